@@ -2,38 +2,89 @@ package main
 
 import (
 	"Crawler/internal/database"
-	"encoding/json"
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
 )
 
 type App struct {
 	Router    *mux.Router
-	DBHandler *database.PSQLHandler
+	DBHandler models.DatabaseHandler
+	// Images is the store of mirrored part photos, nil when it is not configured.
+	Images *images.Store
+	// RecentWindow is how long vehicles and parts are labelled new or recently updated.
+	RecentWindow time.Duration
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
 }
 
+// defaultRecentWindow is used when api.recent_window is not configured.
+const defaultRecentWindow = 7 * 24 * time.Hour
+
 func (a *App) Initialize() {
-	a.DBHandler = database.CreateDatabaseHandler()
+	dbHandler, err := database.CreateDatabaseHandler()
+	if err != nil {
+		log.Fatalf("Cannot connect to database. Reason: %s\n", err)
+	}
+	a.RecentWindow = viper.GetDuration("api.recent_window")
+	if dir := viper.GetString("images.dir"); dir != "" {
+		if a.Images, err = images.Open(dir); err != nil {
+			log.Fatalf("Cannot open the image store. Reason: %s\n", err)
+		}
+	}
+	a.InitializeWithHandler(dbHandler)
+}
+
+// InitializeWithHandler sets up the router on top of an already created database handler.
+func (a *App) InitializeWithHandler(dbHandler models.DatabaseHandler) {
+	a.DBHandler = dbHandler
+	if a.RecentWindow <= 0 {
+		a.RecentWindow = defaultRecentWindow
+	}
+	if a.now == nil {
+		a.now = time.Now
+	}
 	a.Router = mux.NewRouter()
 	a.Router.StrictSlash(true)
+	a.initializeRoutes()
 }
 
-func (a *App) Run(addr string) {
+func (a *App) initializeRoutes() {
 	a.Router.HandleFunc("/vehicles", a.VehicleCountHandler).Methods("GET")
+	a.Router.HandleFunc("/vehicles/recent", a.RecentVehiclesHandler).Methods("GET")
 	a.Router.HandleFunc("/vehicles/types", a.VehicleTypesHandler).Methods("GET")
 	a.Router.HandleFunc("/vehicles/types/{vehicleType}", a.VehiclesWithTypeHandler).Methods("GET")
-	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}", a.VehicleHandler).Methods("GET")
-	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}/parts", a.PartHandler).Methods("GET")
+	// Brand routes are registered before the vehicle routes so that "brands" is not matched as a vehicle id.
 	a.Router.HandleFunc("/vehicles/types/{vehicleType}/brands", a.BrandsWithTypeHandler).Methods("GET")
 	a.Router.HandleFunc("/vehicles/types/{vehicleType}/brands/{brandName}/models", a.ModelsForBrandHandler).Methods("GET")
 	a.Router.HandleFunc("/vehicles/types/{vehicleType}/brands/{brandName}/models/{modelName}", a.VehiclesForModelHandler).Methods("GET")
 	a.Router.HandleFunc("/vehicles/types/{vehicleType}/brands/{brandName}/models/{modelName}/parts", a.PartsForModelHandler).Methods("GET")
+	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}", a.VehicleHandler).Methods("GET")
+	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}/parts", a.PartHandler).Methods("GET")
+	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}/compatible-parts", a.CompatiblePartsHandler).Methods("GET")
+	a.Router.HandleFunc("/vehicles/types/{vehicleType}/{vehicleId}/parts/{partId}/prices", a.PriceHistoryHandler).Methods("GET")
+	a.Router.HandleFunc("/parts/recent", a.RecentPartsHandler).Methods("GET")
+	a.Router.HandleFunc("/search", a.SearchHandler).Methods("GET")
+	a.Router.HandleFunc("/images/duplicates", a.DuplicateImagesHandler).Methods("GET")
+	a.Router.HandleFunc("/images/{hash}", a.ImageHandler).Methods("GET")
+	a.Router.Use(requestIDMiddleware, contentTypeApplicationJsonMiddleware)
+	a.Router.NotFoundHandler = requestIDMiddleware(contentTypeApplicationJsonMiddleware(http.HandlerFunc(notFoundHandler)))
+	a.Router.MethodNotAllowedHandler = requestIDMiddleware(contentTypeApplicationJsonMiddleware(http.HandlerFunc(methodNotAllowedHandler)))
+}
+
+func (a *App) Run(addr string) {
 	http.Handle("/", a.Router)
-	a.Router.Use(contentTypeApplicationJsonMiddleware)
 
 	srv := &http.Server{
 		Handler:      a.Router,
@@ -45,6 +96,23 @@ func (a *App) Run(addr string) {
 	log.Fatal(srv.ListenAndServe())
 }
 
+// labelVehicles labels the vehicles new or recently updated at the current time.
+func (a *App) labelVehicles(vehicles []models.Vehicle) {
+	now := a.now()
+	for i := range vehicles {
+		vehicles[i].Label(now, a.RecentWindow)
+	}
+}
+
+// labelParts labels the parts and their vehicles new or recently updated at the current time.
+func (a *App) labelParts(parts []models.VehicleAndPart) {
+	now := a.now()
+	for i := range parts {
+		parts[i].Vehicle.Label(now, a.RecentWindow)
+		parts[i].Part.Label(now, a.RecentWindow)
+	}
+}
+
 func contentTypeApplicationJsonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -55,156 +123,391 @@ func contentTypeApplicationJsonMiddleware(next http.Handler) http.Handler {
 func (a *App) VehicleCountHandler(w http.ResponseWriter, r *http.Request) {
 	count, err := a.DBHandler.GetVehicleCount()
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(count)
+	writeJSON(w, r, http.StatusOK, count)
+}
+
+func (a *App) VehicleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicle, err := a.DBHandler.GetVehicle(vars["vehicleType"], vars["vehicleId"])
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	vehicle.Label(a.now(), a.RecentWindow)
+	writeJSON(w, r, http.StatusOK, vehicle)
 }
 
-func (a *App) VehicleHandler(w http.ResponseWriter, r *http.Request) {
+func (a *App) PartHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	vehicle, err := a.DBHandler.GetVehicle(vars["vehicleType"], vars["vehicleId"])
+	options, err := parseListOptions(r)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(vehicle)
+	page, err := a.DBHandler.GetPartsForVehicle(vars["vehicleType"], vars["vehicleId"], options)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	a.labelParts(page.Parts)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Parts, page.NextCursor))
 }
 
-func (a *App) PartHandler(w http.ResponseWriter, r *http.Request) {
+func (a *App) CompatiblePartsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	parts, err := a.DBHandler.GetPartsForVehicle(vars["vehicleId"])
+	options, err := parseListOptions(r)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(parts)
+	page, err := a.DBHandler.GetCompatibleParts(vars["vehicleType"], vars["vehicleId"], options)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	a.labelParts(page.Parts)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Parts, page.NextCursor))
 }
 
-func (a *App) BrandsWithTypeHandler(w http.ResponseWriter, r *http.Request) {
+func (a *App) PriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	brands, err := a.DBHandler.GetBrands(vars["vehicleType"])
+	prices, err := a.DBHandler.GetPartPriceHistory(vars["vehicleType"], vars["vehicleId"], vars["partId"])
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	payload, err := json.Marshal(brands)
+	writeJSON(w, r, http.StatusOK, prices)
+}
+
+// imageMaxAge is how long clients may cache a mirrored image. The content of
+// an image never changes, as it is addressed by its hash.
+const imageMaxAge = 365 * 24 * time.Hour
+
+// ImageHandler serves a mirrored part photo by its hash. Images missing from
+// the local store are redirected to the URL they were downloaded from.
+func (a *App) ImageHandler(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+	if !images.ValidHash(hash) {
+		writeError(w, r, fmt.Errorf("%w: invalid image hash %q", errInvalidParameter, hash))
+		return
+	}
+	image, err := a.DBHandler.GetImage(hash)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	err = images.ErrNotStored
+	if a.Images != nil {
+		var file *os.File
+		file, err = a.Images.Open(hash)
+		if err == nil {
+			defer file.Close()
+			w.Header().Set("Content-Type", image.MIMEType)
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(imageMaxAge.Seconds())))
+			w.Header().Set("ETag", `"`+hash+`"`)
+			http.ServeContent(w, r, "", time.Time{}, file)
+			return
+		}
+	}
+	if !errors.Is(err, images.ErrNotStored) {
+		log.Printf("[%s] cannot open image %s: %v", requestIDFrom(r), hash, err)
+	}
+	// The store may get the image later, so the redirect is not cached.
+	w.Header().Del("Content-Type")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.Redirect(w, r, image.SourceURL, http.StatusFound)
+}
+
+// Limits of the max_distance parameter of duplicate images, in bits of the
+// 64-bit perceptual hash.
+const (
+	defaultDuplicateDistance = 6
+	maxDuplicateDistance     = 16
+)
 
+// DuplicateImagesHandler lists the groups of similar part photos shared by
+// different vehicles, such as stock photos. The optional max_distance
+// parameter is how many bits the perceptual hashes of a group may differ in.
+func (a *App) DuplicateImagesHandler(w http.ResponseWriter, r *http.Request) {
+	maxDistance := defaultDuplicateDistance
+	if value := r.URL.Query().Get("max_distance"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > maxDuplicateDistance {
+			writeError(w, r, fmt.Errorf("%w: invalid max_distance %q", errInvalidParameter, value))
+			return
+		}
+		maxDistance = parsed
+	}
+	groups, err := a.DBHandler.GetDuplicateImages(maxDistance)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for i := range groups {
+		a.labelParts(groups[i].Parts)
+	}
+	writeJSON(w, r, http.StatusOK, groups)
 }
 
-func (a *App) ModelsForBrandHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	brands, err := a.DBHandler.GetModelsForBrand(vars["vehicleType"], vars["brandName"])
+// Limits of the number of list items returned at once.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// listResponse is the envelope of paged lists. Next is the URL of the next
+// page, it is null on the last page.
+type listResponse struct {
+	Items any     `json:"items"`
+	Next  *string `json:"next"`
+}
+
+func newListResponse(r *http.Request, items any, nextCursor string) listResponse {
+	response := listResponse{Items: items}
+	if nextCursor != "" {
+		query := r.URL.Query()
+		query.Set("cursor", nextCursor)
+		next := r.URL.Path + "?" + query.Encode()
+		response.Next = &next
+	}
+	return response
+}
+
+// isCurrencyCode tells whether the text has the form of an ISO 4217 code.
+func isCurrencyCode(text string) bool {
+	if len(text) != 3 {
+		return false
+	}
+	for _, r := range text {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseListOptions reads the limit, cursor, sort, min_year, max_year,
+// min_displacement, max_displacement, stroke, currency, min_price and
+// max_price query parameters of a list request. Prices are in the major units
+// of the currency, euros by default.
+func parseListOptions(r *http.Request) (models.ListOptions, error) {
+	query := r.URL.Query()
+	options := models.ListOptions{
+		Limit:  defaultListLimit,
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return options, fmt.Errorf("%w: invalid limit %q", models.ErrInvalidListOptions, value)
+		}
+		options.Limit = min(limit, maxListLimit)
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{
+		{"min_year", &options.MinYear}, {"max_year", &options.MaxYear},
+		{"min_displacement", &options.MinDisplacement}, {"max_displacement", &options.MaxDisplacement},
+	} {
+		if value := query.Get(param.name); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 1 {
+				return options, fmt.Errorf("%w: invalid %s %q", models.ErrInvalidListOptions, param.name, value)
+			}
+			*param.value = number
+		}
+	}
+	if value := query.Get("stroke"); value != "" {
+		options.Stroke = strings.ToUpper(value)
+		if options.Stroke != models.StrokeTwo && options.Stroke != models.StrokeFour {
+			return options, fmt.Errorf("%w: invalid stroke %q", models.ErrInvalidListOptions, value)
+		}
+	}
+	if value := query.Get("currency"); value != "" {
+		options.Currency = strings.ToUpper(value)
+		if !isCurrencyCode(options.Currency) {
+			return options, fmt.Errorf("%w: invalid currency %q", models.ErrInvalidListOptions, value)
+		}
+	}
+	currency := options.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	for _, param := range []struct {
+		name  string
+		value **int64
+	}{{"min_price", &options.MinPrice}, {"max_price", &options.MaxPrice}} {
+		if value := query.Get(param.name); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+				return options, fmt.Errorf("%w: invalid %s %q", models.ErrInvalidListOptions, param.name, value)
+			}
+			minorUnits := models.MinorUnits(price, currency)
+			*param.value = &minorUnits
+		}
+	}
+	return options, nil
+}
+
+// Limits of the number of search hits returned at once.
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// SearchHandler searches vehicles and parts with the query in the q parameter.
+// The optional limit parameter caps the number of hits.
+func (a *App) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(w, r, fmt.Errorf("%w: invalid limit %q", errInvalidParameter, value))
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+	hits, err := a.DBHandler.Search(r.URL.Query().Get("q"), limit)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	payload, err := json.Marshal(brands)
+	now := a.now()
+	for i := range hits {
+		hits[i].Vehicle.Label(now, a.RecentWindow)
+		if hits[i].Part != nil {
+			hits[i].Part.Label(now, a.RecentWindow)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, hits)
+}
+
+func (a *App) BrandsWithTypeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	brands, err := a.DBHandler.GetBrands(vars["vehicleType"])
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	writeJSON(w, r, http.StatusOK, brands)
+}
+
+// ModelsForBrandHandler lists the model names of a brand, or with
+// ?group=family the models grouped by family with their spellings.
+func (a *App) ModelsForBrandHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	switch group := r.URL.Query().Get("group"); group {
+	case "":
+		names, err := a.DBHandler.GetModelsForBrand(vars["vehicleType"], vars["brandName"])
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, names)
+	case "family":
+		families, err := a.DBHandler.GetModelFamilies(vars["vehicleType"], vars["brandName"])
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, families)
+	default:
+		writeError(w, r, fmt.Errorf("%w: invalid group %q", errInvalidParameter, group))
+	}
 }
 
 func (a *App) VehiclesWithTypeHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	vehicles, err := a.DBHandler.GetVehiclesForType(vars["vehicleType"])
+	options, err := parseListOptions(r)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(vehicles)
+	page, err := a.DBHandler.GetVehiclesForType(vars["vehicleType"], options)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	a.labelVehicles(page.Vehicles)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Vehicles, page.NextCursor))
 }
 
-func (a *App) VehicleTypesHandler(w http.ResponseWriter, r *http.Request) {
-	types, err := a.DBHandler.GetVehicleTypes()
+// RecentVehiclesHandler lists the vehicles updated within the recent window, last updated first.
+func (a *App) RecentVehiclesHandler(w http.ResponseWriter, r *http.Request) {
+	options, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	options.UpdatedSince = a.now().Add(-a.RecentWindow)
+	page, err := a.DBHandler.GetRecentVehicles(options)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(types)
+	a.labelVehicles(page.Vehicles)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Vehicles, page.NextCursor))
+}
+
+// RecentPartsHandler lists the parts updated within the recent window, last updated first.
+func (a *App) RecentPartsHandler(w http.ResponseWriter, r *http.Request) {
+	options, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	options.UpdatedSince = a.now().Add(-a.RecentWindow)
+	page, err := a.DBHandler.GetRecentParts(options)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	a.labelParts(page.Parts)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Parts, page.NextCursor))
+}
+
+func (a *App) VehicleTypesHandler(w http.ResponseWriter, r *http.Request) {
+	types, err := a.DBHandler.GetVehicleTypes()
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	writeJSON(w, r, http.StatusOK, types)
 }
 
 func (a *App) PartsForModelHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	parts, err := a.DBHandler.GetPartsForModel(vars["vehicleType"], vars["brandName"], vars["modelName"])
+	options, err := parseListOptions(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(parts)
+	page, err := a.DBHandler.GetPartsForModel(vars["vehicleType"], vars["brandName"], vars["modelName"], options)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	a.labelParts(page.Parts)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Parts, page.NextCursor))
 }
 
 func (a *App) VehiclesForModelHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	vehicles, err := a.DBHandler.GetVehiclesForModel(vars["vehicleType"], vars["brandName"], vars["modelName"])
+	options, err := parseListOptions(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	payload, err := json.Marshal(vehicles)
+	page, err := a.DBHandler.GetVehiclesForModel(vars["vehicleType"], vars["brandName"], vars["modelName"], options)
 	if err != nil {
-		log.Printf("Cannot unmarshal: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		writeError(w, r, err)
 		return
 	}
-	w.Write(payload)
+	a.labelVehicles(page.Vehicles)
+	writeJSON(w, r, http.StatusOK, newListResponse(r, page.Vehicles, page.NextCursor))
 }
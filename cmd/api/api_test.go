@@ -0,0 +1,577 @@
+package main
+
+import (
+	"Crawler/internal/database"
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an App backed by an in-memory handler seeded from testdata.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	handler, err := database.CreateMemoryHandlerFromDirectory("testdata")
+	if err != nil {
+		t.Fatalf("cannot seed memory handler: %v", err)
+	}
+	a := &App{}
+	a.InitializeWithHandler(handler)
+	return a
+}
+
+// euros returns a price in euros with VAT included.
+func euros(amount float64) models.Price {
+	return models.NewPrice(amount, models.DefaultCurrency, true)
+}
+
+func executeRequest(a *App, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+// timeFields are the keys of the timestamps and labels, which depend on when the
+// test data was loaded.
+var timeFields = []string{"CreatedAt", "UpdatedAt", "IsNew", "RecentlyUpdated", "created_at", "updated_at", "is_new", "recently_updated"}
+
+// withoutTimeFields removes the timestamps and labels from a decoded JSON document.
+func withoutTimeFields(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for _, key := range timeFields {
+			delete(value, key)
+		}
+		for key, child := range value {
+			value[key] = withoutTimeFields(child)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = withoutTimeFields(child)
+		}
+	}
+	return value
+}
+
+// assertJSONEqual compares the response body without timestamps and labels
+// with the expected JSON document.
+func assertJSONEqual(t *testing.T, body []byte, want string) {
+	t.Helper()
+	var got, expected any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("response is not JSON: %v (%s)", err, body)
+	}
+	got = withoutTimeFields(got)
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatalf("expected value is not JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+// errorCodes are the error codes of the response statuses.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusInternalServerError: codeInternal,
+	http.StatusServiceUnavailable:  codeUnavailable,
+}
+
+// assertErrorResponse checks that the response is a JSON error with the status,
+// its error code and the request id of the response.
+func assertErrorResponse(t *testing.T, rr *httptest.ResponseRecorder, wantStatus int) {
+	t.Helper()
+	if rr.Code != wantStatus {
+		t.Errorf("status = %v, want %v", rr.Code, wantStatus)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not an error: %v (%s)", err, rr.Body.Bytes())
+	}
+	if body.Error.Code != errorCodes[wantStatus] || body.Error.Message == "" {
+		t.Errorf("error = %+v, want code %q with a message", body.Error, errorCodes[wantStatus])
+	}
+	if requestID := rr.Header().Get(requestIDHeader); body.Error.RequestID == "" || body.Error.RequestID != requestID {
+		t.Errorf("request_id = %q, want %q of the %s header", body.Error.RequestID, requestID, requestIDHeader)
+	}
+}
+
+func Test_Handlers(t *testing.T) {
+	a := newTestApp(t)
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Vehicle count", "/vehicles", http.StatusOK, `4`},
+		{"Vehicle types", "/vehicles/types", http.StatusOK, `["moped","motorcycle"]`},
+		{"Vehicles for type", "/vehicles/types/motorcycle", http.StatusOK,
+			`{"items":[{"Brand":"Aprilia","Model":"MX 125","ModelKey":"MX 125","ModelFamily":"MX","Displacement":125,"VehicleType":"motorcycle","Identifier":"3673734910","Year":2004,"YearFrom":2004,"YearTo":2004,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/apriliamx12504.htm","Parts":null}],"next":null}`},
+		{"Vehicles for unknown type", "/vehicles/types/tractor", http.StatusOK, `{"items":[],"next":null}`},
+		{"Vehicle", "/vehicles/types/moped/1003", http.StatusOK,
+			`{"Brand":"Polini","Model":"XP4 50","ModelKey":"XP4 50","ModelFamily":"XP4","Displacement":50,"VehicleType":"moped","Identifier":"1003","Year":2007,"YearFrom":2007,"YearTo":2007,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/polinixp450.htm","Parts":null}`},
+		{"Vehicle with wrong type", "/vehicles/types/motorcycle/1003", http.StatusNotFound, ``},
+		{"Parts for vehicle", "/vehicles/types/moped/1002/parts", http.StatusOK,
+			`{"items":[{"part":{"name":"Satula","description":"","id":"2003","part_number":"","price":{"amount":3000,"currency":"EUR","vat_included":true,"formatted":"30,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2003.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2003_t.jpg","img_hash":"","img_thumb_hash":""},
+			  "vehicle":{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1002","Year":2017,"YearFrom":2017,"YearTo":2017,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx17.htm","Parts":null}}],"next":null}`},
+		{"Parts for vehicle without parts", "/vehicles/types/moped/1003/parts", http.StatusOK, `{"items":[],"next":null}`},
+		{"Parts for unknown vehicle", "/vehicles/types/moped/9999/parts", http.StatusNotFound, ``},
+		{"Parts for vehicle with wrong type", "/vehicles/types/motorcycle/1002/parts", http.StatusNotFound, ``},
+		{"Brands for type", "/vehicles/types/moped/brands", http.StatusOK, `["Polini","Suzuki"]`},
+		{"Models for brand", "/vehicles/types/moped/brands/Polini/models", http.StatusOK, `["XP4 50"]`},
+		{"Model families for brand", "/vehicles/types/moped/brands/Polini/models?group=family", http.StatusOK,
+			`[{"family":"XP4","models":[{"key":"XP4 50","name":"XP4 50","displacement":50,"spellings":["XP4 50"]}]}]`},
+		{"Models for brand with invalid group", "/vehicles/types/moped/brands/Polini/models?group=year", http.StatusBadRequest, ``},
+		{"Vehicles for model", "/vehicles/types/moped/brands/Suzuki/models/RX", http.StatusOK,
+			`{"items":[{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1002","Year":2017,"YearFrom":2017,"YearTo":2017,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx17.htm","Parts":null},
+			  {"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1001","Year":2019,"YearFrom":2019,"YearTo":2019,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx19.htm","Parts":null}],"next":null}`},
+		{"Parts for model", "/vehicles/types/moped/brands/Suzuki/models/RX/parts?sort=-price&limit=2", http.StatusOK,
+			`{"items":[{"part":{"name":"Satula","description":"","id":"2003","part_number":"","price":{"amount":3000,"currency":"EUR","vat_included":true,"formatted":"30,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2003.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2003_t.jpg","img_hash":"","img_thumb_hash":""},
+			    "vehicle":{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1002","Year":2017,"YearFrom":2017,"YearTo":2017,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx17.htm","Parts":null}},
+			  {"part":{"name":"Takarengas","description":"Hyvä kunto","id":"2001","part_number":"","price":{"amount":2000,"currency":"EUR","vat_included":true,"formatted":"20,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2001.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2001_t.jpg","img_hash":"","img_thumb_hash":""},
+			    "vehicle":{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1001","Year":2019,"YearFrom":2019,"YearTo":2019,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx19.htm","Parts":null}}],
+			 "next":"/vehicles/types/moped/brands/Suzuki/models/RX/parts?cursor=eyJzIjoiLXByaWNlIiwidiI6MjAwMCwiaWQiOiIyMDAxIn0&limit=2&sort=-price"}`},
+		{"Parts for model filtered by price", "/vehicles/types/moped/brands/Suzuki/models/RX/parts?min_price=16&max_price=25", http.StatusOK,
+			`{"items":[{"part":{"name":"Takarengas","description":"Hyvä kunto","id":"2001","part_number":"","price":{"amount":2000,"currency":"EUR","vat_included":true,"formatted":"20,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2001.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2001_t.jpg","img_hash":"","img_thumb_hash":""},
+			    "vehicle":{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1001","Year":2019,"YearFrom":2019,"YearTo":2019,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx19.htm","Parts":null}}],"next":null}`},
+		{"Parts for model filtered by price in another currency", "/vehicles/types/moped/brands/Suzuki/models/RX/parts?currency=sek&min_price=16", http.StatusOK, `{"items":[],"next":null}`},
+		{"Parts for model with invalid currency", "/vehicles/types/moped/brands/Suzuki/models/RX/parts?currency=euro", http.StatusBadRequest, ``},
+		{"Vehicles filtered by displacement", "/vehicles/types/moped?min_displacement=40&max_displacement=100", http.StatusOK,
+			`{"items":[{"Brand":"Polini","Model":"XP4 50","ModelKey":"XP4 50","ModelFamily":"XP4","Displacement":50,"VehicleType":"moped","Identifier":"1003","Year":2007,"YearFrom":2007,"YearTo":2007,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/polinixp450.htm","Parts":null}],"next":null}`},
+		{"Vehicles filtered by stroke", "/vehicles/types/moped?stroke=2t", http.StatusOK, `{"items":[],"next":null}`},
+		{"Invalid limit", "/vehicles/types/moped?limit=0", http.StatusBadRequest, ``},
+		{"Invalid displacement", "/vehicles/types/moped?min_displacement=-50", http.StatusBadRequest, ``},
+		{"Invalid stroke", "/vehicles/types/moped?stroke=3T", http.StatusBadRequest, ``},
+		{"Invalid sort", "/vehicles/types/moped?sort=price", http.StatusBadRequest, ``},
+		{"Invalid filter", "/vehicles/types/moped?max_price=10", http.StatusBadRequest, ``},
+		{"Invalid cursor", "/vehicles/types/moped?cursor=abc", http.StatusBadRequest, ``},
+		{"Unknown route", "/parts", http.StatusNotFound, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := executeRequest(a, tt.path)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				assertJSONEqual(t, rr.Body.Bytes(), tt.wantBody)
+			} else {
+				assertErrorResponse(t, rr, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_PriceHistoryHandler(t *testing.T) {
+	a := newTestApp(t)
+	handler := a.DBHandler.(*database.MemoryHandler)
+	vehicle, _ := handler.GetVehicle("moped", "1002")
+	vehicle.Parts = []models.Part{{Name: "Satula", PartIdentifier: "2003", Price: euros(24), ImgUrl: "https://www.purkuosat.net/kuvat/2003.jpg", ImgThumbUrl: "https://www.purkuosat.net/kuvat/2003_t.jpg"}}
+	if err := handler.InsertParts([]models.Vehicle{vehicle}); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	rr := executeRequest(a, "/vehicles/types/moped/1002/parts/2003/prices")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var history []models.PricePoint
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+		t.Fatalf("response is not a price history: %v", err)
+	}
+	if len(history) != 2 || history[0].Price != euros(30) || history[1].Price != euros(24) || history[0].ObservedAt.IsZero() {
+		t.Errorf("price history = %+v, want prices 30 and 24", history)
+	}
+
+	if rr := executeRequest(a, "/vehicles/types/moped/1001/parts/2003/prices"); rr.Code != http.StatusNotFound {
+		t.Errorf("price history of a part of another vehicle status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func Test_CompatiblePartsHandler(t *testing.T) {
+	a := newTestApp(t)
+	err := a.DBHandler.InsertCompatibilities([]models.Compatibility{
+		{PartIdentifier: "2101", Brand: "Suzuki", Model: "RX", YearFrom: 2018, YearTo: 2020, Source: "part_number"},
+		{PartIdentifier: "2001", Brand: "Suzuki", Model: "RX", YearFrom: 2017, YearTo: 2019, Source: "part_name"},
+	})
+	if err != nil {
+		t.Fatalf("InsertCompatibilities() error = %v", err)
+	}
+
+	rr := executeRequest(a, "/vehicles/types/moped/1001/compatible-parts")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	assertJSONEqual(t, rr.Body.Bytes(), `{"items":[{"part":{"name":"Kaasukahva","description":"","id":"2101","part_number":"","price":{"amount":1200,"currency":"EUR","vat_included":true,"formatted":"12,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2101.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2101_t.jpg","img_hash":"","img_thumb_hash":""},
+		"vehicle":{"Brand":"Aprilia","Model":"MX 125","ModelKey":"MX 125","ModelFamily":"MX","Displacement":125,"VehicleType":"motorcycle","Identifier":"3673734910","Year":2004,"YearFrom":2004,"YearTo":2004,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/apriliamx12504.htm","Parts":null}}],"next":null}`)
+
+	rr = executeRequest(a, "/vehicles/types/moped/1002/compatible-parts")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	assertJSONEqual(t, rr.Body.Bytes(), `{"items":[{"part":{"name":"Takarengas","description":"Hyvä kunto","id":"2001","part_number":"","price":{"amount":2000,"currency":"EUR","vat_included":true,"formatted":"20,00\u00a0€"},"img_url":"https://www.purkuosat.net/kuvat/2001.jpg","img_thumb_url":"https://www.purkuosat.net/kuvat/2001_t.jpg","img_hash":"","img_thumb_hash":""},
+		"vehicle":{"Brand":"Suzuki","Model":"RX","ModelKey":"RX","ModelFamily":"RX","Displacement":0,"VehicleType":"moped","Identifier":"1001","Year":2019,"YearFrom":2019,"YearTo":2019,"Stroke":"","Trim":"","Url":"https://www.purkuosat.net/suzukirx19.htm","Parts":null}}],"next":null}`)
+
+	if rr := executeRequest(a, "/vehicles/types/moped/9999/compatible-parts"); rr.Code != http.StatusNotFound {
+		t.Errorf("compatible parts of an unknown vehicle status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func Test_ImageHandler(t *testing.T) {
+	a := newTestApp(t)
+	store, err := images.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("images.Open() error = %v", err)
+	}
+	a.Images = store
+	var content bytes.Buffer
+	png.Encode(&content, image.NewGray(image.Rect(0, 0, 2, 2)))
+	mirrored, err := store.Put(content.Bytes(), "https://www.purkuosat.net/kuvat/2001.jpg")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	// The second image is known but missing from the store.
+	missing := models.Image{Hash: strings.Repeat("ab", 32), SourceURL: "https://www.purkuosat.net/kuvat/2003.jpg", Size: 10, MIMEType: "image/jpeg"}
+	if err := a.DBHandler.InsertImages([]models.Image{mirrored, missing}); err != nil {
+		t.Fatalf("InsertImages() error = %v", err)
+	}
+
+	rr := executeRequest(a, "/images/"+mirrored.Hash)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content.Bytes()) {
+		t.Fatalf("status = %v, want %v with the image", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+	if cc := rr.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("Cache-Control = %q, want an immutable image", cc)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/images/"+mirrored.Hash, nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("status of a cached image = %v, want %v", rr.Code, http.StatusNotModified)
+	}
+
+	rr = executeRequest(a, "/images/"+missing.Hash)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != missing.SourceURL {
+		t.Errorf("missing image status = %v, location %q, want a redirect to %s", rr.Code, rr.Header().Get("Location"), missing.SourceURL)
+	}
+	if rr := executeRequest(a, "/images/"+strings.Repeat("cd", 32)); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown image status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := executeRequest(a, "/images/abc"); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid hash status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func Test_DuplicateImagesHandler(t *testing.T) {
+	a := newTestApp(t)
+	stock := models.Image{Hash: strings.Repeat("ab", 32), SourceURL: "https://www.purkuosat.net/kuvat/9011.jpg", Size: 10, MIMEType: "image/jpeg", PHash: "8f3c00ff12345678"}
+	recompressed := models.Image{Hash: strings.Repeat("cd", 32), SourceURL: "https://www.purkuosat.net/kuvat/9021.jpg", Size: 8, MIMEType: "image/jpeg", PHash: "8f3c00ff1234567f"}
+	if err := a.DBHandler.InsertImages([]models.Image{stock, recompressed}); err != nil {
+		t.Fatalf("InsertImages() error = %v", err)
+	}
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "9001", Year: 2019, Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "9011", Price: euros(20), ImgHash: stock.Hash}}},
+		{Brand: "Honda", Model: "MB", VehicleType: "moped", Identifier: "9002", Year: 1982, Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "9021", Price: euros(25), ImgHash: recompressed.Hash}}},
+	}
+	if err := a.DBHandler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := a.DBHandler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantGroups int
+	}{
+		{"Default distance", "/images/duplicates", http.StatusOK, 1},
+		{"Exact photos", "/images/duplicates?max_distance=0", http.StatusOK, 0},
+		{"Invalid distance", "/images/duplicates?max_distance=abc", http.StatusBadRequest, 0},
+		{"Too large distance", "/images/duplicates?max_distance=17", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := executeRequest(a, tt.path)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var groups []models.DuplicateImageGroup
+			if err := json.Unmarshal(rr.Body.Bytes(), &groups); err != nil {
+				t.Fatalf("cannot decode %s: %v", rr.Body.String(), err)
+			}
+			if len(groups) != tt.wantGroups {
+				t.Fatalf("groups = %+v, want %d", groups, tt.wantGroups)
+			}
+			if tt.wantGroups > 0 && (len(groups[0].Images) != 2 || len(groups[0].Parts) != 2 || groups[0].Parts[0].Vehicle.Identifier != "9001") {
+				t.Errorf("group = %+v, want both photos and parts", groups[0])
+			}
+		})
+	}
+}
+
+func Test_SearchHandler(t *testing.T) {
+	a := newTestApp(t)
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"Vehicles and parts", "/search?q=polini+OR+satula", http.StatusOK, []string{"1003", "2003"}},
+		{"Phrase", "/search?q=%22hyv%C3%A4+kunto%22", http.StatusOK, []string{"2001"}},
+		{"Prefix and NOT", "/search?q=suzuki+-taka*+-etu*+-rx", http.StatusOK, []string{}},
+		{"Limit", "/search?q=suzuki&limit=1", http.StatusOK, []string{"1001"}},
+		{"Missing query", "/search", http.StatusBadRequest, nil},
+		{"Invalid query", "/search?q=-suzuki", http.StatusBadRequest, nil},
+		{"Invalid limit", "/search?q=suzuki&limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := executeRequest(a, tt.path)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var hits []models.SearchHit
+			if err := json.Unmarshal(rr.Body.Bytes(), &hits); err != nil {
+				t.Fatalf("response is not a list of search hits: %v", err)
+			}
+			ids := []string{}
+			for _, hit := range hits {
+				if hit.Kind == models.SearchHitPart {
+					ids = append(ids, hit.Part.PartIdentifier)
+				} else {
+					ids = append(ids, hit.Vehicle.Identifier)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("hits = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func Test_ListPagination(t *testing.T) {
+	a := newTestApp(t)
+	var ids []string
+	next := "/vehicles/types/moped?limit=1"
+	for pages := 0; next != ""; pages++ {
+		if pages == 5 {
+			t.Fatalf("pagination did not end, got %v", ids)
+		}
+		rr := executeRequest(a, next)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status of %s = %v, want %v", next, rr.Code, http.StatusOK)
+		}
+		var page struct {
+			Items []models.Vehicle `json:"items"`
+			Next  *string          `json:"next"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("response is not a page: %v", err)
+		}
+		for _, vehicle := range page.Items {
+			ids = append(ids, vehicle.Identifier)
+		}
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+	if want := []string{"1003", "1001", "1002"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("paged vehicles = %v, want %v", ids, want)
+	}
+}
+
+func Test_RecentHandlers(t *testing.T) {
+	a := newTestApp(t)
+	loaded := time.Now()
+	err := a.DBHandler.InsertParts([]models.Vehicle{{Identifier: "1001", Parts: []models.Part{
+		{Name: "Takarengas", Description: "Hyvä kunto", PartIdentifier: "2001", Price: euros(18), ImgUrl: "https://www.purkuosat.net/kuvat/2001.jpg", ImgThumbUrl: "https://www.purkuosat.net/kuvat/2001_t.jpg"},
+	}}})
+	if err != nil {
+		t.Fatalf("cannot update part: %v", err)
+	}
+	// The test data is older than the window, the updated part is not.
+	a.now = func() time.Time { return loaded.Add(a.RecentWindow) }
+
+	rr := executeRequest(a, "/vehicles/recent")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var vehicles struct {
+		Items []models.Vehicle `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &vehicles); err != nil {
+		t.Fatalf("response is not a page: %v", err)
+	}
+	if len(vehicles.Items) != 1 || vehicles.Items[0].Identifier != "1001" {
+		t.Fatalf("recent vehicles = %+v, want vehicle 1001", vehicles.Items)
+	}
+	if vehicle := vehicles.Items[0]; vehicle.IsNew || !vehicle.RecentlyUpdated {
+		t.Errorf("vehicle is_new = %v, recently_updated = %v, want false, true", vehicle.IsNew, vehicle.RecentlyUpdated)
+	}
+
+	rr = executeRequest(a, "/parts/recent")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var parts struct {
+		Items []models.VehicleAndPart `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &parts); err != nil {
+		t.Fatalf("response is not a page: %v", err)
+	}
+	if len(parts.Items) != 1 || parts.Items[0].Part.PartIdentifier != "2001" {
+		t.Fatalf("recent parts = %+v, want part 2001", parts.Items)
+	}
+	if part := parts.Items[0].Part; part.IsNew || !part.RecentlyUpdated || part.Price != euros(18) {
+		t.Errorf("part = %+v, want the updated part labelled recently updated", part)
+	}
+
+	// Everything loaded is new within the window of the load.
+	a.now = time.Now
+	rr = executeRequest(a, "/vehicles/types/moped/1003")
+	var vehicle models.Vehicle
+	if err := json.Unmarshal(rr.Body.Bytes(), &vehicle); err != nil {
+		t.Fatalf("response is not a vehicle: %v", err)
+	}
+	if !vehicle.IsNew || vehicle.RecentlyUpdated || vehicle.CreatedAt.IsZero() {
+		t.Errorf("vehicle = %+v, want a new vehicle with a creation time", vehicle)
+	}
+}
+
+// failingHandler fails every read with err.
+type failingHandler struct {
+	models.DatabaseHandler
+	err error
+}
+
+func (h failingHandler) GetVehicleCount() (int, error)                      { return 0, h.err }
+func (h failingHandler) GetVehicleTypes() ([]string, error)                 { return nil, h.err }
+func (h failingHandler) GetBrands(string) ([]string, error)                 { return nil, h.err }
+func (h failingHandler) GetModelsForBrand(string, string) ([]string, error) { return nil, h.err }
+func (h failingHandler) GetVehicle(string, string) (models.Vehicle, error) {
+	return models.Vehicle{}, h.err
+}
+func (h failingHandler) GetVehiclesForType(string, models.ListOptions) (models.VehiclePage, error) {
+	return models.VehiclePage{}, h.err
+}
+func (h failingHandler) GetVehiclesForModel(string, string, string, models.ListOptions) (models.VehiclePage, error) {
+	return models.VehiclePage{}, h.err
+}
+func (h failingHandler) GetPartsForVehicle(string, string, models.ListOptions) (models.PartPage, error) {
+	return models.PartPage{}, h.err
+}
+func (h failingHandler) GetPartsForModel(string, string, string, models.ListOptions) (models.PartPage, error) {
+	return models.PartPage{}, h.err
+}
+func (h failingHandler) GetCompatibleParts(string, string, models.ListOptions) (models.PartPage, error) {
+	return models.PartPage{}, h.err
+}
+func (h failingHandler) GetPartPriceHistory(string, string, string) ([]models.PricePoint, error) {
+	return nil, h.err
+}
+func (h failingHandler) GetRecentVehicles(models.ListOptions) (models.VehiclePage, error) {
+	return models.VehiclePage{}, h.err
+}
+func (h failingHandler) GetRecentParts(models.ListOptions) (models.PartPage, error) {
+	return models.PartPage{}, h.err
+}
+func (h failingHandler) Search(string, int) ([]models.SearchHit, error) { return nil, h.err }
+
+func Test_ErrorResponses(t *testing.T) {
+	paths := []string{
+		"/vehicles",
+		"/vehicles/recent",
+		"/vehicles/types",
+		"/vehicles/types/moped",
+		"/vehicles/types/moped/brands",
+		"/vehicles/types/moped/brands/Suzuki/models",
+		"/vehicles/types/moped/brands/Suzuki/models/RX",
+		"/vehicles/types/moped/brands/Suzuki/models/RX/parts",
+		"/vehicles/types/moped/1001",
+		"/vehicles/types/moped/1001/parts",
+		"/vehicles/types/moped/1001/compatible-parts",
+		"/vehicles/types/moped/1001/parts/2001/prices",
+		"/parts/recent",
+		"/search?q=suzuki",
+	}
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Not found", fmt.Errorf("vehicle: %w", sql.ErrNoRows), http.StatusNotFound},
+		{"Invalid options", fmt.Errorf("%w: malformed cursor", models.ErrInvalidListOptions), http.StatusBadRequest},
+		{"Broken connection", driver.ErrBadConn, http.StatusServiceUnavailable},
+		{"Connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, http.StatusServiceUnavailable},
+		{"Other failure", errors.New("pq: relation \"vehicles\" does not exist"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		a := &App{}
+		a.InitializeWithHandler(failingHandler{err: tt.err})
+		for _, path := range paths {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				rr := executeRequest(a, path)
+				assertErrorResponse(t, rr, tt.wantStatus)
+				if tt.wantStatus == http.StatusInternalServerError && strings.Contains(rr.Body.String(), "pq:") {
+					t.Errorf("body %s exposes the cause of the error", rr.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func Test_RequestID(t *testing.T) {
+	a := newTestApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/vehicles/types/moped/9999", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	assertErrorResponse(t, rr, http.StatusNotFound)
+	if requestID := rr.Header().Get(requestIDHeader); requestID != "abc-123" {
+		t.Errorf("request id = %q, want the request id of the request", requestID)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/vehicles", nil)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	assertErrorResponse(t, rr, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"Crawler/internal/models"
+	"Crawler/internal/search"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Error codes of the error responses.
+const (
+	codeBadRequest       = "bad_request"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeInternal         = "internal_error"
+	codeUnavailable      = "unavailable"
+)
+
+// errInvalidParameter is returned for query parameters that cannot be parsed.
+var errInvalidParameter = errors.New("invalid parameter")
+
+// apiError is an error with the status and body of the response it is sent as.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	// Err is the cause that is logged, it is never sent to the client.
+	Err error
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// toAPIError maps an error of a handler to its response. Missing rows are not
+// found, invalid input is a bad request and broken database connections make
+// the service unavailable. The details of other errors are not exposed.
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, sql.ErrNoRows):
+		return &apiError{Status: http.StatusNotFound, Code: codeNotFound, Message: "resource not found", Err: err}
+	case errors.Is(err, models.ErrInvalidListOptions), errors.Is(err, search.ErrInvalidQuery), errors.Is(err, errInvalidParameter):
+		return &apiError{Status: http.StatusBadRequest, Code: codeBadRequest, Message: err.Error(), Err: err}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return &apiError{Status: http.StatusServiceUnavailable, Code: codeUnavailable, Message: "database unavailable", Err: err}
+	}
+	return &apiError{Status: http.StatusInternalServerError, Code: codeInternal, Message: "internal server error", Err: err}
+}
+
+// writeError logs the error and sends it as an error response.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	requestID := requestIDFrom(r)
+	log.Printf("[%s] %s %s: %d %v", requestID, r.Method, r.URL.Path, apiErr.Status, err)
+	payload, _ := json.Marshal(errorResponse{Error: errorBody{Code: apiErr.Code, Message: apiErr.Message, RequestID: requestID}})
+	w.WriteHeader(apiErr.Status)
+	w.Write(payload)
+}
+
+// writeJSON sends value as the JSON body of a response with the status. The
+// value is marshalled before the status is written, so that a failure can
+// still be sent as an error response.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, value any) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("cannot marshal response: %w", err))
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &apiError{Status: http.StatusNotFound, Code: codeNotFound, Message: "resource not found"})
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, &apiError{Status: http.StatusMethodNotAllowed, Code: codeMethodNotAllowed, Message: "method not allowed"})
+}
+
+// requestIDHeader carries the request id. A request id sent by the client is
+// kept, otherwise one is generated.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// requestIDMiddleware adds the request id to the context of the request and
+// to the response headers.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+func requestIDFrom(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
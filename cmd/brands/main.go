@@ -0,0 +1,169 @@
+package main
+
+import (
+	"Crawler/internal/data"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const usage = `Usage: brands [flags] <command>
+
+Commands:
+  list      list the brands of the catalogue with their aliases and vehicle types
+  unmapped  list the vehicle names the crawler saw but could not map to a brand
+
+Flags:`
+
+// issueMissingBrand is the code of the quarantine issue the crawler reports
+// for vehicle names without a known brand.
+const issueMissingBrand = "missing_brand"
+
+// quarantineRecord holds the fields of the crawler quarantine records that
+// tell the names without a brand.
+type quarantineRecord struct {
+	Name   string `json:"name"`
+	Issues []struct {
+		Code string `json:"code"`
+	} `json:"issues"`
+}
+
+// unmappedBrand is a first word of vehicle names that could not be mapped to
+// a brand, it is likely a brand missing from the catalogue or a new alias.
+type unmappedBrand struct {
+	Word  string
+	Count int
+	// Types are the vehicle types the names were seen in.
+	Types []string
+	// Examples are a few of the names.
+	Examples []string
+}
+
+// maxExamples is the number of example names listed for an unmapped word.
+const maxExamples = 3
+
+// findUnmapped reads the <category>_quarantine.json files of the crawler in
+// dir and groups the vehicle names without a brand by their first word. Names
+// that the catalogue maps now are left out. The most common words come first.
+func findUnmapped(dir string, catalogue *data.Catalogue) ([]unmappedBrand, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*_quarantine.json"))
+	if err != nil {
+		return nil, err
+	}
+	byWord := make(map[string]*unmappedBrand)
+	for _, path := range paths {
+		vehicleType := strings.TrimSuffix(filepath.Base(path), "_quarantine.json")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var records []quarantineRecord
+		if err = json.Unmarshal(content, &records); err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		for _, record := range records {
+			words := strings.Fields(record.Name)
+			if !hasIssue(record, issueMissingBrand) || len(words) == 0 {
+				continue
+			}
+			if _, _, mapped := catalogue.Match(record.Name, vehicleType); mapped {
+				continue
+			}
+			key := strings.ToLower(words[0])
+			unmapped, exists := byWord[key]
+			if !exists {
+				unmapped = &unmappedBrand{Word: words[0]}
+				byWord[key] = unmapped
+			}
+			unmapped.Count++
+			if !contains(unmapped.Types, vehicleType) {
+				unmapped.Types = append(unmapped.Types, vehicleType)
+			}
+			if len(unmapped.Examples) < maxExamples && !contains(unmapped.Examples, record.Name) {
+				unmapped.Examples = append(unmapped.Examples, record.Name)
+			}
+		}
+	}
+	found := make([]unmappedBrand, 0, len(byWord))
+	for _, unmapped := range byWord {
+		found = append(found, *unmapped)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Count != found[j].Count {
+			return found[i].Count > found[j].Count
+		}
+		return strings.ToLower(found[i].Word) < strings.ToLower(found[j].Word)
+	})
+	return found, nil
+}
+
+func hasIssue(record quarantineRecord, code string) bool {
+	for _, issue := range record.Issues {
+		if issue.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	cataloguePath := flag.String("catalogue", "", "brand catalogue file, the built-in catalogue when empty")
+	outputDir := flag.String("output", "./output", "output directory of the crawler")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	catalogue := data.DefaultCatalogue()
+	if *cataloguePath != "" {
+		var err error
+		if catalogue, err = data.LoadCatalogue(*cataloguePath); err != nil {
+			log.Fatalf("Cannot load the brand catalogue %s. Reason: %s\n", *cataloguePath, err)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "list":
+		for _, brand := range catalogue.Brands {
+			types := "all types"
+			if len(brand.VehicleTypes) > 0 {
+				types = strings.Join(brand.VehicleTypes, ", ")
+			}
+			fmt.Printf("%-20s %-30s %s\n", brand.Name, strings.Join(brand.Aliases, ", "), types)
+		}
+	case "unmapped":
+		found, err := findUnmapped(*outputDir, catalogue)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if len(found) == 0 {
+			log.Println("Every vehicle name was mapped to a brand.")
+			return
+		}
+		for _, unmapped := range found {
+			fmt.Printf("%-20s %5d  %-25s e.g. %s\n", unmapped.Word, unmapped.Count, strings.Join(unmapped.Types, ", "), strings.Join(unmapped.Examples, "; "))
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"Crawler/internal/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_findUnmapped(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"moped_quarantine.json": `[
+  {"name": "Foobar RX 2019", "issues": [{"code": "missing_brand"}]},
+  {"name": "Foobar XR 2018", "issues": [{"code": "missing_brand"}, {"code": "unknown_year"}]},
+  {"name": "Suzuki RX", "issues": [{"code": "unknown_year"}]},
+  {"name": "Zongshen ZS 2012", "issues": [{"code": "missing_brand"}]}
+]`,
+		"snowmobile_quarantine.json": `[
+  {"name": "foobar Z 2001", "issues": [{"code": "missing_brand"}]},
+  {"name": "Yamaha Viking 2005", "issues": [{"code": "missing_brand"}]}
+]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := findUnmapped(dir, data.DefaultCatalogue())
+	if err != nil {
+		t.Fatalf("findUnmapped() error = %v", err)
+	}
+	// Yamaha is in the catalogue by now.
+	want := []unmappedBrand{
+		{Word: "Foobar", Count: 3, Types: []string{"moped", "snowmobile"}, Examples: []string{"Foobar RX 2019", "Foobar XR 2018", "foobar Z 2001"}},
+		{Word: "Zongshen", Count: 1, Types: []string{"moped"}, Examples: []string{"Zongshen ZS 2012"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findUnmapped() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"Crawler/internal/compatibility"
+	"Crawler/internal/models"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// partChanges holds the differences between the stored parts of a vehicle and
+// the parts scraped from its listing.
+type partChanges struct {
+	added   []models.Part
+	changed []models.Part
+	removed []string
+}
+
+// diffParts compares stored and scraped parts by their identifiers. Only the
+// scraped fields of the parts are compared.
+func diffParts(stored []models.Part, scraped []models.Part) partChanges {
+	var changes partChanges
+	storedByID := make(map[string]models.Part, len(stored))
+	for _, part := range stored {
+		storedByID[part.PartIdentifier] = part
+	}
+	seen := make(map[string]bool, len(scraped))
+	for _, part := range scraped {
+		if seen[part.PartIdentifier] {
+			continue
+		}
+		seen[part.PartIdentifier] = true
+		storedPart, exists := storedByID[part.PartIdentifier]
+		if !exists {
+			changes.added = append(changes.added, part)
+		} else if storedPart.Scraped() != part.Scraped() {
+			changes.changed = append(changes.changed, part)
+		}
+	}
+	for _, part := range stored {
+		if !seen[part.PartIdentifier] {
+			changes.removed = append(changes.removed, part.PartIdentifier)
+		}
+	}
+	return changes
+}
+
+// syncVehiclesToDatabase compares the scraped vehicles of a category with the
+// stored ones and applies the differences: new and changed vehicles and parts
+// are upserted, vehicles and parts missing from the listing are marked deleted.
+// The stored vehicles whose part page failed to crawl are not missing, they
+// are left as they are. Vehicles still stored with legacy identifiers are re-keyed first. Vehicles
+// and parts whose identifiers collide with other stored records are logged and
+// left out. The photos of the parts are mirrored with the mirror, unless it is
+// nil. Finally the compatibilities proposed between the scraped parts are
+// stored.
+func syncVehiclesToDatabase(handler models.DatabaseHandler, category string, vehicles []models.Vehicle, failures []crawlFailure, mirror *imageMirror) error {
+	err := rekeyLegacyVehicles(handler, category, vehicles)
+	if err != nil {
+		return err
+	}
+	storedVehicles, err := handler.GetVehiclesForType(category, models.ListOptions{})
+	if err != nil {
+		return err
+	}
+	scrapedIDs := make(map[string]bool, len(vehicles))
+	for _, vehicle := range vehicles {
+		scrapedIDs[vehicle.Identifier] = true
+	}
+	failedURLs := make(map[string]bool, len(failures))
+	for _, failure := range failures {
+		failedURLs[failure.URL] = true
+	}
+	var removedVehicles []string
+	for _, vehicle := range storedVehicles.Vehicles {
+		if !scrapedIDs[vehicle.Identifier] && !failedURLs[vehicle.Url] {
+			removedVehicles = append(removedVehicles, vehicle.Identifier)
+		}
+	}
+
+	// Only the added and changed parts of each vehicle are written.
+	var upserts []models.Vehicle
+	var removedParts []string
+	var mirrored []models.Image
+	var addedCount, changedCount int
+	for _, vehicle := range vehicles {
+		// A vehicle that is not stored yet has no stored parts.
+		stored, err := handler.GetPartsForVehicle(vehicle.VehicleType, vehicle.Identifier, models.ListOptions{})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		storedParts := make([]models.Part, len(stored.Parts))
+		for i, vehicleAndPart := range stored.Parts {
+			storedParts[i] = vehicleAndPart.Part
+		}
+		keepImageHashes(vehicle.Parts, storedParts)
+		if mirror != nil {
+			mirrored = append(mirrored, mirror.mirror(vehicle.Parts)...)
+		}
+		changes := diffParts(storedParts, vehicle.Parts)
+		addedCount += len(changes.added)
+		changedCount += len(changes.changed)
+		removedParts = append(removedParts, changes.removed...)
+
+		upsert := vehicle
+		upsert.Parts = append(changes.added, changes.changed...)
+		upserts = append(upserts, upsert)
+	}
+
+	collided, err := logCollisions(category, handler.InsertVehicles(vehicles))
+	if err != nil {
+		return err
+	}
+	if len(collided) > 0 {
+		// The parts of a collided vehicle would be stored under the other vehicle.
+		var kept []models.Vehicle
+		for _, upsert := range upserts {
+			if !collided[upsert.Identifier] {
+				kept = append(kept, upsert)
+			}
+		}
+		upserts = kept
+	}
+	err = handler.InsertImages(mirrored)
+	if err != nil {
+		return err
+	}
+	_, err = logCollisions(category, handler.InsertParts(upserts))
+	if err != nil {
+		return err
+	}
+	err = handler.DeleteParts(removedParts)
+	if err != nil {
+		return err
+	}
+	err = handler.DeleteVehicles(removedVehicles)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d vehicles scraped, %d removed. Parts: %d added, %d changed, %d removed.",
+		category, len(vehicles), len(removedVehicles), addedCount, changedCount, len(removedParts))
+
+	compatibilities := compatibility.Match(vehicles)
+	err = handler.InsertCompatibilities(compatibilities)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d part compatibilities proposed.", category, len(compatibilities))
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"Crawler/internal/database"
+	"Crawler/internal/models"
+	"reflect"
+	"testing"
+)
+
+// euros returns a price in euros with VAT included.
+func euros(amount float64) models.Price {
+	return models.NewPrice(amount, models.DefaultCurrency, true)
+}
+
+func Test_diffParts(t *testing.T) {
+	wheel := models.Part{Name: "Takarengas", PartIdentifier: "1", Price: euros(20)}
+	seat := models.Part{Name: "Satula", PartIdentifier: "2", Price: euros(30)}
+	cheaperSeat := models.Part{Name: "Satula", PartIdentifier: "2", Price: euros(25)}
+	fender := models.Part{Name: "Etulokasuoja", PartIdentifier: "3", Price: euros(15)}
+	tests := []struct {
+		name    string
+		stored  []models.Part
+		scraped []models.Part
+		want    partChanges
+	}{
+		{"Test new vehicle", nil, []models.Part{wheel, seat}, partChanges{added: []models.Part{wheel, seat}}},
+		{"Test unchanged parts", []models.Part{wheel, seat}, []models.Part{seat, wheel}, partChanges{}},
+		{"Test changed price", []models.Part{wheel, seat}, []models.Part{wheel, cheaperSeat}, partChanges{changed: []models.Part{cheaperSeat}}},
+		{"Test added and removed", []models.Part{wheel, seat}, []models.Part{wheel, fender}, partChanges{added: []models.Part{fender}, removed: []string{"2"}}},
+		{"Test all removed", []models.Part{wheel}, nil, partChanges{removed: []string{"1"}}},
+		{"Test duplicate scraped part", nil, []models.Part{wheel, wheel}, partChanges{added: []models.Part{wheel}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffParts(tt.stored, tt.scraped); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffParts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_syncVehiclesToDatabase(t *testing.T) {
+	handler := database.CreateMemoryHandler()
+	first := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019,
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Satula", PartIdentifier: "12", Price: euros(30)}}},
+		{Brand: "Polini", Model: "XP4 50", VehicleType: "moped", Identifier: "2", Year: 2007,
+			Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "21", Price: euros(10)}}},
+	}
+	if err := syncVehiclesToDatabase(handler, "moped", first, nil, nil); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	// The Polini is sold out, the seat got cheaper and the rear wheel was sold.
+	second := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019,
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "12", Price: euros(25)}, {Name: "Etulokasuoja", PartIdentifier: "13", Price: euros(15)}}},
+	}
+	if err := syncVehiclesToDatabase(handler, "moped", second, nil, nil); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+
+	vehicles, _ := handler.GetVehiclesForType("moped", models.ListOptions{})
+	if len(vehicles.Vehicles) != 1 || vehicles.Vehicles[0].Identifier != "1" {
+		t.Errorf("GetVehiclesForType() = %+v, want only vehicle 1", vehicles)
+	}
+	parts, _ := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	var got []models.Part
+	for _, vehicleAndPart := range parts.Parts {
+		got = append(got, vehicleAndPart.Part.Scraped())
+	}
+	want := []models.Part{{Name: "Etulokasuoja", PartIdentifier: "13", Price: euros(15)}, {Name: "Satula", PartIdentifier: "12", Price: euros(25)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPartsForVehicle() parts = %+v, want %+v", got, want)
+	}
+
+	// A vehicle that comes back is restored with its parts.
+	if err := syncVehiclesToDatabase(handler, "moped", first, nil, nil); err != nil {
+		t.Fatalf("third sync error = %v", err)
+	}
+	if count, _ := handler.GetVehicleCount(); count != 2 {
+		t.Errorf("GetVehicleCount() = %d, want 2", count)
+	}
+	parts, _ = handler.GetPartsForVehicle("moped", "2", models.ListOptions{})
+	if len(parts.Parts) != 1 {
+		t.Errorf("restored vehicle has parts %+v", parts.Parts)
+	}
+}
+
+func Test_syncVehiclesToDatabase_failedPage(t *testing.T) {
+	handler := database.CreateMemoryHandler()
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}}},
+		{Brand: "Polini", Model: "XP4 50", VehicleType: "moped", Identifier: "2", Year: 2007, Url: "https://www.purkuosat.net/polinixp450.htm",
+			Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "21", Price: euros(10)}}},
+	}
+	if err := syncVehiclesToDatabase(handler, "moped", vehicles, nil, nil); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	// The part page of the Polini failed, it is still listed.
+	failures := []crawlFailure{{URL: vehicles[1].Url, Vehicle: "Polini XP4 50 2007", Attempts: 3, Error: "timeout"}}
+	if err := syncVehiclesToDatabase(handler, "moped", vehicles[:1], failures, nil); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	if count, _ := handler.GetVehicleCount(); count != 2 {
+		t.Errorf("GetVehicleCount() = %d, want 2", count)
+	}
+	parts, err := handler.GetPartsForVehicle("moped", "2", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 {
+		t.Errorf("GetPartsForVehicle() of the failed vehicle = %+v, %v", parts, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+)
+
+// legacyHash generates the 32-bit identifiers that vehicles and parts were
+// stored with before helpers.GenerateHash. The values are hashed one after another.
+func legacyHash(hashableVals ...string) string {
+	h := fnv.New32a()
+	for _, a := range hashableVals {
+		h.Write([]byte(a))
+	}
+	return fmt.Sprint(h.Sum32())
+}
+
+// legacyIdentifierChanges returns the changes that re-key the scraped vehicles
+// of a category still stored with their legacy identifiers, and their parts.
+// Vehicles already stored with the new identifier are left alone.
+func legacyIdentifierChanges(handler models.DatabaseHandler, category string, vehicles []models.Vehicle) ([]models.IdentifierChange, []models.IdentifierChange, error) {
+	stored, err := handler.GetVehiclesForType(category, models.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	storedIDs := make(map[string]bool, len(stored.Vehicles))
+	for _, vehicle := range stored.Vehicles {
+		storedIDs[vehicle.Identifier] = true
+	}
+
+	var vehicleChanges, partChanges []models.IdentifierChange
+	for _, vehicle := range vehicles {
+		oldID := legacyHash(vehicle.Url, vehicle.Name)
+		if !storedIDs[oldID] || storedIDs[vehicle.Identifier] || oldID == vehicle.Identifier {
+			continue
+		}
+		vehicleChanges = append(vehicleChanges, models.IdentifierChange{Old: oldID, New: vehicle.Identifier})
+		storedIDs[oldID] = false
+
+		storedParts, err := handler.GetPartsForVehicle(category, oldID, models.ListOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		storedPartIDs := make(map[string]bool, len(storedParts.Parts))
+		for _, vehicleAndPart := range storedParts.Parts {
+			storedPartIDs[vehicleAndPart.Part.PartIdentifier] = true
+		}
+		for _, part := range vehicle.Parts {
+			oldPartID := legacyHash(part.PartNumber, vehicle.Name)
+			if storedPartIDs[oldPartID] {
+				partChanges = append(partChanges, models.IdentifierChange{Old: oldPartID, New: part.PartIdentifier})
+				// A part number listed twice is re-keyed once.
+				storedPartIDs[oldPartID] = false
+			}
+		}
+	}
+	return vehicleChanges, partChanges, nil
+}
+
+// rekeyLegacyVehicles moves the scraped vehicles of a category and their parts
+// from their legacy identifiers to the current ones, so that their history and
+// compatibilities are kept. The listings no longer on the site are re-keyed by
+// the rekey command of migrate.
+func rekeyLegacyVehicles(handler models.DatabaseHandler, category string, vehicles []models.Vehicle) error {
+	vehicleChanges, partChanges, err := legacyIdentifierChanges(handler, category, vehicles)
+	if err != nil || len(vehicleChanges) == 0 {
+		return err
+	}
+	err = handler.RekeyIdentifiers(vehicleChanges, partChanges)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d vehicles and %d parts re-keyed from legacy identifiers.", category, len(vehicleChanges), len(partChanges))
+	return nil
+}
+
+// logCollisions logs the records an insert skipped because of identifier
+// collisions and returns their identifiers. Other errors are returned as is.
+func logCollisions(category string, err error) (map[string]bool, error) {
+	var collisionErr *models.CollisionError
+	if !errors.As(err, &collisionErr) {
+		return nil, err
+	}
+	collided := make(map[string]bool, len(collisionErr.Collisions))
+	for _, collision := range collisionErr.Collisions {
+		log.Printf("%s: %s %s of %s collides with %s, not stored.",
+			category, collision.Kind, collision.Identifier, collision.Scraped, collision.Stored)
+		collided[collision.Identifier] = true
+	}
+	return collided, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"Crawler/internal/database"
+	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"testing"
+)
+
+func Test_legacyHash(t *testing.T) {
+	got := legacyHash("https://www.purkuosat.net/apriliamx12504.htm", "Aprilia MX 125 2004")
+	if want := "3673734910"; got != want {
+		t.Errorf("legacyHash() = %v, want %v", got, want)
+	}
+}
+
+func Test_syncVehiclesToDatabase_legacyIdentifiers(t *testing.T) {
+	handler := database.CreateMemoryHandler()
+	raw := models.RawVehicle{Name: "Suzuki RX 2019", Url: "https://www.purkuosat.net/suzukirx19.htm",
+		RawParts: []models.RawPart{{Name: "Satula", PartIdentifier: "SR-1", Price: "30 €"}}}
+	vehicle := processRawVehicle(raw, "moped", sites.Purkuosat{})
+
+	// The vehicle as it was stored with the legacy identifiers.
+	legacy := vehicle
+	legacy.Identifier = legacyHash(raw.Url, raw.Name)
+	legacy.Parts = []models.Part{vehicle.Parts[0]}
+	legacy.Parts[0].PartIdentifier = legacyHash("SR-1", raw.Name)
+	legacy.Parts[0].PartNumber = ""
+	if err := handler.InsertVehicles([]models.Vehicle{legacy}); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts([]models.Vehicle{legacy}); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	if err := syncVehiclesToDatabase(handler, "moped", []models.Vehicle{vehicle}, nil, nil); err != nil {
+		t.Fatalf("syncVehiclesToDatabase() error = %v", err)
+	}
+	if count, _ := handler.GetVehicleCount(); count != 1 {
+		t.Errorf("GetVehicleCount() = %d, want the vehicle re-keyed and not added", count)
+	}
+	parts, err := handler.GetPartsForVehicle(vehicle.VehicleType, vehicle.Identifier, models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.PartIdentifier != vehicle.Parts[0].PartIdentifier {
+		t.Fatalf("GetPartsForVehicle() = %+v, %v, want the re-keyed part", parts, err)
+	}
+	if part := parts.Parts[0].Part; part.PartNumber != "SR-1" {
+		t.Errorf("re-keyed part number = %q, want SR-1", part.PartNumber)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxImageSize is the largest image that is mirrored.
+const maxImageSize = 10 << 20
+
+// defaultThumbnailSize is the width and height of the generated thumbnails.
+const defaultThumbnailSize = 200
+
+// imageMirror downloads the photos of scraped parts into the image store.
+// The downloads are spaced like the crawl of the site.
+type imageMirror struct {
+	store     *images.Store
+	client    *http.Client
+	userAgent string
+	delay     time.Duration
+	limiter   *requestLimiter
+	// thumbnailSize is the width and height of the generated thumbnails.
+	thumbnailSize int
+	// mirrored holds the photos downloaded in this run by their URL, so that a
+	// photo shared by parts is downloaded once.
+	mirrored map[string]mirroredPhoto
+	// lastRequest is when the previous image was requested.
+	lastRequest time.Time
+}
+
+// mirroredPhoto is a downloaded photo and the thumbnail generated from it.
+// The thumbnail is empty when the photo cannot be decoded.
+type mirroredPhoto struct {
+	image     models.Image
+	thumbnail models.Image
+}
+
+// configureImageMirror returns the image mirror configured with the images
+// key, nil when mirroring is disabled.
+func configureImageMirror(policy politenessPolicy, limiter *requestLimiter) (*imageMirror, error) {
+	if !viper.GetBool("images.mirror") {
+		return nil, nil
+	}
+	store, err := images.Open(viper.GetString("images.dir"))
+	if err != nil {
+		return nil, err
+	}
+	mirror := newImageMirror(store, policy, limiter)
+	if viper.IsSet("images.thumbnail_size") {
+		mirror.thumbnailSize = viper.GetInt("images.thumbnail_size")
+		if mirror.thumbnailSize <= 0 {
+			return nil, fmt.Errorf("invalid images.thumbnail_size %d", mirror.thumbnailSize)
+		}
+	}
+	return mirror, nil
+}
+
+func newImageMirror(store *images.Store, policy politenessPolicy, limiter *requestLimiter) *imageMirror {
+	return &imageMirror{
+		store:     store,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		userAgent: policy.UserAgent,
+		delay:     policy.Delay,
+		limiter:   limiter,
+
+		thumbnailSize: defaultThumbnailSize,
+		mirrored:      make(map[string]mirroredPhoto),
+	}
+}
+
+// fetch downloads an image.
+func (m *imageMirror) fetch(url string) ([]byte, error) {
+	if wait := m.delay - time.Since(m.lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	m.limiter.wait()
+	m.lastRequest = time.Now()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m.userAgent != "" {
+		req.Header.Set("User-Agent", m.userAgent)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxImageSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxImageSize)
+	}
+	return content, nil
+}
+
+// download fetches a photo, stores it and generates its thumbnail.
+// thumbnailURL is where the thumbnail is served from when it is missing from
+// the store.
+func (m *imageMirror) download(url string, thumbnailURL string) (mirroredPhoto, error) {
+	content, err := m.fetch(url)
+	if err != nil {
+		return mirroredPhoto{}, err
+	}
+	image, err := m.store.Put(content, url)
+	if err != nil {
+		return mirroredPhoto{}, err
+	}
+	photo := mirroredPhoto{image: image}
+	photo.thumbnail, err = m.store.PutThumbnail(content, m.thumbnailSize, thumbnailURL)
+	if err != nil {
+		log.Printf("Cannot generate a thumbnail of the image %s. Reason: %s", url, err)
+	}
+	return photo, nil
+}
+
+// mirror sets the image hashes of the parts, downloading the photos that are
+// not in the store yet. The thumbnail of a part is generated from its photo,
+// or from the thumbnail of the site when the photo cannot be downloaded. It
+// returns the images downloaded for the first time in this run. A photo that
+// cannot be downloaded is logged and its hash left empty, so that it keeps
+// being served from the site.
+func (m *imageMirror) mirror(parts []models.Part) []models.Image {
+	var downloaded []models.Image
+	for i := range parts {
+		part := &parts[i]
+		thumbnailURL := part.ImgThumbUrl
+		if thumbnailURL == "" {
+			thumbnailURL = part.ImgUrl
+		}
+		if (part.ImgUrl == "" || m.store.Has(part.ImgHash)) && (thumbnailURL == "" || m.store.Has(part.ImgThumbHash)) {
+			continue
+		}
+		part.ImgHash, part.ImgThumbHash = "", ""
+		for _, url := range []string{part.ImgUrl, part.ImgThumbUrl} {
+			if url == "" {
+				continue
+			}
+			photo, exists := m.mirrored[url]
+			if !exists {
+				var err error
+				photo, err = m.download(url, thumbnailURL)
+				if err != nil {
+					log.Printf("Cannot mirror the image %s of part %s. Reason: %s", url, part.PartIdentifier, err)
+					continue
+				}
+				m.mirrored[url] = photo
+				downloaded = append(downloaded, photo.image)
+				if photo.thumbnail.Hash != "" {
+					downloaded = append(downloaded, photo.thumbnail)
+				}
+			}
+			if url == part.ImgUrl {
+				part.ImgHash = photo.image.Hash
+			}
+			part.ImgThumbHash = photo.thumbnail.Hash
+			break
+		}
+	}
+	return downloaded
+}
+
+// keepImageHashes copies the image hashes of the stored parts to the scraped
+// parts with the same photos, so that unchanged photos are not downloaded
+// again and are not lost when mirroring is disabled. The thumbnail is kept
+// when both photos are unchanged, as it is generated from either of them.
+func keepImageHashes(scraped []models.Part, stored []models.Part) {
+	storedByID := make(map[string]models.Part, len(stored))
+	for _, part := range stored {
+		storedByID[part.PartIdentifier] = part
+	}
+	for i := range scraped {
+		storedPart, exists := storedByID[scraped[i].PartIdentifier]
+		if !exists {
+			continue
+		}
+		if scraped[i].ImgHash == "" && storedPart.ImgUrl == scraped[i].ImgUrl {
+			scraped[i].ImgHash = storedPart.ImgHash
+		}
+		if scraped[i].ImgThumbHash == "" && storedPart.ImgUrl == scraped[i].ImgUrl && storedPart.ImgThumbUrl == scraped[i].ImgThumbUrl {
+			scraped[i].ImgThumbHash = storedPart.ImgThumbHash
+		}
+	}
+}
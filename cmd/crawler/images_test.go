@@ -0,0 +1,84 @@
+package main
+
+import (
+	"Crawler/internal/database"
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_syncVehiclesToDatabase_mirrorImages(t *testing.T) {
+	var content bytes.Buffer
+	png.Encode(&content, image.NewGray(image.Rect(0, 0, 3, 2)))
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path != "/kuvat/11.jpg" && r.URL.Path != "/kuvat/13_t.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content.Bytes())
+	}))
+	defer server.Close()
+	store, err := images.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("images.Open() error = %v", err)
+	}
+	mirror := newImageMirror(store, politenessPolicy{UserAgent: "TestBot/1.0"}, nil)
+
+	handler := database.CreateMemoryHandler()
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "11", Price: euros(20), ImgUrl: server.URL + "/kuvat/11.jpg", ImgThumbUrl: server.URL + "/kuvat/11_t.jpg"},
+			// The same photo is used by two parts.
+			{Name: "Eturengas", PartIdentifier: "12", Price: euros(20), ImgUrl: server.URL + "/kuvat/11.jpg"},
+			// Only the thumbnail of the site can be downloaded.
+			{Name: "Vanne", PartIdentifier: "13", Price: euros(30), ImgUrl: server.URL + "/kuvat/13.jpg", ImgThumbUrl: server.URL + "/kuvat/13_t.jpg"},
+		}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := syncVehiclesToDatabase(handler, "moped", vehicles, nil, mirror); err != nil {
+			t.Fatalf("syncVehiclesToDatabase() error = %v", err)
+		}
+		// The next crawl scrapes the parts without hashes again.
+		for i := range vehicles[0].Parts {
+			vehicles[0].Parts[i].ImgHash, vehicles[0].Parts[i].ImgThumbHash = "", ""
+		}
+	}
+
+	parts, _ := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	for _, vehicleAndPart := range parts.Parts {
+		part := vehicleAndPart.Part
+		thumbnail, err := handler.GetImage(part.ImgThumbHash)
+		if !store.Has(part.ImgThumbHash) || err != nil || thumbnail.Width != defaultThumbnailSize || thumbnail.Height != defaultThumbnailSize {
+			t.Errorf("part %s thumbnail = %+v, %v, want a generated %dx%[4]d thumbnail", part.PartIdentifier, thumbnail, err, defaultThumbnailSize)
+		}
+		if part.PartIdentifier == "13" {
+			if part.ImgHash != "" {
+				t.Errorf("part 13 photo hash = %q, want none", part.ImgHash)
+			}
+			continue
+		}
+		mirrored, err := handler.GetImage(part.ImgHash)
+		if !store.Has(part.ImgHash) || err != nil || mirrored.SourceURL != server.URL+"/kuvat/11.jpg" || mirrored.Width != 3 || mirrored.Height != 2 {
+			t.Errorf("part %s photo = %+v, %v, want the mirrored 3x2 photo", part.PartIdentifier, mirrored, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// The stored photos are not downloaded again, the missing photo is retried.
+	want := map[string]int{"/kuvat/11.jpg": 1, "/kuvat/13.jpg": 2, "/kuvat/13_t.jpg": 1}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
@@ -1,42 +1,61 @@
 package main
 
 import (
+	"Crawler/internal/archive"
+	"Crawler/internal/crawlqueue"
 	"Crawler/internal/data"
 	"Crawler/internal/database"
 	"Crawler/internal/helpers"
 	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"hash/fnv"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gocolly/colly/v2"
-	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
 )
 
-const BrandReMatcher = "^[\\w-]+"
+// brands recognises the brands of the vehicle names. It is the built-in
+// catalogue unless brand_catalogue is configured.
+var brands = data.DefaultCatalogue()
 
-// Instantiates a Colly collector and configures it.
-func createCollector() (*colly.Collector, error) {
+// defaultSite is crawled when the site is not configured.
+const defaultSite = "purkuosat"
+
+// defaultQueuePath is the crawl queue file when queue_path is not configured.
+const defaultQueuePath = "./output/crawl_queue.db"
+
+// defaultMaxFailureRate is the share of failed part pages of a category above
+// which the crawl fails when max_failure_rate is not configured.
+const defaultMaxFailureRate = 0.05
+
+// Crawl modes. Live fetches the pages from the site, record fetches them too
+// and stores them into the archive, replay serves them from the archive.
+const (
+	modeLive   = "live"
+	modeRecord = "record"
+	modeReplay = "replay"
+)
+
+// Instantiates a Colly collector for the domains of the site and configures it
+// to crawl as politely as the policy says.
+func createCollector(adapter sites.SiteAdapter, policy politenessPolicy) (*colly.Collector, error) {
 	// Instantiate default collector
 	c := colly.NewCollector(
-		colly.AllowedDomains("purkuosat.net", "www.purkuosat.net"),
+		colly.AllowedDomains(adapter.AllowedDomains()...),
 		colly.AllowURLRevisit(),
+		colly.UserAgent(policy.UserAgent),
 	)
+	c.IgnoreRobotsTxt = !policy.ObeyRobots
 
-	err := c.Limit(&colly.LimitRule{
-		DomainGlob:  "*purkuosat.*",
-		Parallelism: 10,
-		Delay:       50 * time.Millisecond,
-		RandomDelay: 50 * time.Millisecond,
-	})
+	err := c.Limit(policy.limitRule())
 	if err != nil {
 		log.Fatalf("Cannot set limit rule. Reason: %s\n", err)
 		return nil, err
@@ -44,10 +63,36 @@ func createCollector() (*colly.Collector, error) {
 	return c, nil
 }
 
-func configureDefaultHandlers(c *colly.Collector) {
-	// Set Fake User Agent and log visited URLs
+// configureTransport makes the collector record the fetched pages into the
+// archive directory or replay them from there, depending on the crawl mode.
+func configureTransport(c *colly.Collector, mode string, archiveDir string) error {
+	switch mode {
+	case "", modeLive:
+		return nil
+	case modeRecord, modeReplay:
+		pageArchive, err := archive.Open(archiveDir)
+		if err != nil {
+			return err
+		}
+		if mode == modeRecord {
+			c.WithTransport(&archive.Recorder{Archive: pageArchive})
+		} else {
+			c.WithTransport(&archive.Replayer{Archive: pageArchive})
+			// The pages were fetched under the robots.txt when they were
+			// recorded, and older archives do not hold it.
+			c.IgnoreRobotsTxt = true
+		}
+		log.Printf("Crawling in %s mode with the archive %s.", mode, archiveDir)
+		return nil
+	}
+	return fmt.Errorf("unknown crawl mode %q", mode)
+}
+
+// configureDefaultHandlers logs the visited URLs and responses. The requests
+// wait for the limiter shared by all collectors of the crawl.
+func configureDefaultHandlers(c *colly.Collector, limiter *requestLimiter) {
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "1 Mozilla/5.0 (iPad; CPU OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148")
+		limiter.wait()
 		log.Println("visiting", r.URL.String())
 	})
 
@@ -56,72 +101,97 @@ func configureDefaultHandlers(c *colly.Collector) {
 	})
 }
 
+// configureRetry reads the retry policy of part page fetches from the config.
+// Unset values and delays that are not positive keep their defaults. The
+// maximum delay is at least the base delay.
+func configureRetry() retryPolicy {
+	policy := defaultRetryPolicy()
+	if viper.IsSet("retry.max_attempts") {
+		policy.maxAttempts = max(viper.GetInt("retry.max_attempts"), 1)
+	}
+	if viper.IsSet("retry.base_delay") {
+		if delay := viper.GetDuration("retry.base_delay"); delay > 0 {
+			policy.baseDelay = delay
+		} else {
+			log.Printf("Ignoring the retry.base_delay %s, it must be positive.", delay)
+		}
+	}
+	if viper.IsSet("retry.max_delay") {
+		if delay := viper.GetDuration("retry.max_delay"); delay > 0 {
+			policy.maxDelay = delay
+		} else {
+			log.Printf("Ignoring the retry.max_delay %s, it must be positive.", delay)
+		}
+	}
+	policy.maxDelay = max(policy.maxDelay, policy.baseDelay)
+	return policy
+}
+
 func main() {
+	resume := flag.Bool("resume", false, "continue the last unfinished crawl of each category instead of starting over")
+	flag.Parse()
 	helpers.ReadConfig()
-	c, _ := createCollector()
-	configureDefaultHandlers(c)
-
-	var vehicles []models.RawVehicle
-
-	// Each font element is a disassembled vehicle link
-	c.OnHTML("font", func(e *colly.HTMLElement) {
-		if e.Attr("size") == "2" {
-			// Cloning the collector so we can visit the part page
-			partCollector := c.Clone()
-
-			// Instantiate a new vehicle and parts list for it.
-			vehicle := models.RawVehicle{}
-			var parts []models.RawPart
-
-			// Grabbing basic info from the link text
-			var vehicleName = e.ChildText("a")
-			if len(vehicleName) == 0 {
-				return
-			}
-
-			vehicle.Name = vehicleName
-			vehicle.Url = e.Request.AbsoluteURL(e.ChildAttr("a", "href"))
-
-			partCollector.OnRequest(func(r *colly.Request) {
-				log.Println("Part collector visiting page:", r.URL.String())
-			})
-
-			partCollector.OnHTML("table", func(tb *colly.HTMLElement) {
-				part := models.RawPart{}
-				if tb.Attr("width") == "75%" {
-					part.Name = tb.ChildText("tr:nth-of-type(1) > td:nth-of-type(3)")
-					part.ImgThumbUrl = e.Request.AbsoluteURL(tb.ChildAttr("tr:nth-of-type(1) > td:nth-of-type(1) > a > img", "src"))
-					part.ImgUrl = e.Request.AbsoluteURL(tb.ChildAttr("tr:nth-of-type(1) > td:nth-of-type(1) > a", "href"))
-					part.PartIdentifier = tb.ChildText("tr:nth-of-type(2) > td:nth-of-type(2)")
-					part.Description = tb.ChildText("tr:nth-of-type(3) > td:nth-of-type(2)")
-					part.Price = tb.ChildText("tr:nth-of-type(4) > td:nth-of-type(2) > font > b:nth-of-type(1)")
-					parts = append(parts, part)
-				}
-			})
+	siteName := viper.GetString("site")
+	if siteName == "" {
+		siteName = defaultSite
+	}
+	adapter, err := sites.Get(siteName)
+	if err != nil {
+		log.Fatalf("Cannot crawl site. Reason: %s\n", err)
+	}
+	if cataloguePath := viper.GetString("brand_catalogue"); cataloguePath != "" {
+		if brands, err = data.LoadCatalogue(cataloguePath); err != nil {
+			log.Fatalf("Cannot load the brand catalogue %s. Reason: %s\n", cataloguePath, err)
+		}
+	}
+	// Retrieve the map of vehicle categories that should be crawled.
+	categories := viper.GetStringMapString("crawl_categories")
 
-			// Visiting the vehicle part page
-			err := partCollector.Visit(vehicle.Url)
-			if err != nil {
-				log.Printf("Cannot visit the part page: %s. Reason: %s\n", vehicle.Url, err)
-				return
+	policy := configurePolicy(adapter)
+	crawlMode := viper.GetString("crawl_mode")
+	if crawlMode != modeReplay && !viper.GetBool("loadFromJSON") {
+		client := &http.Client{Timeout: robotsTimeout}
+		for _, listingPageUrl := range categories {
+			if err = policy.applyCrawlDelay(client, listingPageUrl); err != nil {
+				log.Printf("Cannot read the robots.txt of %s. Reason: %s\n", listingPageUrl, err)
 			}
-
-			// Add parts to the vehicle
-			vehicle.RawParts = parts
-			vehicles = append(vehicles, vehicle)
-		} else {
-			return
 		}
-	})
+	}
+	log.Printf("Crawling %s with %s.", siteName, policy)
+	c, _ := createCollector(adapter, policy)
+	if err = configureTransport(c, crawlMode, viper.GetString("archive_dir")); err != nil {
+		log.Fatalf("Cannot configure crawl mode. Reason: %s\n", err)
+	}
+	crawler := newPipeline(c, adapter)
+	crawler.retry = configureRetry()
+	crawler.limiter = newRequestLimiter(policy.MaxRequestsPerMinute)
+	mirror, err := configureImageMirror(policy, crawler.limiter)
+	if err != nil {
+		log.Fatalf("Cannot open the image store. Reason: %s\n", err)
+	}
+	maxFailureRate := defaultMaxFailureRate
+	if viper.IsSet("max_failure_rate") {
+		maxFailureRate = viper.GetFloat64("max_failure_rate")
+	}
+	var failedCategories []string
 
-	// Retrieve the map of vehicle categories that should be crawled.
-	categories := viper.GetStringMapString("crawl_categories")
+	var queue *crawlqueue.Queue
+	if !viper.GetBool("loadFromJSON") {
+		queuePath := viper.GetString("queue_path")
+		if queuePath == "" {
+			queuePath = defaultQueuePath
+		}
+		if queue, err = crawlqueue.Open(queuePath); err != nil {
+			log.Fatalf("Cannot open the crawl queue %s. Reason: %s\n", queuePath, err)
+		}
+		defer queue.Close()
+	}
 
 	// Iterate over the vehicle categories.
 	for category, listingPageUrl := range categories {
 		var processedVehicles []models.Vehicle
-		// Emptying vehicles slice when switching categories.
-		vehicles = nil
+		var failures []crawlFailure
+		var run *crawlqueue.Run
 		if viper.GetBool("loadFromJSON") {
 			log.Println("Loading vehicles from JSON.")
 			// Get the absolute path of the JSON file
@@ -131,96 +201,114 @@ func main() {
 			}
 
 			// Read vehicles from the JSON file
-			processedVehicles, err = ReadVehiclesFromJSONFile(absJSONFilePath)
+			processedVehicles, err = database.ReadVehiclesFromJSONFile(absJSONFilePath)
 			if err != nil {
 				log.Fatal(err)
 			}
+			var quarantined []quarantineRecord
+			var summary validationSummary
+			processedVehicles, quarantined, summary = validateVehicles(processedVehicles, adapter)
+			logValidation(category, summary, quarantined)
 		} else {
-			fName := "./output/" + category + "_data.json"
-			file, err := os.Create(fName)
+			if *resume {
+				run, err = queue.Resume(category, listingPageUrl)
+			} else {
+				run, err = queue.Start(category, listingPageUrl)
+			}
 			if err != nil {
-				log.Fatalf("Cannot create file %q: %s\n", fName, err)
-				return
+				log.Fatalf("Cannot start the crawl of category %s. Reason: %s\n", category, err)
 			}
-			defer func(file *os.File) {
-				err := file.Close()
-				if err != nil {
-					log.Fatalf("Cannot close the file. Reason: %s\n", err)
-					return
-				}
-			}(file)
-
-			err = c.Visit(listingPageUrl)
+			var report crawlReport
+			processedVehicles, report, err = crawler.crawl(context.Background(), category, listingPageUrl, run)
 			if err != nil {
 				log.Fatalf("Cannot visit the page %s. Reason: %s\n", listingPageUrl, err)
-				return
 			}
+			log.Printf("Crawled %d vehicles of category %s, %d part pages failed", len(processedVehicles), category, len(report.Failures))
+			logValidation(category, report.Validation, report.quarantined)
 
-			// Wait until all threads have finished.
-			c.Wait()
-
-			enc := json.NewEncoder(file)
-			enc.SetIndent("", "  ")
+			reportName := "./output/" + category + "_failures.json"
+			if err = writeReport(reportName, report); err != nil {
+				log.Printf("Cannot write the failure report %q: %s\n", reportName, err)
+			}
+			failures = report.Failures
+			if rate := report.failureRate(); rate > maxFailureRate {
+				// Too many vehicles would be left as they were stored, so the
+				// category is not synced. The run stays unfinished for
+				// --resume to retry the failed pages.
+				log.Printf("%.1f%% of the part pages of category %s failed, more than the allowed %.1f%%. See %s.", rate*100, category, maxFailureRate*100, reportName)
+				failedCategories = append(failedCategories, category)
+				continue
+			}
 
-			// Convert raw vehicles to vehicles
-			processedVehicles := convertRawVehiclesToVehicles(vehicles, category)
-			// Dump json to the standard output
-			enc.Encode(processedVehicles)
-			log.Printf("Successfully dumped json to the file %s.", file.Name())
+			fName := "./output/" + category + "_data.json"
+			if err = writeVehiclesToJSONFile(fName, processedVehicles); err != nil {
+				log.Fatalf("Cannot write file %q: %s\n", fName, err)
+			}
+			log.Printf("Successfully dumped json to the file %s.", fName)
 		}
 		log.Println("Connecting to database.")
-		dbHandler := database.CreateDatabaseHandler()
+		dbHandler, err := database.CreateDatabaseHandler()
+		if err != nil {
+			log.Fatalf("Cannot connect to database. Reason: %s\n", err)
+		}
 		log.Println("Transfering vehicles to database.")
-		transferVehiclesToDatabase(dbHandler, processedVehicles)
+		transferVehiclesToDatabase(dbHandler, category, processedVehicles, failures, mirror)
+		if len(failures) > 0 {
+			// The run stays unfinished for --resume to retry the failed pages.
+			log.Printf("The crawl of category %s is left unfinished, %d part pages failed. See %s.", category, len(failures), "./output/"+category+"_failures.json")
+		} else if run != nil {
+			if err = run.Finish(); err != nil {
+				log.Printf("Cannot mark the crawl of category %s finished. Reason: %s\n", category, err)
+			}
+		}
+	}
+	if len(failedCategories) > 0 {
+		log.Printf("Too many part pages failed in categories %s, they were not synced to the database.", strings.Join(failedCategories, ", "))
+		os.Exit(1)
 	}
 }
 
-// ReadVehiclesFromJSONFile reads vehicles from a JSON file and returns a slice of vehicles
-func ReadVehiclesFromJSONFile(filePath string) ([]models.Vehicle, error) {
-	// Read the JSON file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// logValidation logs the validation summary of the category and writes its
+// quarantined records into ./output/<category>_quarantine.json.
+func logValidation(category string, summary validationSummary, quarantined []quarantineRecord) {
+	log.Printf("%s: %s", category, summary)
+	fName := "./output/" + category + "_quarantine.json"
+	if err := writeQuarantine(fName, quarantined); err != nil {
+		log.Printf("Cannot write the quarantine file %q: %s\n", fName, err)
 	}
-	defer file.Close()
+}
 
-	// Decode the JSON data into a slice of vehicles
-	var vehicles []models.Vehicle
-	err = json.NewDecoder(file).Decode(&vehicles)
+// writeVehiclesToJSONFile dumps the vehicles to an indented JSON file.
+func writeVehiclesToJSONFile(fName string, vehicles []models.Vehicle) error {
+	file, err := os.Create(fName)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return vehicles, nil
-}
-
-// convertRawVehiclesToVehicles converts raw vehicle data to processed vehicle data
-func convertRawVehiclesToVehicles(rawVehicles []models.RawVehicle, category string) []models.Vehicle {
-	log.Printf("Converting %d raw vehicles to vehicles", len(rawVehicles))
-	var vehicles []models.Vehicle
-	for _, rawVehicle := range rawVehicles {
-		vehicles = append(vehicles, processRawVehicle(rawVehicle, category))
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(vehicles); err != nil {
+		file.Close()
+		return err
 	}
-	return vehicles
+	return file.Close()
 }
 
-// processVehicleData processes the raw vehicle data and returns a vehicle struct
-func processRawVehicle(rawVehicle models.RawVehicle, category string) models.Vehicle {
+// processVehicleData processes the raw vehicle data and returns a vehicle struct.
+// Prices are parsed in the format of the site.
+func processRawVehicle(rawVehicle models.RawVehicle, category string, adapter sites.SiteAdapter) models.Vehicle {
 	// Instantiate a new vehicle and parts list for it.
 	var vehicle models.Vehicle
 	var parts []models.Part
 
 	vehicle.Name = standardizeSpaces(rawVehicle.Name)
 	vehicle.Url = rawVehicle.Url
-	vehicle.Year = extractYear(vehicle.Name)
-	vehicle.Brand = extractBrand(vehicle.Name, BrandReMatcher)
-	vehicle.Model = extractModel(vehicle.Name)
+	applyVehicleName(&vehicle, parseVehicleName(vehicle.Name, category))
 	vehicle.VehicleType = category
-	vehicle.Identifier = generateHash(vehicle.Url, vehicle.Name)
+	vehicle.Identifier = helpers.GenerateHash(vehicle.Url, vehicle.Name)
 
 	for _, part := range rawVehicle.RawParts {
 		// Parsing price from string to float64
-		price, err := parsePrice(part.Price)
+		price, err := adapter.ParsePrice(part.Price)
 		if err != nil {
 			log.Printf("Cannot parse price string of part %s (Url: %s) (Value: %q). Reason: %s\n", part.PartIdentifier, vehicle.Url, part.Price, err)
 		}
@@ -228,7 +316,8 @@ func processRawVehicle(rawVehicle models.RawVehicle, category string) models.Veh
 		newPart := models.Part{
 			Name:           standardizeSpaces(part.Name),
 			Description:    standardizeSpaces(part.Description),
-			PartIdentifier: generateHash(part.PartIdentifier, vehicle.Name),
+			PartIdentifier: helpers.GenerateHash(vehicle.Identifier, part.PartIdentifier),
+			PartNumber:     part.PartIdentifier,
 			Price:          price,
 			ImgUrl:         part.ImgUrl,
 			ImgThumbUrl:    part.ImgThumbUrl,
@@ -244,129 +333,33 @@ func standardizeSpaces(s string) string {
 	return strings.Join(strings.Fields(s), " ")
 }
 
-func parsePrice(price string) (float64, error) {
-	// regular expression to match a sequence of digits optionally followed by a decimal point and more digits
-	re := regexp.MustCompile(`(\d+\.\d+|\d+)`)
-	matches := re.FindStringSubmatch(price)
-
-	if len(matches) == 0 {
-		return 0, fmt.Errorf("no price found in string")
-	}
-
-	priceFloat, err := strconv.ParseFloat(matches[0], 64)
-	if err != nil {
-		return 0, fmt.Errorf("cannot parse price: %w", err)
-	}
-
-	return priceFloat, nil
-}
-
-// extractYear extracts the year from a string
+// extractYear extracts the model year, or the first year of a range, from a vehicle name.
 func extractYear(s string) int {
-	// regular expression to match a sequence of digits optionally followed by a decimal point and more digits
-	re := regexp.MustCompile(`\d{4}`)
-	matches := re.FindStringSubmatch(s)
-
-	if len(matches) == 0 {
-		return 0
-	}
-
-	year, err := strconv.Atoi(matches[0])
-	if err != nil {
-		return 0
-	}
-
-	return year
-}
-
-// extractBrand extracts the brand from a string
-func extractBrand(s string, regexMatcher string) string {
-	// Trim the string
-	s = strings.TrimSpace(s)
-	re := regexp.MustCompile(regexMatcher)
-	matches := re.FindStringSubmatch(s)
-
-	if len(matches) == 0 {
-		return ""
-	}
-
-	// Replace dashes with spaces in the matched brand
-	match := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(matches[0], "-", " "), " ", ""))
-
-	// Convert brands to a map for faster lookup
-	brandsMap := make(map[string]string)
-	for _, brand := range data.Brands {
-		processedBrand := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(brand, "-", " "), " ", ""))
-		brandsMap[processedBrand] = brand
-	}
-
-	// if the brand is in the map of brands, return it
-	if brand, ok := brandsMap[match]; ok {
-		return brand
-	}
-
-	// Recursively call the function with regex matcher that matches first two words.
-	// Exit on the second call
-	if regexMatcher == BrandReMatcher {
-		return extractBrand(s, regexMatcher+"\\s[\\w-]+")
-	}
-	return ""
+	return parseVehicleName(s, "").Year
 }
 
-// extractModel extracts the model from a string
-func extractModel(s string) string {
-	// Trim the string
-	s = strings.TrimSpace(s)
-	// Model is between brand and year in the string, other can be discarded.
-	// Identify brand first
-	brand := extractBrand(s, BrandReMatcher)
-	// If brand is not found, return empty string
-	if len(brand) == 0 {
+// extractBrand extracts the canonical brand of a vehicle of the type from its name.
+func extractBrand(s string, vehicleType string) string {
+	brand, _, ok := brands.Match(s, vehicleType)
+	if !ok {
 		return ""
 	}
-	// Identify year
-	year := extractYear(s)
-
-	// Identify the indexes of brand and year and take the string between them
-	brandIndex := strings.Index(strings.ToLower(s), strings.ToLower(brand))
-	// If year exists, then take the index of it
-	var yearIndex int
-	if year != 0 {
-		yearIndex = strings.Index(s, strconv.Itoa(year))
-		// Take the string between brand and year
-		return strings.TrimSpace(s[brandIndex+len(brand) : yearIndex])
-	} else {
-		// If year does not exist, trim out the brand and take the rest of the string
-		return strings.TrimSpace(s[brandIndex+len(brand):])
-	}
+	return brand.Name
 }
 
-// generateHash
-// generates a hashed identifier from one or many string values.
-func generateHash(hashableVals ...string) string {
-	h := fnv.New32a()
-	for _, a := range hashableVals {
-		h.Write([]byte(a))
-	}
-	return fmt.Sprint(h.Sum32())
+// extractModel extracts the model of a vehicle of the type from its name.
+func extractModel(s string, vehicleType string) string {
+	// Model is between brand and year in the string, it is empty without a brand.
+	return parseVehicleName(s, vehicleType).Model
 }
 
-// transferVehiclesToDatabase writes the contents of the parsed vehicles and their parts there.
-func transferVehiclesToDatabase(handler *database.PSQLHandler, vehicles []models.Vehicle) {
-	// Verify the connection by pinging the database
-	err := handler.DB.Ping()
-	if err != nil {
-		panic(err)
-	}
+// transferVehiclesToDatabase writes the changes in the parsed vehicles and their parts there.
+// The vehicles of the failed part pages are left as they are.
+func transferVehiclesToDatabase(handler models.DatabaseHandler, category string, vehicles []models.Vehicle, failures []crawlFailure, mirror *imageMirror) {
 	// Close connection after everything has been sent to database.
-	defer handler.DB.Close()
-	err = handler.InsertVehicles(vehicles)
-	if err != nil {
-		panic(err)
-	}
-	log.Printf("successfully inserted %d vehicles into database", len(vehicles))
-	err = handler.InsertParts(vehicles)
+	defer handler.Close()
+	err := syncVehiclesToDatabase(handler, category, vehicles, failures, mirror)
 	if err != nil {
-		log.Fatalf("failed to insert parts to database %s", err)
+		log.Fatalf("failed to transfer vehicles to database %s", err)
 	}
 }
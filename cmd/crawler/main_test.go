@@ -15,6 +15,9 @@ func Test_extractYear(t *testing.T) {
 		{"Test year with text", args{"Suzuki 2019"}, 2019},
 		{"Test year with text and spaces", args{"Suzuki RX 2019"}, 2019},
 		{"Test year with text and spaces and other numbers", args{"Suzuki RX 203 2019"}, 2019},
+		{"Test year range", args{"Suzuki RX 2004-2006"}, 2004},
+		{"Test year after displacement that looks like a year", args{"Yamaha XV 1900 2008"}, 2008},
+		{"Test no year", args{"Suzuki RX"}, 0},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -41,10 +44,13 @@ func Test_extractBrand(t *testing.T) {
 		{"Test brand with two brands in the name", args{"Suzuki RX 203 2019 or Kawasaki XP"}, "Suzuki"},
 		{"Test multipart name with spaces as separators", args{"Harley Davidson RX203 2020"}, "Harley Davidson"},
 		{"Test multipart name with dashes as separators", args{"Harley-Davidson RX203 2020"}, "Harley Davidson"},
+		{"Test brand alias", args{"HD Sportster 883 2005"}, "Harley Davidson"},
+		{"Test brand alias with dash", args{"Can-Am Spyder 2015"}, "Can Am"},
+		{"Test unknown brand", args{"Foobar RX 2019"}, ""},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := extractBrand(tt.args.s, BrandReMatcher); got != tt.want {
+			if got := extractBrand(tt.args.s, ""); got != tt.want {
 				t.Errorf("extractBrand() = %v, want %v", got, tt.want)
 			}
 		})
@@ -66,31 +72,59 @@ func Test_extractModel(t *testing.T) {
 		{"Test model with spaces and with brand and year 2nd", args{"Polini XP4 50 2007"}, "XP4 50"},
 		{"Test model with spaces and with brand and year and other numbers", args{"Suzuki RX 3 2019 203"}, "RX 3"},
 		{"Test model with dashes in model name", args{"Suzuki RX-3 2019"}, "RX-3"},
+		{"Test model with brand alias", args{"HD Sportster 883 2005"}, "Sportster 883"},
+		{"Test model with multiword brand", args{"Moto Guzzi V7 Stone 2012"}, "V7 Stone"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := extractModel(tt.args.s); got != tt.want {
+			if got := extractModel(tt.args.s, ""); got != tt.want {
 				t.Errorf("extractModel() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func Test_generateHash(t *testing.T) {
-	type args struct {
-		vals []string
-	}
+func Test_parseVehicleName(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
-		want string
+		name        string
+		vehicleName string
+		want        vehicleName
 	}{
-		{"Test normal link", args{vals: []string{"https://www.purkuosat.net/apriliamx12504.htm", "Aprilia MX 125 2004"}}, "3673734910"},
+		{"Test plain name", "Suzuki RX 2019",
+			vehicleName{Brand: "Suzuki", Model: "RX", Year: 2019, YearFrom: 2019, YearTo: 2019}},
+		{"Test displacement in model", "Polini XP4 50 2007",
+			vehicleName{Brand: "Polini", Model: "XP4 50", Year: 2007, YearFrom: 2007, YearTo: 2007, Displacement: 50}},
+		{"Test year range", "Aprilia MX 125 2004-2006",
+			vehicleName{Brand: "Aprilia", Model: "MX 125", Year: 2004, YearFrom: 2004, YearTo: 2006, Displacement: 125}},
+		{"Test short year range", "Aprilia MX 125 2004-06",
+			vehicleName{Brand: "Aprilia", Model: "MX 125", Year: 2004, YearFrom: 2004, YearTo: 2006, Displacement: 125}},
+		{"Test spaced year range", "Aprilia MX 125 2004 - 2006",
+			vehicleName{Brand: "Aprilia", Model: "MX 125", Year: 2004, YearFrom: 2004, YearTo: 2006, Displacement: 125}},
+		{"Test backwards year range", "Aprilia MX 125 2006-2004",
+			vehicleName{Brand: "Aprilia", Model: "MX 125", Year: 2006, YearFrom: 2006, YearTo: 2006, Displacement: 125}},
+		{"Test displacement with unit", "Honda CR 125cc 2004",
+			vehicleName{Brand: "Honda", Model: "CR 125cc", Year: 2004, YearFrom: 2004, YearTo: 2004, Displacement: 125}},
+		{"Test displacement with unit after year", "Honda CR 2004 250 cc",
+			vehicleName{Brand: "Honda", Model: "CR", Year: 2004, YearFrom: 2004, YearTo: 2004, Displacement: 250}},
+		{"Test displacement that looks like a year", "Yamaha XV 1900 2008",
+			vehicleName{Brand: "Yamaha", Model: "XV 1900", Year: 2008, YearFrom: 2008, YearTo: 2008, Displacement: 1900}},
+		{"Test two stroke", "Derbi Senda 50 2T 2005",
+			vehicleName{Brand: "Derbi", Model: "Senda 50", Year: 2005, YearFrom: 2005, YearTo: 2005, Displacement: 50, Stroke: "2T"}},
+		{"Test four stroke in Finnish", "Yamaha WR 250 2010 4-tahti",
+			vehicleName{Brand: "Yamaha", Model: "WR 250", Year: 2010, YearFrom: 2010, YearTo: 2010, Displacement: 250, Stroke: "4T"}},
+		{"Test trim", "Kawasaki Z 750 2008 ABS",
+			vehicleName{Brand: "Kawasaki", Model: "Z 750", Year: 2008, YearFrom: 2008, YearTo: 2008, Displacement: 750, Trim: "ABS"}},
+		{"Test trim after year range and stroke", "KTM EXC 125 2004-2006 2T Six Days",
+			vehicleName{Brand: "KTM", Model: "EXC 125", Year: 2004, YearFrom: 2004, YearTo: 2006, Displacement: 125, Stroke: "2T", Trim: "Six Days"}},
+		{"Test no year", "Suzuki RX",
+			vehicleName{Brand: "Suzuki", Model: "RX"}},
+		{"Test unknown brand", "Foobar RX 125cc 2019",
+			vehicleName{Year: 2019, YearFrom: 2019, YearTo: 2019, Displacement: 125}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := generateHash(tt.args.vals...); got != tt.want {
-				t.Errorf("extractVehicleIdentifier() = %v, want %v", got, tt.want)
+			if got := parseVehicleName(tt.vehicleName, ""); got != tt.want {
+				t.Errorf("parseVehicleName() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"Crawler/internal/crawlqueue"
+	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Default sizes of the worker pools of the crawl pipeline.
+const (
+	defaultFetchWorkers = 10
+	defaultParseWorkers = 4
+)
+
+// The parsed vehicles are checkpointed into the crawl queue when this many
+// have been parsed or this much time has passed since the last checkpoint.
+const (
+	checkpointEvery    = 25
+	checkpointInterval = 10 * time.Second
+)
+
+// listingLink is a vehicle link discovered on a listing page. The index is the
+// position of the link on the page, it keeps the crawled vehicles in listing
+// order.
+type listingLink struct {
+	index int
+	name  string
+	url   string
+}
+
+// partPage is a fetched part page of a vehicle and the number of fetch
+// attempts it took.
+type partPage struct {
+	link     listingLink
+	response *colly.Response
+	attempts int
+}
+
+// crawledVehicle is a parsed vehicle with the position and URL of its listing
+// link and the issues found in its record.
+type crawledVehicle struct {
+	index   int
+	url     string
+	vehicle models.Vehicle
+	issues  []validationIssue
+}
+
+// discovery is the outcome of the discovery stage. The restored vehicles were
+// parsed by an earlier attempt of the run and are not crawled again.
+type discovery struct {
+	// links is the number of vehicle links on the listing page.
+	links    int
+	restored []crawledVehicle
+	err      error
+}
+
+// pipeline crawls a vehicle category in stages connected by channels: the
+// listing page is read for vehicle links, a pool of workers fetches the part
+// page of each vehicle, another pool parses the pages and a single consumer
+// collects the vehicles for persisting. Every stage owns its data, so nothing
+// is shared between the goroutines but the channels.
+type pipeline struct {
+	// collector is cloned for every stage and worker. The clones share its
+	// HTTP backend and limit rules.
+	collector *colly.Collector
+	// adapter knows the page layout of the crawled site.
+	adapter sites.SiteAdapter
+	retry   retryPolicy
+	// limiter limits the request rate of all stages, nil is unlimited.
+	limiter      *requestLimiter
+	fetchWorkers int
+	parseWorkers int
+}
+
+func newPipeline(collector *colly.Collector, adapter sites.SiteAdapter) *pipeline {
+	return &pipeline{collector: collector, adapter: adapter, retry: defaultRetryPolicy(), fetchWorkers: defaultFetchWorkers, parseWorkers: defaultParseWorkers}
+}
+
+// crawl crawls the listing page of a category and returns its valid vehicles
+// in listing order. Transient failures to fetch a part page are retried, the
+// vehicles whose part page still cannot be crawled are skipped and listed in
+// the failures of the report. The vehicles and parts that do not pass
+// validation are quarantined in the report.
+//
+// When run is not nil, the state of every part page URL is recorded in the
+// crawl queue and the parsed vehicles are checkpointed there. The URLs the run
+// has already parsed are not crawled again, so a resumed run continues where
+// the previous attempt stopped.
+func (p *pipeline) crawl(ctx context.Context, category string, listingURL string, run *crawlqueue.Run) ([]models.Vehicle, crawlReport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	report := crawlReport{Category: category, ListingURL: listingURL, StartedAt: time.Now().UTC(), Failures: []crawlFailure{}}
+	var entries []crawlqueue.Entry
+	if run != nil {
+		report.RunID = run.ID
+		var err error
+		if entries, err = run.Entries(); err != nil {
+			return nil, report, fmt.Errorf("cannot read the crawl queue: %w", err)
+		}
+	}
+
+	links := make(chan listingLink)
+	pages := make(chan partPage)
+	vehicles := make(chan crawledVehicle)
+	failures := make(chan crawlFailure)
+
+	discovered := make(chan discovery, 1)
+	go func() {
+		defer close(links)
+		discovered <- p.discover(ctx, listingURL, run, entries, links)
+	}()
+
+	var fetchers sync.WaitGroup
+	for i := 0; i < p.fetchWorkers; i++ {
+		fetchers.Add(1)
+		go func() {
+			defer fetchers.Done()
+			p.fetch(ctx, run, links, pages, failures)
+		}()
+	}
+	go func() {
+		fetchers.Wait()
+		close(pages)
+	}()
+
+	var parsers sync.WaitGroup
+	for i := 0; i < p.parseWorkers; i++ {
+		parsers.Add(1)
+		go func() {
+			defer parsers.Done()
+			p.parse(ctx, category, run, pages, vehicles, failures)
+		}()
+	}
+	go func() {
+		parsers.Wait()
+		close(vehicles)
+		close(failures)
+	}()
+
+	failed := make(chan []crawlFailure, 1)
+	go func() {
+		var all []crawlFailure
+		for failure := range failures {
+			all = append(all, failure)
+		}
+		failed <- all
+	}()
+
+	collected := collect(vehicles, run)
+	report.Failures = append(report.Failures, <-failed...)
+	sort.Slice(report.Failures, func(i, j int) bool { return report.Failures[i].URL < report.Failures[j].URL })
+	result := <-discovered
+	report.FinishedAt = time.Now().UTC()
+	if result.err != nil {
+		return nil, report, result.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, report, err
+	}
+	valid, quarantined, summary := validate(inListingOrderOf(append(result.restored, collected...)))
+	report.Links, report.Vehicles = result.links, len(valid)
+	report.Validation, report.quarantined = summary, quarantined
+	return inListingOrder(valid), report, nil
+}
+
+// discover sends the vehicle links of the listing page. A run whose listing
+// page was read completely before sends the links of its queue instead.
+// Links parsed by an earlier attempt of the run are restored from the queue.
+func (p *pipeline) discover(ctx context.Context, listingURL string, run *crawlqueue.Run, entries []crawlqueue.Entry, links chan<- listingLink) discovery {
+	var result discovery
+	known := make(map[string]crawlqueue.Entry, len(entries))
+	for _, entry := range entries {
+		known[entry.URL] = entry
+	}
+	send := func(link listingLink) {
+		result.links++
+		if entry, exists := known[link.url]; exists && entry.State == crawlqueue.StateParsed && entry.Vehicle != nil {
+			// The raw prices of a restored vehicle are not known any more.
+			issues := checkVehicle(*entry.Vehicle, nil, p.adapter, time.Now())
+			result.restored = append(result.restored, crawledVehicle{index: link.index, url: link.url, vehicle: *entry.Vehicle, issues: issues})
+			return
+		}
+		select {
+		case links <- link:
+		case <-ctx.Done():
+		}
+	}
+
+	if run != nil && run.Discovered {
+		log.Printf("Resuming the crawl of %s from the queue.", listingURL)
+		for _, entry := range entries {
+			send(listingLink{index: entry.Index, name: entry.Name, url: entry.URL})
+		}
+		return result
+	}
+
+	c := p.collector.Clone()
+	configureDefaultHandlers(c, p.limiter)
+	index := 0
+	c.OnHTML(p.adapter.ListingSelector(), func(e *colly.HTMLElement) {
+		name, url, ok := p.adapter.VehicleLink(e)
+		if !ok || result.err != nil {
+			return
+		}
+		link := listingLink{index: index, name: name, url: url}
+		index++
+		if run != nil {
+			if result.err = run.Enqueue(link.index, link.name, link.url); result.err != nil {
+				return
+			}
+		}
+		send(link)
+	})
+	if err := c.Visit(listingURL); err != nil {
+		result.err = err
+	}
+	if result.err == nil && run != nil {
+		result.err = run.MarkDiscovered()
+	}
+	return result
+}
+
+// fetch visits the part pages of the links until the links are exhausted.
+// Transient failures are retried as the retry policy allows. The pages that
+// cannot be fetched are recorded in the queue of the run, the fetched pages
+// are recorded by parse once their outcome is known, so that their attempts
+// are counted once.
+func (p *pipeline) fetch(ctx context.Context, run *crawlqueue.Run, links <-chan listingLink, pages chan<- partPage, failures chan<- crawlFailure) {
+	c := p.collector.Clone()
+	configureDefaultHandlers(c, p.limiter)
+	var response, failed *colly.Response
+	c.OnResponse(func(r *colly.Response) {
+		response = r
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		failed = r
+	})
+	for link := range links {
+		if ctx.Err() != nil {
+			continue
+		}
+		var err error
+		attempts := 0
+		for {
+			attempts++
+			response, failed = nil, nil
+			log.Println("Part collector visiting page:", link.url)
+			if err = c.Visit(link.url); err == nil {
+				break
+			}
+			delay, retry := p.retry.next(attempts, failed, err)
+			if !retry {
+				break
+			}
+			log.Printf("Retrying the part page %s in %s. Reason: %s\n", link.url, delay, err)
+			if sleepContext(ctx, delay) != nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("Cannot visit the part page: %s. Reason: %s\n", link.url, err)
+			p.record(run, link.url, attempts, err)
+			failure := crawlFailure{URL: link.url, Vehicle: link.name, Attempts: attempts, Error: err.Error()}
+			if failed != nil {
+				failure.StatusCode = failed.StatusCode
+			}
+			select {
+			case failures <- failure:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if response == nil {
+			continue
+		}
+		select {
+		case pages <- partPage{link: link, response: response, attempts: attempts}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// record records the outcome of an attempt to crawl the URL in the queue of
+// the run. A failure to record it only costs progress on resume, so it is
+// logged and the crawl goes on.
+func (p *pipeline) record(run *crawlqueue.Run, url string, attempts int, cause error) {
+	if run == nil {
+		return
+	}
+	var err error
+	if cause != nil {
+		err = run.MarkFailed(url, attempts, cause)
+	} else {
+		err = run.MarkFetched(url, attempts)
+	}
+	if err != nil {
+		log.Printf("Cannot record the state of %s in the crawl queue. Reason: %s\n", url, err)
+	}
+}
+
+// parse turns the fetched part pages into vehicles of the category. The
+// outcome of each page is recorded in the queue of the run with the fetch
+// attempts of the page.
+func (p *pipeline) parse(ctx context.Context, category string, run *crawlqueue.Run, pages <-chan partPage, vehicles chan<- crawledVehicle, failures chan<- crawlFailure) {
+	for page := range pages {
+		parts, err := parsePartPage(p.adapter, page.response)
+		if err != nil {
+			log.Printf("Cannot parse the part page: %s. Reason: %s\n", page.link.url, err)
+			p.record(run, page.link.url, page.attempts, err)
+			select {
+			case failures <- crawlFailure{URL: page.link.url, Vehicle: page.link.name, Attempts: page.attempts, Error: err.Error()}:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		p.record(run, page.link.url, page.attempts, nil)
+		rawVehicle := models.RawVehicle{Name: page.link.name, Url: page.link.url, RawParts: parts}
+		vehicle := processRawVehicle(rawVehicle, category, p.adapter)
+		issues := checkVehicle(vehicle, &rawVehicle, p.adapter, time.Now())
+		select {
+		case vehicles <- crawledVehicle{index: page.link.index, url: page.link.url, vehicle: vehicle, issues: issues}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// parsePartPage reads the parts of a part page with the selectors of the site.
+func parsePartPage(adapter sites.SiteAdapter, r *colly.Response) ([]models.RawPart, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	var parts []models.RawPart
+	doc.Find(adapter.PartSelector()).Each(func(i int, s *goquery.Selection) {
+		if part, ok := adapter.Part(colly.NewHTMLElementFromSelectionNode(r, s, s.Nodes[0], i)); ok {
+			parts = append(parts, part)
+		}
+	})
+	return parts, nil
+}
+
+// collect gathers the parsed vehicles and checkpoints them into the queue of
+// the run. A failed checkpoint is retried with the next one.
+func collect(crawled <-chan crawledVehicle, run *crawlqueue.Run) []crawledVehicle {
+	var collected []crawledVehicle
+	unsaved := make(map[string]models.Vehicle)
+	checkpoint := func() {
+		if run == nil || len(unsaved) == 0 {
+			return
+		}
+		if err := run.Checkpoint(unsaved); err != nil {
+			log.Printf("Cannot checkpoint %d vehicles. Reason: %s\n", len(unsaved), err)
+			return
+		}
+		unsaved = make(map[string]models.Vehicle)
+	}
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case vehicle, ok := <-crawled:
+			if !ok {
+				checkpoint()
+				return collected
+			}
+			collected = append(collected, vehicle)
+			unsaved[vehicle.url] = vehicle.vehicle
+			if len(unsaved) >= checkpointEvery {
+				checkpoint()
+			}
+		case <-ticker.C:
+			checkpoint()
+		}
+	}
+}
+
+// inListingOrderOf orders the crawled vehicles as they were listed.
+func inListingOrderOf(crawled []crawledVehicle) []crawledVehicle {
+	sort.Slice(crawled, func(i, j int) bool { return crawled[i].index < crawled[j].index })
+	return crawled
+}
+
+// inListingOrder orders the vehicles as they were listed.
+func inListingOrder(ordered []crawledVehicle) []models.Vehicle {
+	inListingOrderOf(ordered)
+	vehicles := make([]models.Vehicle, len(ordered))
+	for i, crawled := range ordered {
+		vehicles[i] = crawled.vehicle
+	}
+	return vehicles
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"Crawler/internal/crawlqueue"
+	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// localSite is the purkuosat adapter for a local server.
+type localSite struct {
+	sites.Purkuosat
+	host string
+}
+
+func (s localSite) AllowedDomains() []string {
+	return []string{s.host}
+}
+
+// newTestPipeline returns a pipeline crawling a local server that serves the
+// purkuosat-style pages of testdata/site.
+func newTestPipeline(t *testing.T) (*pipeline, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata/site")))
+	t.Cleanup(server.Close)
+	serverURL, _ := url.Parse(server.URL)
+	adapter := localSite{host: serverURL.Hostname()}
+	c, err := createCollector(adapter, defaultPolicy(adapter))
+	if err != nil {
+		t.Fatalf("createCollector() error = %v", err)
+	}
+	p := newPipeline(c, adapter)
+	p.fetchWorkers, p.parseWorkers = 3, 2
+	p.retry.baseDelay = time.Millisecond
+	return p, server
+}
+
+func Test_pipeline_crawl(t *testing.T) {
+	p, server := newTestPipeline(t)
+	vehicles, report, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", nil)
+	if err != nil {
+		t.Fatalf("crawl() error = %v", err)
+	}
+	if report.Links != 4 || report.Vehicles != 3 || len(report.Failures) != 1 || report.Failures[0].StatusCode != http.StatusNotFound || report.Failures[0].Attempts != 1 {
+		t.Errorf("crawl() report = %+v, want the missing page failed once", report)
+	}
+	if report.Validation.Vehicles != 3 || report.Validation.Parts != 3 || len(report.quarantined) != 0 {
+		t.Errorf("crawl() validation = %+v, want 3 valid vehicles", report.Validation)
+	}
+
+	// The sold vehicle has no part page and is skipped.
+	want := []models.Vehicle{
+		processRawVehicle(models.RawVehicle{Name: "Suzuki RX 2019", Url: server.URL + "/suzukirx19.htm", RawParts: []models.RawPart{
+			{Name: "Takarengas", Description: "Hyvä kunto", PartIdentifier: "2001", Price: "20 €", ImgUrl: server.URL + "/kuvat/2001.jpg", ImgThumbUrl: server.URL + "/kuvat/2001_t.jpg"},
+			{Name: "Etulokasuoja", Description: "Naarmuja", PartIdentifier: "2002", Price: "15.50 €", ImgUrl: server.URL + "/kuvat/2002.jpg", ImgThumbUrl: server.URL + "/kuvat/2002_t.jpg"},
+		}}, "moped", sites.Purkuosat{}),
+		processRawVehicle(models.RawVehicle{Name: "Polini XP4 50 2007", Url: server.URL + "/polinixp450.htm"}, "moped", sites.Purkuosat{}),
+		processRawVehicle(models.RawVehicle{Name: "Suzuki RX 2017", Url: server.URL + "/suzukirx17.htm", RawParts: []models.RawPart{
+			{Name: "Satula", PartIdentifier: "2003", Price: "30 €", ImgUrl: server.URL + "/kuvat/2003.jpg", ImgThumbUrl: server.URL + "/kuvat/2003_t.jpg"},
+		}}, "moped", sites.Purkuosat{}),
+	}
+	if !reflect.DeepEqual(vehicles, want) {
+		t.Errorf("crawl() = %+v, want %+v", vehicles, want)
+	}
+	if vehicles[0].Brand != "Suzuki" || vehicles[0].Year != 2019 || vehicles[0].Parts[1].Price != euros(15.5) {
+		t.Errorf("crawl() parsed %+v", vehicles[0])
+	}
+}
+
+func Test_pipeline_crawl_errors(t *testing.T) {
+	p, server := newTestPipeline(t)
+	if _, _, err := p.crawl(context.Background(), "moped", server.URL+"/missing.htm", nil); err == nil {
+		t.Errorf("crawl() of a missing listing page should fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := p.crawl(ctx, "moped", server.URL+"/index.htm", nil); err == nil {
+		t.Errorf("crawl() with a cancelled context should fail")
+	}
+}
+
+func Test_pipeline_crawl_replay(t *testing.T) {
+	p, server := newTestPipeline(t)
+	archiveDir := t.TempDir()
+	if err := configureTransport(p.collector, modeRecord, archiveDir); err != nil {
+		t.Fatalf("configureTransport() error = %v", err)
+	}
+	recorded, _, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", nil)
+	if err != nil {
+		t.Fatalf("crawl() while recording error = %v", err)
+	}
+	server.Close()
+
+	// The site is gone, the pages come from the archive.
+	if err := configureTransport(p.collector, modeReplay, archiveDir); err != nil {
+		t.Fatalf("configureTransport() error = %v", err)
+	}
+	replayed, _, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", nil)
+	if err != nil {
+		t.Fatalf("crawl() while replaying error = %v", err)
+	}
+	if len(replayed) != 3 || !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed crawl = %+v, want the recorded %+v", replayed, recorded)
+	}
+
+	if err := configureTransport(p.collector, "offline", archiveDir); err == nil {
+		t.Errorf("configureTransport() with an unknown mode should fail")
+	}
+}
+
+func Test_pipeline_crawl_resume(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	down := true
+	files := http.FileServer(http.Dir("testdata/site"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		failing := down && r.URL.Path == "/suzukirx17.htm"
+		mu.Unlock()
+		if failing {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	adapter := localSite{host: serverURL.Hostname()}
+	c, _ := createCollector(adapter, defaultPolicy(adapter))
+	p := newPipeline(c, adapter)
+	p.retry.baseDelay = time.Millisecond
+
+	queue, err := crawlqueue.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("crawlqueue.Open() error = %v", err)
+	}
+	defer queue.Close()
+	run, _ := queue.Start("moped", server.URL+"/index.htm")
+	if _, _, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", run); err != nil {
+		t.Fatalf("first crawl() error = %v", err)
+	}
+
+	// The crawler died before the run finished. The resumed run crawls only
+	// the pages that were not parsed.
+	mu.Lock()
+	down = false
+	requests = make(map[string]int)
+	mu.Unlock()
+	resumed, err := queue.Resume("moped", server.URL+"/index.htm")
+	if err != nil || resumed.ID != run.ID {
+		t.Fatalf("Resume() = %+v, %v, want run %d", resumed, err, run.ID)
+	}
+	vehicles, _, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", resumed)
+	if err != nil {
+		t.Fatalf("resumed crawl() error = %v", err)
+	}
+	mu.Lock()
+	if want := map[string]int{"/suzukirx17.htm": 1, "/myytyrx.htm": 1}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("resumed crawl requested %v, want %v", requests, want)
+	}
+	mu.Unlock()
+
+	fresh, _, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", nil)
+	if err != nil {
+		t.Fatalf("fresh crawl() error = %v", err)
+	}
+	if len(vehicles) != 3 || !reflect.DeepEqual(vehicles, fresh) {
+		t.Errorf("resumed crawl = %+v, want %+v", vehicles, fresh)
+	}
+}
+
+func Test_pipeline_crawl_retry(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	files := http.FileServer(http.Dir("testdata/site"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		attempt := requests[r.URL.Path]
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/suzukirx19.htm" && attempt <= 2:
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		case r.URL.Path == "/polinixp450.htm" && attempt == 1:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		case r.URL.Path == "/suzukirx17.htm":
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	adapter := localSite{host: serverURL.Hostname()}
+	c, _ := createCollector(adapter, defaultPolicy(adapter))
+	p := newPipeline(c, adapter)
+	p.retry = retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: 10 * time.Millisecond}
+
+	queue, err := crawlqueue.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("crawlqueue.Open() error = %v", err)
+	}
+	defer queue.Close()
+	run, _ := queue.Start("moped", server.URL+"/index.htm")
+	vehicles, report, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", run)
+	if err != nil {
+		t.Fatalf("crawl() error = %v", err)
+	}
+	if len(vehicles) != 2 || vehicles[0].Name != "Suzuki RX 2019" || vehicles[1].Name != "Polini XP4 50 2007" {
+		t.Errorf("crawl() = %+v, want the retried vehicles", vehicles)
+	}
+	mu.Lock()
+	// The missing page is not retried.
+	if want := map[string]int{"/robots.txt": 1, "/index.htm": 1, "/suzukirx19.htm": 3, "/polinixp450.htm": 2, "/suzukirx17.htm": 3, "/myytyrx.htm": 1}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("crawl() requested %v, want %v", requests, want)
+	}
+	mu.Unlock()
+
+	want := []crawlFailure{
+		{URL: server.URL + "/myytyrx.htm", Vehicle: "Suzuki RX 2016", Attempts: 1, StatusCode: http.StatusNotFound, Error: "Not Found"},
+		{URL: server.URL + "/suzukirx17.htm", Vehicle: "Suzuki RX 2017", Attempts: 3, StatusCode: http.StatusInternalServerError, Error: "Internal Server Error"},
+	}
+	if !reflect.DeepEqual(report.Failures, want) {
+		t.Errorf("crawl() failures = %+v, want %+v", report.Failures, want)
+	}
+	if report.Links != 4 || report.Vehicles != 2 || report.failureRate() != 0.5 {
+		t.Errorf("crawl() report = %+v, want 2 of 4 links failed", report)
+	}
+
+	// The queue counts every fetch attempt once.
+	entries, err := run.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	attempts := make(map[string]int)
+	for _, entry := range entries {
+		attempts[strings.TrimPrefix(entry.URL, server.URL)] = entry.Attempts
+	}
+	if want := map[string]int{"/suzukirx19.htm": 3, "/polinixp450.htm": 2, "/suzukirx17.htm": 3, "/myytyrx.htm": 1}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf("queue attempts = %v, want %v", attempts, want)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"Crawler/internal/sites"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/viper"
+	"github.com/temoto/robotstxt"
+)
+
+// defaultUserAgent identifies the crawler and tells the site owners where to
+// find out about it.
+const defaultUserAgent = "MotoPartBrowser-crawler/1.0 (+https://github.com/OscarGTH/MotoPartBrowser-go)"
+
+// robotsTimeout bounds the fetch of a robots.txt file.
+const robotsTimeout = 30 * time.Second
+
+// politenessPolicy is how considerately a site is crawled. The defaults come
+// from the limit rule of the site adapter and are overridden by the
+// politeness.<site> block of the config.
+type politenessPolicy struct {
+	UserAgent   string
+	Parallelism int
+	// Delay is waited between the requests to the site, RandomDelay at most on top.
+	Delay       time.Duration
+	RandomDelay time.Duration
+	// MaxRequestsPerMinute limits the request rate over all workers, zero is unlimited.
+	MaxRequestsPerMinute int
+	// ObeyRobots skips the pages disallowed by the robots.txt of the site.
+	ObeyRobots bool
+	// HonorCrawlDelay raises Delay to the Crawl-delay of the robots.txt.
+	HonorCrawlDelay bool
+	domainGlob      string
+}
+
+// defaultPolicy returns the politeness policy of the site before configuration.
+func defaultPolicy(adapter sites.SiteAdapter) politenessPolicy {
+	rule := adapter.LimitRule()
+	return politenessPolicy{
+		UserAgent:       defaultUserAgent,
+		Parallelism:     rule.Parallelism,
+		Delay:           rule.Delay,
+		RandomDelay:     rule.RandomDelay,
+		ObeyRobots:      true,
+		HonorCrawlDelay: true,
+		domainGlob:      rule.DomainGlob,
+	}
+}
+
+// configurePolicy reads the politeness policy of the site from the config.
+// Unset values keep their defaults.
+func configurePolicy(adapter sites.SiteAdapter) politenessPolicy {
+	policy := defaultPolicy(adapter)
+	config := viper.Sub("politeness." + adapter.Name())
+	if config == nil {
+		return policy
+	}
+	if config.IsSet("user_agent") {
+		policy.UserAgent = config.GetString("user_agent")
+	}
+	if config.IsSet("parallelism") {
+		policy.Parallelism = max(config.GetInt("parallelism"), 1)
+	}
+	if config.IsSet("delay") {
+		policy.Delay = config.GetDuration("delay")
+	}
+	if config.IsSet("random_delay") {
+		policy.RandomDelay = config.GetDuration("random_delay")
+	}
+	if config.IsSet("max_requests_per_minute") {
+		policy.MaxRequestsPerMinute = max(config.GetInt("max_requests_per_minute"), 0)
+	}
+	if config.IsSet("obey_robots") {
+		policy.ObeyRobots = config.GetBool("obey_robots")
+	}
+	if config.IsSet("honor_crawl_delay") {
+		policy.HonorCrawlDelay = config.GetBool("honor_crawl_delay")
+	}
+	return policy
+}
+
+func (policy politenessPolicy) String() string {
+	rate := "unlimited"
+	if policy.MaxRequestsPerMinute > 0 {
+		rate = fmt.Sprintf("%d/min", policy.MaxRequestsPerMinute)
+	}
+	return fmt.Sprintf("user agent %q, parallelism %d, delay %s + %s random, rate %s, obey robots.txt %t, honor crawl-delay %t",
+		policy.UserAgent, policy.Parallelism, policy.Delay, policy.RandomDelay, rate, policy.ObeyRobots, policy.HonorCrawlDelay)
+}
+
+// limitRule is the colly limit rule enforcing the parallelism and delays.
+func (policy politenessPolicy) limitRule() *colly.LimitRule {
+	return &colly.LimitRule{
+		DomainGlob:  policy.domainGlob,
+		Parallelism: policy.Parallelism,
+		Delay:       policy.Delay,
+		RandomDelay: policy.RandomDelay,
+	}
+}
+
+// applyCrawlDelay raises the delay of the policy to the Crawl-delay that the
+// robots.txt of the host of pageURL sets for the user agent. A missing
+// robots.txt sets no delay.
+func (policy *politenessPolicy) applyCrawlDelay(client *http.Client, pageURL string) error {
+	if !policy.HonorCrawlDelay {
+		return nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, u.Scheme+"://"+u.Host+"/robots.txt", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", policy.UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	robots, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return err
+	}
+	if group := robots.FindGroup(policy.UserAgent); group != nil && group.CrawlDelay > policy.Delay {
+		log.Printf("Raising the delay between requests to %s to the crawl-delay of %s.", u.Host, group.CrawlDelay)
+		policy.Delay = group.CrawlDelay
+	}
+	return nil
+}
+
+// requestLimiter spaces the requests of all workers evenly to keep the request
+// rate under a limit. A nil limiter does not limit.
+type requestLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// newRequestLimiter returns a limiter of the requests per minute, nil when the
+// rate is unlimited.
+func newRequestLimiter(perMinute int) *requestLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &requestLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until the next request may be sent.
+func (l *requestLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/viper"
+)
+
+func Test_configurePolicy(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	adapter := localSite{host: "127.0.0.1"}
+	if got, want := configurePolicy(adapter), defaultPolicy(adapter); got != want {
+		t.Errorf("configurePolicy() without config = %+v, want %+v", got, want)
+	}
+
+	viper.Set("politeness.purkuosat", map[string]any{
+		"user_agent":              "TestBot/1.0 (+mailto:bot@example.com)",
+		"parallelism":             2,
+		"delay":                   "2s",
+		"max_requests_per_minute": 30,
+		"obey_robots":             false,
+	})
+	want := defaultPolicy(adapter)
+	want.UserAgent = "TestBot/1.0 (+mailto:bot@example.com)"
+	want.Parallelism, want.Delay, want.MaxRequestsPerMinute, want.ObeyRobots = 2, 2*time.Second, 30, false
+	if got := configurePolicy(adapter); got != want {
+		t.Errorf("configurePolicy() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_pipeline_crawl_politeness(t *testing.T) {
+	const userAgent = "TestBot/1.0 (+mailto:bot@example.com)"
+	var mu sync.Mutex
+	var visits []time.Time
+	var agents []string
+	files := http.FileServer(http.Dir("testdata/site"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /suzukirx17.htm\nCrawl-delay: 0.05\n"))
+			return
+		}
+		mu.Lock()
+		visits = append(visits, time.Now())
+		agents = append(agents, r.UserAgent())
+		mu.Unlock()
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	adapter := localSite{host: serverURL.Hostname()}
+
+	policy := defaultPolicy(adapter)
+	policy.UserAgent, policy.Parallelism, policy.RandomDelay = userAgent, 1, 0
+	policy.MaxRequestsPerMinute = 1200
+	// The limit rule of purkuosat does not match the local server.
+	policy.domainGlob = "*"
+	if err := policy.applyCrawlDelay(server.Client(), server.URL+"/index.htm"); err != nil {
+		t.Fatalf("applyCrawlDelay() error = %v", err)
+	}
+	if policy.Delay != 50*time.Millisecond {
+		t.Errorf("applyCrawlDelay() delay = %v, want the crawl-delay 50ms", policy.Delay)
+	}
+	c, err := createCollector(adapter, policy)
+	if err != nil {
+		t.Fatalf("createCollector() error = %v", err)
+	}
+	p := newPipeline(c, adapter)
+	p.fetchWorkers, p.parseWorkers = 3, 2
+	p.limiter = newRequestLimiter(policy.MaxRequestsPerMinute)
+
+	vehicles, report, err := p.crawl(context.Background(), "moped", server.URL+"/index.htm", nil)
+	if err != nil {
+		t.Fatalf("crawl() error = %v", err)
+	}
+	if len(vehicles) != 2 {
+		t.Errorf("crawl() = %+v, want the 2 vehicles allowed by robots.txt", vehicles)
+	}
+	blocked := false
+	for _, failure := range report.Failures {
+		if failure.URL == server.URL+"/suzukirx17.htm" {
+			blocked = failure.Attempts == 1 && failure.Error == colly.ErrRobotsTxtBlocked.Error()
+		}
+	}
+	if !blocked {
+		t.Errorf("crawl() failures = %+v, want the disallowed page blocked", report.Failures)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The listing and the three allowed part pages.
+	if len(visits) != 4 {
+		t.Fatalf("crawl() sent %d requests, want 4", len(visits))
+	}
+	for i, agent := range agents {
+		if agent != userAgent {
+			t.Errorf("request %d user agent = %q, want %q", i, agent, userAgent)
+		}
+	}
+	// Allow for the scheduling of the server.
+	minGap := policy.Delay * 4 / 5
+	for i := 1; i < len(visits); i++ {
+		if gap := visits[i].Sub(visits[i-1]); gap < minGap {
+			t.Errorf("request %d was sent %v after the previous one, want at least %v", i, gap, policy.Delay)
+		}
+	}
+}
+
+func Test_requestLimiter(t *testing.T) {
+	if limiter := newRequestLimiter(0); limiter != nil {
+		t.Errorf("newRequestLimiter(0) = %+v, want unlimited", limiter)
+	}
+	limiter := newRequestLimiter(6000)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.wait()
+		}()
+	}
+	wg.Wait()
+	// Five requests at 10ms intervals take at least 40ms.
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 waits took %v, want at least 40ms", elapsed)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// crawlFailure is a part page that could not be crawled.
+type crawlFailure struct {
+	URL     string `json:"url"`
+	Vehicle string `json:"vehicle"`
+	// Attempts is the number of fetch attempts made.
+	Attempts int `json:"attempts"`
+	// StatusCode is the HTTP status of the last attempt, zero when no response was received.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error"`
+}
+
+// crawlReport summarises the crawl of a category.
+type crawlReport struct {
+	Category   string    `json:"category"`
+	ListingURL string    `json:"listing_url"`
+	RunID      int64     `json:"run_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Links is the number of vehicle links on the listing page.
+	Links    int            `json:"links"`
+	Vehicles int            `json:"vehicles"`
+	Failures []crawlFailure `json:"failures"`
+	// Validation counts the issues of the crawled vehicles.
+	Validation validationSummary `json:"validation"`
+	// quarantined are the records kept from the database, they are written
+	// into a file of their own.
+	quarantined []quarantineRecord
+}
+
+// failureRate is the share of the vehicle links whose part page failed.
+func (report crawlReport) failureRate() float64 {
+	if report.Links == 0 {
+		return 0
+	}
+	return float64(len(report.Failures)) / float64(report.Links)
+}
+
+// writeReport dumps the report to an indented JSON file.
+func writeReport(fName string, report crawlReport) error {
+	payload, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fName, append(payload, '\n'), 0o644)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Defaults of the retry policy of part page fetches.
+const (
+	defaultMaxAttempts = 4
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 30 * time.Second
+)
+
+// retryPolicy decides whether a failed fetch is retried and how long to wait
+// before the next attempt. The delay doubles with every attempt and is
+// jittered, so that the workers do not retry in lockstep.
+type retryPolicy struct {
+	// maxAttempts is the number of attempts including the first one.
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{maxAttempts: defaultMaxAttempts, baseDelay: defaultBaseDelay, maxDelay: defaultMaxDelay}
+}
+
+// next returns the delay before the attempt following the failed attempt
+// number attempt, and false when the fetch is not retried. Only transient
+// errors are retried: server errors, rate limiting and network timeouts. The
+// Retry-After header of a response is honoured up to the maximum delay.
+func (policy retryPolicy) next(attempt int, resp *colly.Response, err error) (time.Duration, bool) {
+	if attempt >= policy.maxAttempts || !isTransient(resp, err) {
+		return 0, false
+	}
+	delay := policy.baseDelay
+	for i := 1; i < attempt && delay < policy.maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, policy.maxDelay)
+	// Half of the delay is random.
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Headers); ok && retryAfter > delay {
+			delay = min(retryAfter, policy.maxDelay)
+		}
+	}
+	return delay, true
+}
+
+// isTransient tells whether a failed fetch may succeed when tried again.
+func isTransient(resp *colly.Response, err error) bool {
+	if resp != nil && resp.StatusCode != 0 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseRetryAfter reads the Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(header *http.Header) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits for the delay unless the context is done first.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
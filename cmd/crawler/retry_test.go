@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/viper"
+)
+
+func Test_retryPolicy_next(t *testing.T) {
+	policy := retryPolicy{maxAttempts: 5, baseDelay: 100 * time.Millisecond, maxDelay: 300 * time.Millisecond}
+	status := func(code int, header http.Header) *colly.Response {
+		return &colly.Response{StatusCode: code, Headers: &header}
+	}
+	tests := []struct {
+		name      string
+		attempt   int
+		resp      *colly.Response
+		err       error
+		wantRetry bool
+		wantMin   time.Duration
+		wantMax   time.Duration
+	}{
+		{"first retry", 1, status(http.StatusServiceUnavailable, nil), errors.New("Service Unavailable"), true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubled delay", 2, status(http.StatusBadGateway, nil), errors.New("Bad Gateway"), true, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"capped delay", 4, status(http.StatusInternalServerError, nil), errors.New("Internal Server Error"), true, 150 * time.Millisecond, 300 * time.Millisecond},
+		{"attempts exhausted", 5, status(http.StatusInternalServerError, nil), errors.New("Internal Server Error"), false, 0, 0},
+		{"retry after", 1, status(http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}), errors.New("Too Many Requests"), true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"longer retry after", 1, status(http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}}), errors.New("Too Many Requests"), true, 300 * time.Millisecond, 300 * time.Millisecond},
+		{"not found", 1, status(http.StatusNotFound, nil), errors.New("Not Found"), false, 0, 0},
+		{"timeout", 1, nil, context.DeadlineExceeded, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"connection reset", 1, status(0, nil), syscall.ECONNRESET, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"forbidden domain", 1, nil, colly.ErrForbiddenDomain, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				delay, retry := policy.next(tt.attempt, tt.resp, tt.err)
+				if retry != tt.wantRetry || delay < tt.wantMin || delay > tt.wantMax {
+					t.Fatalf("next() = %v, %v, want %v in [%v, %v]", delay, retry, tt.wantRetry, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func Test_configureRetry(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	tests := []struct {
+		name      string
+		baseDelay string
+		maxDelay  string
+		want      retryPolicy
+	}{
+		{"configured", "1s", "10s", retryPolicy{maxAttempts: defaultMaxAttempts, baseDelay: time.Second, maxDelay: 10 * time.Second}},
+		{"negative base delay", "-1s", "10s", retryPolicy{maxAttempts: defaultMaxAttempts, baseDelay: defaultBaseDelay, maxDelay: 10 * time.Second}},
+		{"zero delays", "0s", "0s", defaultRetryPolicy()},
+		{"negative max delay", "1s", "-5s", retryPolicy{maxAttempts: defaultMaxAttempts, baseDelay: time.Second, maxDelay: defaultMaxDelay}},
+		{"max delay below base delay", "2s", "1s", retryPolicy{maxAttempts: defaultMaxAttempts, baseDelay: 2 * time.Second, maxDelay: 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("retry.base_delay", tt.baseDelay)
+			viper.Set("retry.max_delay", tt.maxDelay)
+			policy := configureRetry()
+			if policy != tt.want {
+				t.Fatalf("configureRetry() = %+v, want %+v", policy, tt.want)
+			}
+			// The delays of the policy must not make next panic.
+			if _, retry := policy.next(1, nil, context.DeadlineExceeded); !retry {
+				t.Errorf("next() did not retry a timeout")
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"seconds", "120", 2 * time.Minute, true},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"missing", "", 0, false},
+		{"malformed", "soon", 0, false},
+		{"negative", "-1", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+			got, ok := parseRetryAfter(&header)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
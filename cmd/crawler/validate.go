@@ -0,0 +1,210 @@
+package main
+
+import (
+	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Codes of the issues found in scraped records.
+const (
+	issueMissingBrand     = "missing_brand"
+	issueMissingModel     = "missing_model"
+	issueUnknownYear      = "unknown_year"
+	issueUnparseablePrice = "unparseable_price"
+	issueEmptyPartName    = "empty_part_name"
+	issueDuplicateVehicle = "duplicate_vehicle_id"
+	issueDuplicatePart    = "duplicate_part_id"
+	// issueIdentifierCollision is an identifier shared by different listings or parts.
+	issueIdentifierCollision = "id_collision"
+)
+
+// firstVehicleYear is the earliest plausible model year of a vehicle.
+const firstVehicleYear = 1885
+
+// validationIssue is a problem with a scraped vehicle or one of its parts.
+// PartID is empty for the issues of the vehicle itself.
+type validationIssue struct {
+	Code   string `json:"code"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	PartID string `json:"part_id,omitempty"`
+}
+
+// isPartIssue tells whether the issue quarantines only a part of the vehicle.
+func (issue validationIssue) isPartIssue() bool {
+	return issue.PartID != ""
+}
+
+// quarantineRecord is a vehicle, or a part of it, kept from the database
+// because of its issues.
+type quarantineRecord struct {
+	VehicleID string `json:"vehicle_id"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	// Part is set when only the part is quarantined.
+	Part   *models.Part      `json:"part,omitempty"`
+	Issues []validationIssue `json:"issues"`
+}
+
+// validationSummary counts the scraped and quarantined records of a category
+// and their issues by code.
+type validationSummary struct {
+	Vehicles            int            `json:"vehicles"`
+	Parts               int            `json:"parts"`
+	QuarantinedVehicles int            `json:"quarantined_vehicles"`
+	QuarantinedParts    int            `json:"quarantined_parts"`
+	Issues              map[string]int `json:"issues"`
+}
+
+// checkVehicle returns the issues of a processed vehicle. The prices are
+// checked against the raw vehicle it was processed from, when it is known.
+func checkVehicle(vehicle models.Vehicle, raw *models.RawVehicle, adapter sites.SiteAdapter, now time.Time) []validationIssue {
+	var issues []validationIssue
+	if vehicle.Brand == "" {
+		issues = append(issues, validationIssue{Code: issueMissingBrand, Field: "brand", Value: vehicle.Name})
+	} else if vehicle.Model == "" {
+		issues = append(issues, validationIssue{Code: issueMissingModel, Field: "model", Value: vehicle.Name})
+	}
+	if vehicle.Year < firstVehicleYear || vehicle.Year > now.Year()+1 {
+		issues = append(issues, validationIssue{Code: issueUnknownYear, Field: "year", Value: strconv.Itoa(vehicle.Year)})
+	}
+	for i, part := range vehicle.Parts {
+		if part.Name == "" {
+			issues = append(issues, validationIssue{Code: issueEmptyPartName, Field: "name", PartID: part.PartIdentifier})
+		}
+		if raw == nil || i >= len(raw.RawParts) {
+			continue
+		}
+		if _, err := adapter.ParsePrice(raw.RawParts[i].Price); err != nil {
+			issues = append(issues, validationIssue{Code: issueUnparseablePrice, Field: "price", Value: raw.RawParts[i].Price, PartID: part.PartIdentifier})
+		}
+	}
+	return issues
+}
+
+// validate splits the checked vehicles of a category into the ones that may
+// be stored and the quarantined records. A vehicle with issues of its own is
+// quarantined whole, a part with issues is removed from its vehicle. Vehicles
+// and parts whose identifier was already seen in the category are duplicates
+// when they come from the same listing or part number, and collisions when not.
+func validate(crawled []crawledVehicle) ([]crawledVehicle, []quarantineRecord, validationSummary) {
+	summary := validationSummary{Issues: make(map[string]int)}
+	var valid []crawledVehicle
+	var quarantined []quarantineRecord
+	// The listings and part numbers the seen identifiers were generated from.
+	seenVehicles := make(map[string]string)
+	seenParts := make(map[string]string)
+	for _, checked := range crawled {
+		vehicle := checked.vehicle
+		summary.Vehicles++
+		summary.Parts += len(vehicle.Parts)
+		record := func(part *models.Part, issues []validationIssue) {
+			for _, issue := range issues {
+				summary.Issues[issue.Code]++
+			}
+			quarantined = append(quarantined, quarantineRecord{VehicleID: vehicle.Identifier, Name: vehicle.Name, URL: vehicle.Url, Part: part, Issues: issues})
+		}
+
+		var vehicleIssues, allPartIssues []validationIssue
+		partIssues := make(map[string][]validationIssue)
+		for _, issue := range checked.issues {
+			if issue.isPartIssue() {
+				partIssues[issue.PartID] = append(partIssues[issue.PartID], issue)
+				allPartIssues = append(allPartIssues, issue)
+			} else {
+				vehicleIssues = append(vehicleIssues, issue)
+			}
+		}
+		source := vehicle.Url + "\x00" + vehicle.Name
+		if seen, exists := seenVehicles[vehicle.Identifier]; exists {
+			code := issueDuplicateVehicle
+			if seen != source {
+				code = issueIdentifierCollision
+			}
+			vehicleIssues = append(vehicleIssues, validationIssue{Code: code, Field: "id", Value: vehicle.Identifier})
+		} else {
+			seenVehicles[vehicle.Identifier] = source
+		}
+		if len(vehicleIssues) > 0 {
+			// The issues of the parts are reported with the vehicle.
+			record(nil, append(vehicleIssues, allPartIssues...))
+			summary.QuarantinedVehicles++
+			summary.QuarantinedParts += len(vehicle.Parts)
+			continue
+		}
+
+		var parts []models.Part
+		for _, part := range vehicle.Parts {
+			issues := partIssues[part.PartIdentifier]
+			source := vehicle.Identifier + "\x00" + part.PartNumber
+			if seen, exists := seenParts[part.PartIdentifier]; exists {
+				code := issueDuplicatePart
+				if seen != source {
+					code = issueIdentifierCollision
+				}
+				issues = append(issues, validationIssue{Code: code, Field: "id", Value: part.PartIdentifier, PartID: part.PartIdentifier})
+			} else {
+				seenParts[part.PartIdentifier] = source
+			}
+			if len(issues) > 0 {
+				part := part
+				record(&part, issues)
+				summary.QuarantinedParts++
+				continue
+			}
+			parts = append(parts, part)
+		}
+		checked.vehicle.Parts = parts
+		valid = append(valid, checked)
+	}
+	return valid, quarantined, summary
+}
+
+// validateVehicles validates vehicles processed by an earlier crawl, such as
+// the ones read from the JSON output. Their raw prices are not known any more.
+func validateVehicles(vehicles []models.Vehicle, adapter sites.SiteAdapter) ([]models.Vehicle, []quarantineRecord, validationSummary) {
+	now := time.Now()
+	checked := make([]crawledVehicle, len(vehicles))
+	for i, vehicle := range vehicles {
+		checked[i] = crawledVehicle{index: i, url: vehicle.Url, vehicle: vehicle, issues: checkVehicle(vehicle, nil, adapter, now)}
+	}
+	valid, quarantined, summary := validate(checked)
+	return inListingOrder(valid), quarantined, summary
+}
+
+func (summary validationSummary) String() string {
+	codes := make([]string, 0, len(summary.Issues))
+	for code := range summary.Issues {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	counts := make([]string, len(codes))
+	for i, code := range codes {
+		counts[i] = fmt.Sprintf("%s %d", code, summary.Issues[code])
+	}
+	issues := "none"
+	if len(counts) > 0 {
+		issues = strings.Join(counts, ", ")
+	}
+	return fmt.Sprintf("%d vehicles and %d parts validated, %d vehicles and %d parts quarantined. Issues: %s",
+		summary.Vehicles, summary.Parts, summary.QuarantinedVehicles, summary.QuarantinedParts, issues)
+}
+
+// writeQuarantine dumps the quarantined records to an indented JSON file.
+func writeQuarantine(fName string, records []quarantineRecord) error {
+	if records == nil {
+		records = []quarantineRecord{}
+	}
+	payload, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fName, append(payload, '\n'), 0o644)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"Crawler/internal/models"
+	"Crawler/internal/sites"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_checkVehicle(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		vehicleName string
+		parts       []models.RawPart
+		want        []string
+	}{
+		{"valid vehicle", "Suzuki RX 2019", []models.RawPart{{Name: "Satula", PartIdentifier: "1", Price: "30 €"}}, nil},
+		{"unknown brand", "Foobar RX 2019", nil, []string{issueMissingBrand}},
+		{"missing model", "Suzuki 2019", nil, []string{issueMissingModel}},
+		{"missing year", "Suzuki RX", nil, []string{issueUnknownYear}},
+		{"future year", "Suzuki RX 2030", nil, []string{issueUnknownYear}},
+		{"bad part", "Suzuki RX 2019", []models.RawPart{{PartIdentifier: "1", Price: "kysy"}}, []string{issueEmptyPartName, issueUnparseablePrice}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := models.RawVehicle{Name: tt.vehicleName, Url: "https://www.purkuosat.net/vehicle.htm", RawParts: tt.parts}
+			vehicle := processRawVehicle(raw, "moped", sites.Purkuosat{})
+			var got []string
+			for _, issue := range checkVehicle(vehicle, &raw, sites.Purkuosat{}, now) {
+				got = append(got, issue.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkVehicle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validate(t *testing.T) {
+	parts := []models.Part{{Name: "Satula", PartIdentifier: "1"}, {PartIdentifier: "2"}, {Name: "Satula", PartIdentifier: "1"}}
+	valid := models.Vehicle{Name: "Suzuki RX 2019", Brand: "Suzuki", Model: "RX", Year: 2019, Identifier: "10", Parts: parts}
+	noBrand := models.Vehicle{Name: "Foobar RX 2019", Year: 2019, Identifier: "20", Parts: []models.Part{{Name: "Satula", PartIdentifier: "3"}}}
+	crawled := []crawledVehicle{
+		{index: 0, vehicle: valid, issues: []validationIssue{{Code: issueEmptyPartName, Field: "name", PartID: "2"}}},
+		{index: 1, vehicle: noBrand, issues: []validationIssue{{Code: issueMissingBrand, Field: "brand", Value: noBrand.Name}}},
+		{index: 2, vehicle: valid},
+	}
+
+	got, quarantined, summary := validate(crawled)
+	if len(got) != 1 || !reflect.DeepEqual(got[0].vehicle.Parts, parts[:1]) {
+		t.Errorf("validate() = %+v, want the valid vehicle with its valid part", got)
+	}
+	wantQuarantined := []quarantineRecord{
+		{VehicleID: "10", Name: valid.Name, Part: &parts[1], Issues: []validationIssue{{Code: issueEmptyPartName, Field: "name", PartID: "2"}}},
+		{VehicleID: "10", Name: valid.Name, Part: &parts[2], Issues: []validationIssue{{Code: issueDuplicatePart, Field: "id", Value: "1", PartID: "1"}}},
+		{VehicleID: "20", Name: noBrand.Name, Issues: []validationIssue{{Code: issueMissingBrand, Field: "brand", Value: noBrand.Name}}},
+		{VehicleID: "10", Name: valid.Name, Issues: []validationIssue{{Code: issueDuplicateVehicle, Field: "id", Value: "10"}}},
+	}
+	if !reflect.DeepEqual(quarantined, wantQuarantined) {
+		t.Errorf("validate() quarantined = %+v, want %+v", quarantined, wantQuarantined)
+	}
+	wantSummary := validationSummary{Vehicles: 3, Parts: 7, QuarantinedVehicles: 2, QuarantinedParts: 6, Issues: map[string]int{
+		issueEmptyPartName: 1, issueDuplicatePart: 1, issueMissingBrand: 1, issueDuplicateVehicle: 1,
+	}}
+	if !reflect.DeepEqual(summary, wantSummary) {
+		t.Errorf("validate() summary = %+v, want %+v", summary, wantSummary)
+	}
+}
+
+func Test_validate_collisions(t *testing.T) {
+	first := models.Vehicle{Name: "Suzuki RX 2019", Url: "https://www.purkuosat.net/suzukirx19.htm", Identifier: "10",
+		Parts: []models.Part{{Name: "Satula", PartIdentifier: "1", PartNumber: "SR-1"}, {Name: "Satula", PartIdentifier: "1", PartNumber: "SR-2"}}}
+	other := models.Vehicle{Name: "Suzuki RX 2017", Url: "https://www.purkuosat.net/suzukirx17.htm", Identifier: "10"}
+	_, quarantined, summary := validate([]crawledVehicle{{index: 0, vehicle: first}, {index: 1, vehicle: other}})
+
+	want := map[string]int{issueIdentifierCollision: 2}
+	if !reflect.DeepEqual(summary.Issues, want) || len(quarantined) != 2 {
+		t.Errorf("validate() issues = %v, quarantined %+v, want %v", summary.Issues, quarantined, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"Crawler/internal/modelname"
+	"Crawler/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// yearRe matches a model year or a range of them, "2004", "2004-2006" or "2004-06".
+	yearRe = regexp.MustCompile(`^((?:19|20)\d{2})(?:[-–/]((?:19|20)?\d{2}))?$`)
+	// displacementRe matches a displacement with its unit, "125cc" or "125 ccm".
+	displacementRe = regexp.MustCompile(`(?i)^(\d{2,4})(cc|ccm|cm3)$`)
+	// displacementUnitRe matches a unit written apart from the displacement.
+	displacementUnitRe = regexp.MustCompile(`(?i)^(cc|ccm|cm3)$`)
+	// strokeRe matches the stroke of the engine, "2T", "4-tahti" or "2-stroke".
+	strokeRe = regexp.MustCompile(`(?i)^([24])-?(t|tahti|takt|stroke)$`)
+)
+
+// vehicleName is the information parsed from the name of a vehicle listing.
+type vehicleName struct {
+	Brand string
+	Model string
+	// Year is the first model year of the range from YearFrom to YearTo.
+	Year     int
+	YearFrom int
+	YearTo   int
+	// Displacement is the one in the name with a unit, or else the one in the model.
+	Displacement int
+	// Stroke is models.StrokeTwo, models.StrokeFour or empty.
+	Stroke string
+	// Trim is the rest of the name after the year, such as "ABS" or "Custom".
+	Trim string
+}
+
+// parseVehicleName splits a vehicle name of the type into its brand, model,
+// years, displacement, stroke and trim. The name is expected to be the brand,
+// the model and the year or year range followed by the trim. The last year in
+// the name is the model year, as numbers in models such as "XV 1900" look
+// like years too. Words giving the stroke or the displacement with its unit
+// are left out of the model and trim wherever they are.
+func parseVehicleName(s string, vehicleType string) vehicleName {
+	words := strings.Fields(s)
+	var name vehicleName
+	start := 0
+	if brand, brandWords, ok := brands.Match(s, vehicleType); ok {
+		name.Brand = brand.Name
+		start = brandWords
+	}
+
+	// yearAt and yearEnd are the words of the year or range, yearAt is -1 without a year.
+	yearAt, yearEnd := -1, -1
+	for i := start; i < len(words); i++ {
+		from, to, n := parseYears(words[i:])
+		if n > 0 && !isDisplacementUnit(words, i+n) {
+			name.YearFrom, name.YearTo = from, to
+			yearAt, yearEnd = i, i+n
+			i += n - 1
+		}
+	}
+	name.Year = name.YearFrom
+
+	var model, trim []string
+	for i := start; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case yearAt >= 0 && i >= yearAt && i < yearEnd:
+			continue
+		case strokeRe.MatchString(word):
+			name.Stroke = models.StrokeFour
+			if strokeRe.FindStringSubmatch(word)[1] == "2" {
+				name.Stroke = models.StrokeTwo
+			}
+			continue
+		case displacementRe.MatchString(word):
+			name.Displacement, _ = strconv.Atoi(displacementRe.FindStringSubmatch(word)[1])
+		case isDisplacementUnit(words, i+1):
+			name.Displacement, _ = strconv.Atoi(word)
+		}
+		if yearAt >= 0 && i >= yearEnd {
+			if displacementRe.MatchString(word) || isDisplacementUnit(words, i+1) || displacementUnitRe.MatchString(word) {
+				continue
+			}
+			trim = append(trim, word)
+		} else {
+			model = append(model, word)
+		}
+	}
+	if name.Brand != "" {
+		name.Model = strings.Join(model, " ")
+	}
+	name.Trim = strings.Join(trim, " ")
+	if name.Displacement == 0 {
+		name.Displacement = modelname.Normalize(name.Model).Displacement
+	}
+	return name
+}
+
+// parseYears parses the year or year range at the start of the words. It
+// returns the first and last year and the number of words they took, 0 when
+// the words do not start with a year.
+func parseYears(words []string) (int, int, int) {
+	match := yearRe.FindStringSubmatch(words[0])
+	if match == nil {
+		return 0, 0, 0
+	}
+	from, _ := strconv.Atoi(match[1])
+	n := 1
+	end := match[2]
+	// "2004 - 2006" is written apart.
+	if end == "" && len(words) >= 3 && (words[1] == "-" || words[1] == "–") {
+		if next := yearRe.FindStringSubmatch(words[2]); next != nil && next[2] == "" {
+			end, n = next[1], 3
+		}
+	}
+	to := from
+	if end != "" {
+		to, _ = strconv.Atoi(end)
+		if len(end) == 2 {
+			to += from / 100 * 100
+		}
+		if to < from {
+			to = from
+		}
+	}
+	return from, to, n
+}
+
+// isDisplacementUnit tells whether the word at i is a displacement unit
+// written apart from the number before it.
+func isDisplacementUnit(words []string, i int) bool {
+	return i > 0 && i < len(words) && displacementUnitRe.MatchString(words[i]) && isNumber(words[i-1])
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// applyVehicleName sets the fields of the vehicle parsed from its name.
+func applyVehicleName(vehicle *models.Vehicle, name vehicleName) {
+	vehicle.Brand, vehicle.Model = name.Brand, name.Model
+	vehicle.Year, vehicle.YearFrom, vehicle.YearTo = name.Year, name.YearFrom, name.YearTo
+	vehicle.Stroke, vehicle.Trim = name.Stroke, name.Trim
+	modelName := modelname.Normalize(vehicle.Model)
+	vehicle.ModelKey, vehicle.ModelFamily = modelName.Key, modelName.Family
+	vehicle.Displacement = name.Displacement
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"Crawler/internal/database"
+	"Crawler/internal/helpers"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up        apply every pending migration
+  down      revert the latest applied migration
+  status    list migrations and whether they are applied
+  baseline  record the initial migration as applied to a database created
+            before migrations, without running it
+  rekey     move the removed vehicles and parts still stored with 32-bit
+            identifiers to 128-bit ones, best run after a crawl of every
+            category`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+	helpers.ReadConfig()
+
+	migrator, err := database.CreateMigrator()
+	if err != nil {
+		log.Fatalf("Cannot connect to database. Reason: %s\n", err)
+	}
+	defer migrator.DB.Close()
+
+	switch os.Args[1] {
+	case "up":
+		// Up baselines a database created before migrations too, this only logs it.
+		if initial, err := migrator.Baseline(); err == nil {
+			log.Printf("Recorded migration %d (%s) as applied to the existing schema", initial.Version, initial.Name)
+		} else if !errors.Is(err, database.ErrNoLegacySchema) {
+			log.Fatalln(err)
+		}
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("Applied migration %d (%s)", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database schema is already up to date.")
+		}
+	case "down":
+		migration, reverted, err := migrator.Down()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !reverted {
+			log.Println("No applied migrations to revert.")
+			return
+		}
+		log.Printf("Reverted migration %d (%s)", migration.Version, migration.Name)
+	case "baseline":
+		initial, err := migrator.Baseline()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Recorded migration %d (%s) as applied to the existing schema", initial.Version, initial.Name)
+	case "rekey":
+		vehicles, parts, err := migrator.RekeyLegacyIdentifiers()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Re-keyed %d removed vehicles and %d parts from legacy identifiers", vehicles, parts)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,157 @@
+// Package archive records the pages fetched by the crawler into a directory
+// and replays them, so that a crawl can be parsed again without network
+// access.
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrNotArchived is returned when replaying a page that was not recorded.
+var ErrNotArchived = errors.New("page is not archived")
+
+// Page is a recorded response.
+type Page struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+// Archive is a directory holding one JSON file per recorded page. The file
+// name is the hash of the method and URL of the page, so recording a page
+// again replaces it.
+type Archive struct {
+	dir string
+}
+
+// Open opens the archive in dir, creating the directory when needed.
+func Open(dir string) (*Archive, error) {
+	if dir == "" {
+		return nil, errors.New("archive directory is not configured")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir}, nil
+}
+
+func (a *Archive) path(method string, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Save stores the page. The file is replaced atomically, so that concurrent
+// crawl workers never leave a partly written page behind.
+func (a *Archive) Save(page Page) error {
+	payload, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(a.dir, ".page-*")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(payload); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), a.path(page.Method, page.URL))
+}
+
+// Load reads the page recorded for the method and URL.
+func (a *Archive) Load(method string, url string) (Page, error) {
+	payload, err := os.ReadFile(a.path(method, url))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Page{}, fmt.Errorf("%w: %s %s", ErrNotArchived, method, url)
+	}
+	if err != nil {
+		return Page{}, err
+	}
+	var page Page
+	if err = json.Unmarshal(payload, &page); err != nil {
+		return Page{}, fmt.Errorf("cannot read archived page %s %s: %w", method, url, err)
+	}
+	return page, nil
+}
+
+// Recorder is an HTTP transport that saves every response it receives into
+// the archive.
+type Recorder struct {
+	Archive *Archive
+	// Transport fetches the pages, http.DefaultTransport when nil.
+	Transport http.RoundTripper
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = r.Archive.Save(Page{
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Status:    resp.StatusCode,
+		Header:    resp.Header,
+		Body:      body,
+		FetchedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot archive %s: %w", req.URL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Replayer is an HTTP transport that serves the recorded responses of the
+// archive instead of fetching them. Pages that were not recorded fail with
+// ErrNotArchived.
+type Replayer struct {
+	Archive *Archive
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	page, err := r.Archive.Load(req.Method, req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(page.Status) + " " + http.StatusText(page.Status),
+		StatusCode:    page.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        page.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(page.Body)),
+		ContentLength: int64(len(page.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.htm" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<html>Hyv\xe4 kunto</html>"))
+	}))
+	pageArchive, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	recording := &http.Client{Transport: &Recorder{Archive: pageArchive}}
+	for _, path := range []string{"/suzukirx19.htm", "/missing.htm"} {
+		resp, err := recording.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("recording %s error = %v", path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	server.Close()
+
+	replaying := &http.Client{Transport: &Replayer{Archive: pageArchive}}
+	resp, err := replaying.Get(server.URL + "/suzukirx19.htm")
+	if err != nil {
+		t.Fatalf("replaying error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "<html>Hyv\xe4 kunto</html>" || resp.Header.Get("Content-Type") != "text/html; charset=iso-8859-1" {
+		t.Errorf("replayed %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	resp, err = replaying.Get(server.URL + "/missing.htm")
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("replayed missing page = %v, %v, want status 404", resp, err)
+	}
+	if _, err = replaying.Get(server.URL + "/polinixp450.htm"); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("replaying a page that was not recorded error = %v, want %v", err, ErrNotArchived)
+	}
+}
@@ -0,0 +1,154 @@
+// Package compatibility proposes which parts fit vehicles other than the one
+// they were taken from.
+package compatibility
+
+import (
+	"Crawler/internal/models"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Sources of proposed compatibilities.
+const (
+	// SourcePartNumber means the parts mention the same original part number.
+	SourcePartNumber = "part_number"
+	// SourcePartName means parts of the same brand have identical names and descriptions.
+	SourcePartName = "part_name"
+)
+
+// partNumberCandidate matches words that may be original part numbers, such as
+// "5GJ-14710-00" or "90119-10M21".
+var partNumberCandidate = regexp.MustCompile(`[0-9A-Za-z]+(?:[-./][0-9A-Za-z]+)*`)
+
+// yearRange matches candidates that are year ranges such as "2004-2006" instead of part numbers.
+var yearRange = regexp.MustCompile(`^(19|20)\d\d[-./](19|20)?\d\d$`)
+
+// minPartNumberLength is the minimum count of letters and digits in a part number.
+const minPartNumberLength = 6
+
+// extractPartNumbers returns the original part numbers mentioned in text, upper-cased.
+func extractPartNumbers(text string) []string {
+	var partNumbers []string
+	for _, candidate := range partNumberCandidate.FindAllString(text, -1) {
+		if yearRange.MatchString(candidate) {
+			continue
+		}
+		var alphanumerics, digits int
+		for _, r := range candidate {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				alphanumerics++
+			}
+		}
+		hasSeparator := alphanumerics != len(candidate)
+		// Plain numbers are only accepted when they are long enough not to be prices or years.
+		if alphanumerics < minPartNumberLength || digits == 0 || (!hasSeparator && digits == alphanumerics && digits < 8) {
+			continue
+		}
+		partNumbers = append(partNumbers, strings.ToUpper(candidate))
+	}
+	return partNumbers
+}
+
+// normalizeText lower-cases text and collapses white space.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// member is a part found on one of the vehicles sharing a match key.
+type member struct {
+	part    models.Part
+	vehicle models.Vehicle
+}
+
+type modelKey struct {
+	brand string
+	model string
+}
+
+// Match proposes compatibilities between the parts of the given vehicles.
+// Parts that mention the same original part number fit every model the part
+// number was seen on. Parts of the same brand with identical names and
+// non-empty descriptions fit each other's models. A part fits a model for the
+// years between the oldest and the newest matching vehicle of that model.
+// Vehicles without brand, model or year are skipped.
+func Match(vehicles []models.Vehicle) []models.Compatibility {
+	groups := make(map[string][]member)
+	for _, vehicle := range vehicles {
+		if vehicle.Brand == "" || vehicle.Model == "" || vehicle.Year == 0 {
+			continue
+		}
+		for _, part := range vehicle.Parts {
+			m := member{part: part, vehicle: vehicle}
+			for _, partNumber := range extractPartNumbers(part.Name + " " + part.Description) {
+				key := SourcePartNumber + "\x00" + partNumber
+				groups[key] = append(groups[key], m)
+			}
+			name, description := normalizeText(part.Name), normalizeText(part.Description)
+			if name != "" && description != "" {
+				key := SourcePartName + "\x00" + strings.ToLower(vehicle.Brand) + "\x00" + name + "\x00" + description
+				groups[key] = append(groups[key], m)
+			}
+		}
+	}
+
+	seen := make(map[models.Compatibility]bool)
+	var compatibilities []models.Compatibility
+	for key, members := range groups {
+		source, _, _ := strings.Cut(key, "\x00")
+		// Year range of each model in the group.
+		years := make(map[modelKey][2]int)
+		for _, m := range members {
+			k := modelKey{m.vehicle.Brand, m.vehicle.Model}
+			r, exists := years[k]
+			if !exists {
+				r = [2]int{m.vehicle.Year, m.vehicle.Year}
+			}
+			r[0] = min(r[0], m.vehicle.Year)
+			r[1] = max(r[1], m.vehicle.Year)
+			years[k] = r
+		}
+		for _, m := range members {
+			for k, r := range years {
+				// The part trivially fits the vehicle it was taken from.
+				if k.brand == m.vehicle.Brand && k.model == m.vehicle.Model && r[0] == r[1] {
+					continue
+				}
+				compatibility := models.Compatibility{
+					PartIdentifier: m.part.PartIdentifier,
+					Brand:          k.brand,
+					Model:          k.model,
+					YearFrom:       r[0],
+					YearTo:         r[1],
+					Source:         source,
+				}
+				if !seen[compatibility] {
+					seen[compatibility] = true
+					compatibilities = append(compatibilities, compatibility)
+				}
+			}
+		}
+	}
+
+	sort.Slice(compatibilities, func(i, j int) bool {
+		a, b := compatibilities[i], compatibilities[j]
+		if a.PartIdentifier != b.PartIdentifier {
+			return a.PartIdentifier < b.PartIdentifier
+		}
+		if a.Brand != b.Brand {
+			return a.Brand < b.Brand
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.YearFrom != b.YearFrom {
+			return a.YearFrom < b.YearFrom
+		}
+		return a.Source < b.Source
+	})
+	return compatibilities
+}
@@ -0,0 +1,58 @@
+package compatibility
+
+import (
+	"Crawler/internal/models"
+	"reflect"
+	"testing"
+)
+
+func Test_extractPartNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"Test dashed part number", "Jarrukahva, alkuperäinen 5GJ-83922-00", []string{"5GJ-83922-00"}},
+		{"Test lower case part number", "osanumero 90119-10m21", []string{"90119-10M21"}},
+		{"Test long plain number", "OEM 4560123789", []string{"4560123789"}},
+		{"Test price and year are ignored", "Hinta 120 €, vuosimalli 2004", nil},
+		{"Test year range is ignored", "Sopii 2004-2006 malleihin", nil},
+		{"Test short words are ignored", "Etulokasuoja RX-3", nil},
+		{"Test words without digits are ignored", "Takarengas Michelin-Pilot", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractPartNumbers(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractPartNumbers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Match(t *testing.T) {
+	vehicles := []models.Vehicle{
+		{Brand: "Yamaha", Model: "DT 50", Identifier: "1", Year: 2004, Parts: []models.Part{
+			{PartIdentifier: "11", Name: "Jarrukahva", Description: "Alkuperäinen 5GJ-83922-00"},
+			{PartIdentifier: "12", Name: "Takarengas", Description: "Hyvä kunto"},
+		}},
+		{Brand: "Yamaha", Model: "DT 50", Identifier: "2", Year: 2006, Parts: []models.Part{
+			{PartIdentifier: "21", Name: "Takarengas", Description: "Hyvä  kunto"},
+		}},
+		{Brand: "MBK", Model: "X-Limit", Identifier: "3", Year: 2005, Parts: []models.Part{
+			{PartIdentifier: "31", Name: "Jarrukahva", Description: "5gj-83922-00"},
+			{PartIdentifier: "32", Name: "Takarengas", Description: "Hyvä kunto"},
+		}},
+		{Brand: "", Model: "", Identifier: "4", Year: 0, Parts: []models.Part{
+			{PartIdentifier: "41", Name: "Jarrukahva", Description: "5GJ-83922-00"},
+		}},
+	}
+	want := []models.Compatibility{
+		{PartIdentifier: "11", Brand: "MBK", Model: "X-Limit", YearFrom: 2005, YearTo: 2005, Source: SourcePartNumber},
+		{PartIdentifier: "12", Brand: "Yamaha", Model: "DT 50", YearFrom: 2004, YearTo: 2006, Source: SourcePartName},
+		{PartIdentifier: "21", Brand: "Yamaha", Model: "DT 50", YearFrom: 2004, YearTo: 2006, Source: SourcePartName},
+		{PartIdentifier: "31", Brand: "Yamaha", Model: "DT 50", YearFrom: 2004, YearTo: 2004, Source: SourcePartNumber},
+	}
+	if got := Match(vehicles); !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+// Package crawlqueue keeps the state of crawl runs in a SQLite file, so that a
+// crawl that dies midway can be resumed instead of started over. A run holds
+// the part page URL of every vehicle found on a listing page with its state,
+// and the vehicles parsed so far.
+package crawlqueue
+
+import (
+	"Crawler/internal/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// State is the crawl state of a part page URL.
+type State string
+
+const (
+	// StatePending URLs are discovered but not fetched yet.
+	StatePending State = "pending"
+	// StateFetched URLs are fetched but their vehicle is not checkpointed yet.
+	StateFetched State = "fetched"
+	// StateParsed URLs have their parsed vehicle stored in the queue.
+	StateParsed State = "parsed"
+	// StateFailed URLs could not be fetched or parsed. They are retried when
+	// the run is resumed.
+	StateFailed State = "failed"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS crawl_runs (
+    run_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category TEXT NOT NULL,
+    listing_url TEXT NOT NULL,
+    discovered BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS crawl_urls (
+    run_id INTEGER NOT NULL REFERENCES crawl_runs (run_id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    listing_index INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    vehicle TEXT,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (run_id, url)
+);`
+
+// Queue is the crawl queue file.
+type Queue struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the queue file at path.
+func Open(path string) (*Queue, error) {
+	if len(path) == 0 {
+		return nil, errors.New("crawl queue path is not configured")
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows only one writer at a time, and the crawl workers write concurrently.
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot create crawl queue schema: %w", err)
+	}
+	return &Queue{db: db}, nil
+}
+
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Run is a crawl of the listing page of a category.
+type Run struct {
+	q          *Queue
+	ID         int64
+	Category   string
+	ListingURL string
+	// Discovered tells whether all the vehicle links of the listing page are enqueued.
+	Discovered bool
+}
+
+// Start starts a new run of the category.
+func (q *Queue) Start(category string, listingURL string) (*Run, error) {
+	result, err := q.db.Exec("INSERT INTO crawl_runs (category, listing_url) VALUES (?, ?);", category, listingURL)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &Run{q: q, ID: id, Category: category, ListingURL: listingURL}, nil
+}
+
+// Resume returns the last unfinished run of the category and listing page,
+// or starts a new run when every run has finished.
+func (q *Queue) Resume(category string, listingURL string) (*Run, error) {
+	run := &Run{q: q, Category: category, ListingURL: listingURL}
+	err := q.db.QueryRow(`SELECT run_id, discovered FROM crawl_runs
+WHERE category = ? AND listing_url = ? AND finished_at IS NULL ORDER BY run_id DESC LIMIT 1;`, category, listingURL).Scan(&run.ID, &run.Discovered)
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.Start(category, listingURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Entry is a part page URL of a run.
+type Entry struct {
+	URL string
+	// Index is the position of the vehicle link on the listing page.
+	Index     int
+	Name      string
+	State     State
+	Attempts  int
+	LastError string
+	// Vehicle is the parsed vehicle of parsed entries.
+	Vehicle *models.Vehicle
+}
+
+// storedVehicle keeps the name of the vehicle, which is not part of its JSON.
+type storedVehicle struct {
+	Name string `json:"name"`
+	models.Vehicle
+}
+
+// Entries returns the entries of the run in listing order.
+func (r *Run) Entries() ([]Entry, error) {
+	rows, err := r.q.db.Query(`SELECT url, listing_index, name, state, attempts, last_error, vehicle FROM crawl_urls
+WHERE run_id = ? ORDER BY listing_index;`, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var vehicle sql.NullString
+		if err = rows.Scan(&entry.URL, &entry.Index, &entry.Name, &entry.State, &entry.Attempts, &entry.LastError, &vehicle); err != nil {
+			return nil, err
+		}
+		if vehicle.Valid {
+			var stored storedVehicle
+			if err = json.Unmarshal([]byte(vehicle.String), &stored); err != nil {
+				return nil, fmt.Errorf("cannot read the checkpointed vehicle of %s: %w", entry.URL, err)
+			}
+			stored.Vehicle.Name = stored.Name
+			entry.Vehicle = &stored.Vehicle
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Enqueue adds a pending URL to the run. URLs already in the run keep their state.
+func (r *Run) Enqueue(index int, name string, url string) error {
+	_, err := r.q.db.Exec(`INSERT INTO crawl_urls (run_id, url, listing_index, name) VALUES (?, ?, ?, ?)
+ON CONFLICT (run_id, url) DO UPDATE SET listing_index = excluded.listing_index, name = excluded.name;`, r.ID, url, index, name)
+	return err
+}
+
+// MarkDiscovered records that all the vehicle links of the listing page are enqueued.
+func (r *Run) MarkDiscovered() error {
+	_, err := r.q.db.Exec("UPDATE crawl_runs SET discovered = TRUE WHERE run_id = ?;", r.ID)
+	if err == nil {
+		r.Discovered = true
+	}
+	return err
+}
+
+// MarkFetched records that the URL was fetched after the number of attempts.
+func (r *Run) MarkFetched(url string, attempts int) error {
+	return r.update(url, StateFetched, attempts, "")
+}
+
+// MarkFailed records that the URL could not be fetched or parsed. The attempts
+// that failed are added to the attempts of the URL.
+func (r *Run) MarkFailed(url string, attempts int, cause error) error {
+	return r.update(url, StateFailed, attempts, cause.Error())
+}
+
+func (r *Run) update(url string, state State, attempts int, lastError string) error {
+	_, err := r.q.db.Exec(`UPDATE crawl_urls SET state = ?, attempts = attempts + ?, last_error = ?, updated_at = current_timestamp
+WHERE run_id = ? AND url = ?;`, state, attempts, lastError, r.ID, url)
+	return err
+}
+
+// Checkpoint stores the parsed vehicles of the URLs in one transaction.
+func (r *Run) Checkpoint(vehicles map[string]models.Vehicle) error {
+	tx, err := r.q.db.Begin()
+	if err != nil {
+		return err
+	}
+	for url, vehicle := range vehicles {
+		payload, err := json.Marshal(storedVehicle{Name: vehicle.Name, Vehicle: vehicle})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(`UPDATE crawl_urls SET state = ?, last_error = '', vehicle = ?, updated_at = current_timestamp
+WHERE run_id = ? AND url = ?;`, StateParsed, string(payload), r.ID, url)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Finish marks the run finished, so that it is not resumed.
+func (r *Run) Finish() error {
+	_, err := r.q.db.Exec("UPDATE crawl_runs SET finished_at = current_timestamp WHERE run_id = ?;", r.ID)
+	return err
+}
@@ -0,0 +1,69 @@
+package crawlqueue
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_Queue(t *testing.T) {
+	queue, err := Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer queue.Close()
+
+	run, err := queue.Start("moped", "https://www.purkuosat.net/mopot.htm")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for i, url := range []string{"suzukirx19.htm", "polinixp450.htm", "suzukirx17.htm"} {
+		if err := run.Enqueue(i, "Vehicle "+url, url); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	if err := run.MarkDiscovered(); err != nil {
+		t.Fatalf("MarkDiscovered() error = %v", err)
+	}
+	run.MarkFetched("suzukirx19.htm", 2)
+	run.MarkFailed("polinixp450.htm", 1, errors.New("Not Found"))
+	vehicle := models.Vehicle{Name: "Suzuki RX 2019", Brand: "Suzuki", Identifier: "1", Parts: []models.Part{{Name: "Satula", PartIdentifier: "11", Price: models.NewPrice(30, models.DefaultCurrency, true)}}}
+	if err := run.Checkpoint(map[string]models.Vehicle{"suzukirx19.htm": vehicle}); err != nil {
+		t.Fatalf("Checkpoint() error = %v", err)
+	}
+
+	// An unfinished run is resumed with its state.
+	resumed, err := queue.Resume("moped", "https://www.purkuosat.net/mopot.htm")
+	if err != nil || resumed.ID != run.ID || !resumed.Discovered {
+		t.Fatalf("Resume() = %+v, %v, want run %d", resumed, err, run.ID)
+	}
+	entries, err := resumed.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	want := []Entry{
+		{URL: "suzukirx19.htm", Index: 0, Name: "Vehicle suzukirx19.htm", State: StateParsed, Attempts: 2, Vehicle: &vehicle},
+		{URL: "polinixp450.htm", Index: 1, Name: "Vehicle polinixp450.htm", State: StateFailed, Attempts: 1, LastError: "Not Found"},
+		{URL: "suzukirx17.htm", Index: 2, Name: "Vehicle suzukirx17.htm", State: StatePending},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Entries() = %+v, want %+v", entries, want)
+	}
+
+	// Enqueueing a URL again keeps its state.
+	resumed.Enqueue(0, "Vehicle suzukirx19.htm", "suzukirx19.htm")
+	if entries, _ := resumed.Entries(); entries[0].State != StateParsed {
+		t.Errorf("state after Enqueue() = %v, want %v", entries[0].State, StateParsed)
+	}
+
+	// A finished run is not resumed.
+	if err := resumed.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	next, err := queue.Resume("moped", "https://www.purkuosat.net/mopot.htm")
+	if err != nil || next.ID == run.ID || next.Discovered {
+		t.Errorf("Resume() after Finish() = %+v, %v, want a new run", next, err)
+	}
+}
@@ -1,54 +1,131 @@
 package data
 
-var Brands = []string{
-	"Aprilia",
-	"Arctic Cat",
-	"BMW",
-	"BRP",
-	"Benelli",
-	"Beta",
-	"Bimota",
-	"MBK",
-	"Buell",
-	"Cagiva",
-	"Can Am",
-	"Cannondale",
-	"CPI",
-	"Derbi",
-	"Ducati",
-	"Gas Gas",
-	"Baotian",
-	"Gilera",
-	"HM",
-	"Honda",
-	"Harley Davidson",
-	"Husaberg",
-	"Husqvarna",
-	"Kawasaki",
-	"KTM",
-	"Kymco",
-	"Keeway",
-	"Malaguti",
-	"CHRacing",
-	"Polini",
-	"Kawasaki",
-	"Masai",
-	"Motorhispania",
-	"Peugeot",
-	"Rieju",
-	"Royal Enfield",
-	"Thumpstar",
-	"TM",
-	"YCF",
-	"Solifer",
-	"Sachs",
-	"Skyteam",
-	"MBX",
-	"MV Agusta",
-	"Moto Guzzi",
-	"Piaggio",
-	"Suzuki",
-	"Triumph",
-	"Vespa",
-	"Yamaha",
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed brands.yaml
+var defaultCatalogue []byte
+
+// Brand is a vehicle brand of the catalogue.
+type Brand struct {
+	// Name is the canonical name the vehicles get.
+	Name string `yaml:"name"`
+	// Aliases are other spellings of the name found in vehicle names.
+	Aliases []string `yaml:"aliases"`
+	// VehicleTypes limits the brand to vehicles of these types, empty is every type.
+	VehicleTypes []string `yaml:"vehicle_types"`
+}
+
+// AppliesTo tells whether vehicles of the type may be of the brand. An empty
+// vehicle type matches every brand.
+func (brand Brand) AppliesTo(vehicleType string) bool {
+	if len(brand.VehicleTypes) == 0 || vehicleType == "" {
+		return true
+	}
+	for _, applicable := range brand.VehicleTypes {
+		if strings.EqualFold(applicable, vehicleType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Catalogue recognises the brands of vehicle names. The names and aliases are
+// compiled into a lookup by their normalized form when it is loaded.
+type Catalogue struct {
+	Brands []Brand
+	lookup map[string]*Brand
+	// maxWords is the most words in a name or alias.
+	maxWords int
+}
+
+// normalizeBrand ignores the case of a brand name and everything in it but
+// letters and digits, such as spaces, dashes and trailing commas.
+func normalizeBrand(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// ParseCatalogue reads a YAML or JSON brand catalogue. A name or alias may
+// only belong to one brand. Aliases that normalize to the name are redundant.
+func ParseCatalogue(content []byte) (*Catalogue, error) {
+	var file struct {
+		Brands []Brand `yaml:"brands"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid brand catalogue: %w", err)
+	}
+	catalogue := &Catalogue{Brands: file.Brands, lookup: make(map[string]*Brand)}
+	for i := range catalogue.Brands {
+		brand := &catalogue.Brands[i]
+		if strings.TrimSpace(brand.Name) == "" {
+			return nil, fmt.Errorf("invalid brand catalogue: brand %d has no name", i+1)
+		}
+		for _, name := range append([]string{brand.Name}, brand.Aliases...) {
+			key := normalizeBrand(name)
+			if other, exists := catalogue.lookup[key]; exists && other != brand {
+				return nil, fmt.Errorf("invalid brand catalogue: %q of %s is already a name of %s", name, brand.Name, other.Name)
+			}
+			catalogue.lookup[key] = brand
+			catalogue.maxWords = max(catalogue.maxWords, len(strings.Fields(name)))
+		}
+	}
+	return catalogue, nil
+}
+
+// LoadCatalogue reads the brand catalogue file.
+func LoadCatalogue(path string) (*Catalogue, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalogue(content)
+}
+
+var parseDefaultCatalogue = sync.OnceValues(func() (*Catalogue, error) {
+	return ParseCatalogue(defaultCatalogue)
+})
+
+// DefaultCatalogue returns the brand catalogue built into the binary.
+func DefaultCatalogue() *Catalogue {
+	catalogue, err := parseDefaultCatalogue()
+	if err != nil {
+		panic(err)
+	}
+	return catalogue
+}
+
+// Lookup returns the brand that has the name or alias.
+func (catalogue *Catalogue) Lookup(name string) (Brand, bool) {
+	brand, exists := catalogue.lookup[normalizeBrand(name)]
+	if !exists {
+		return Brand{}, false
+	}
+	return *brand, true
+}
+
+// Match finds the brand that a vehicle name of the vehicle type starts with.
+// The longest matching name or alias wins. It returns the brand and the number
+// of words of the vehicle name that the brand spans.
+func (catalogue *Catalogue) Match(vehicleName string, vehicleType string) (Brand, int, bool) {
+	words := strings.Fields(vehicleName)
+	for n := min(catalogue.maxWords, len(words)); n > 0; n-- {
+		brand, exists := catalogue.lookup[normalizeBrand(strings.Join(words[:n], " "))]
+		if exists && brand.AppliesTo(vehicleType) {
+			return *brand, n, true
+		}
+	}
+	return Brand{}, 0, false
 }
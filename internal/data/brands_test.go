@@ -0,0 +1,80 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_DefaultCatalogue(t *testing.T) {
+	catalogue := DefaultCatalogue()
+	names := make(map[string]bool)
+	for _, brand := range catalogue.Brands {
+		if names[brand.Name] {
+			t.Errorf("brand %s is listed twice", brand.Name)
+		}
+		names[brand.Name] = true
+	}
+	if brand, ok := catalogue.Lookup("harley-davidson"); !ok || brand.Name != "Harley Davidson" {
+		t.Errorf("Lookup() = %+v, %v, want Harley Davidson", brand, ok)
+	}
+}
+
+func Test_Catalogue_Match(t *testing.T) {
+	catalogue, err := ParseCatalogue([]byte(`
+brands:
+  - name: Harley Davidson
+    aliases: [HD]
+  - name: Arctic Cat
+    vehicle_types: [snowmobile]
+  - name: MV Agusta
+    aliases: [MV]
+`))
+	if err != nil {
+		t.Fatalf("ParseCatalogue() error = %v", err)
+	}
+	tests := []struct {
+		name        string
+		vehicleName string
+		vehicleType string
+		wantBrand   string
+		wantWords   int
+	}{
+		{"canonical name", "Harley Davidson Sportster 2005", "motorcycle", "Harley Davidson", 2},
+		{"dashed name", "Harley-Davidson Sportster 2005", "motorcycle", "Harley Davidson", 1},
+		{"alias", "HD Sportster 2005", "motorcycle", "Harley Davidson", 1},
+		{"case and punctuation", "hd, Sportster", "", "Harley Davidson", 1},
+		{"longest match", "MV Agusta Brutale 2010", "motorcycle", "MV Agusta", 2},
+		{"shorter alias", "MV Brutale 2010", "motorcycle", "MV Agusta", 1},
+		{"applicable type", "Arctic Cat ZR 2008", "snowmobile", "Arctic Cat", 2},
+		{"other type", "Arctic Cat ZR 2008", "moped", "", 0},
+		{"unknown brand", "Foobar 2008", "moped", "", 0},
+		{"empty name", "", "moped", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brand, words, ok := catalogue.Match(tt.vehicleName, tt.vehicleType)
+			if brand.Name != tt.wantBrand || words != tt.wantWords || ok != (tt.wantBrand != "") {
+				t.Errorf("Match() = %q, %d, %v, want %q, %d", brand.Name, words, ok, tt.wantBrand, tt.wantWords)
+			}
+		})
+	}
+}
+
+func Test_ParseCatalogue_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed", "brands: [", "invalid brand catalogue"},
+		{"missing name", "brands:\n  - aliases: [HD]", "has no name"},
+		{"shared alias", "brands:\n  - name: Honda\n  - name: Hyosung\n    aliases: [honda]", "already a name of Honda"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCatalogue([]byte(tt.content)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseCatalogue() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
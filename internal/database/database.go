@@ -1,34 +1,85 @@
 package database
 
 import (
+	"Crawler/internal/modelname"
 	"Crawler/internal/models"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"sort"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/viper"
 )
 
-type PSQLHandler struct {
-	DB *sql.DB
+// CreateDatabaseHandler connects to the database configured with the
+// database.driver key and returns the handler. Supported drivers are
+// "postgres" (the default), "sqlite" and "memory". For the memory driver the
+// connection string is the crawler output directory to load vehicles from.
+// PostgreSQL bulk loads in batches of database.copy_batch_size rows when it is
+// positive, by default it upserts row by row.
+// SQL databases must have every migration applied.
+func CreateDatabaseHandler() (models.DatabaseHandler, error) {
+	driver := viper.GetString("database.driver")
+	connectionString := viper.GetString("database.connection_string")
+	switch driver {
+	case "", "postgres":
+		handler, err := CreatePSQLHandler(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		if viper.IsSet("database.copy_batch_size") {
+			handler.CopyBatchSize = viper.GetInt("database.copy_batch_size")
+		}
+		return handler, checkSchema(handler.DB, "postgres", handler)
+	case "sqlite":
+		handler, err := CreateSQLiteHandler(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		return handler, checkSchema(handler.DB, "sqlite", handler)
+	case "memory":
+		return CreateMemoryHandlerFromDirectory(connectionString)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
 }
 
-// createDatabaseHandler connects to PostgreSQL database and returns the handler.
-func CreateDatabaseHandler() *PSQLHandler {
-	// Connect to the PostgreSQL database
-	db, err := sql.Open("postgres", viper.GetString("database.connection_string"))
-	if err != nil {
-		panic(err)
+// CreateMigrator connects to the configured SQL database without checking its
+// schema and returns a migrator for it. The caller closes the migrator's DB.
+func CreateMigrator() (*Migrator, error) {
+	driver := viper.GetString("database.driver")
+	connectionString := viper.GetString("database.connection_string")
+	switch driver {
+	case "", "postgres":
+		handler, err := CreatePSQLHandler(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		return NewMigrator(handler.DB, "postgres")
+	case "sqlite":
+		handler, err := CreateSQLiteHandler(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		return NewMigrator(handler.DB, "sqlite")
+	default:
+		return nil, fmt.Errorf("database driver %q does not support migrations", driver)
 	}
+}
 
-	// Verify the connection by pinging the database
-	err = db.Ping()
+// checkSchema closes the handler and returns an error if the database has pending migrations.
+func checkSchema(db *sql.DB, driver string, handler models.DatabaseHandler) error {
+	migrator, err := NewMigrator(db, driver)
+	if err == nil {
+		err = migrator.CheckCurrent()
+	}
 	if err != nil {
-		panic(err)
+		handler.Close()
+		return err
 	}
-	log.Println("Successfully connected to PostgreSQL!")
-	return &PSQLHandler{DB: db}
+	return nil
 }
 
 func hasDuplicateVehicleIDs(vehicles []models.Vehicle) bool {
@@ -50,12 +101,10 @@ func hasDuplicateVehicleIDs(vehicles []models.Vehicle) bool {
 	return false
 }
 
-func (handler *PSQLHandler) InsertVehicles(vehicles []models.Vehicle) error {
-	duplicates := hasDuplicateVehicleIDs(vehicles)
-	if duplicates {
-		return errors.New("duplicate id found")
-	}
-	tx, err := handler.DB.Begin()
+// withTransaction runs fn inside a transaction, committing it if fn succeeds
+// and rolling it back otherwise.
+func withTransaction(db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
@@ -72,269 +121,347 @@ func (handler *PSQLHandler) InsertVehicles(vehicles []models.Vehicle) error {
 			}
 		}
 	}()
+	return fn(tx)
+}
 
-	stmt, err := tx.Prepare("INSERT INTO Vehicles (vehicle_type, brand_name, model_name, listing_url, vehicle_id, year) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING;")
-	if err != nil {
-		return err
+// newProgressBar returns a progress bar for total steps that is only drawn if showProgress is set.
+func newProgressBar(total int, showProgress bool) *progressbar.ProgressBar {
+	if !showProgress {
+		return progressbar.DefaultSilent(int64(total))
 	}
-	defer stmt.Close()
-	bar := progressbar.Default(int64(len(vehicles)))
-	for _, vehicle := range vehicles {
-		_, err := stmt.Exec(vehicle.VehicleType, vehicle.Brand, vehicle.Model, vehicle.Url, vehicle.Identifier, vehicle.Year)
-		if err != nil {
-			return err
-		}
-		bar.Add(1)
+	return progressbar.Default(int64(total))
+}
+
+// withYearRange makes the year range of a vehicle scraped before the ranges
+// were parsed its model year.
+func withYearRange(vehicle models.Vehicle) models.Vehicle {
+	if vehicle.YearFrom == 0 && vehicle.YearTo == 0 {
+		vehicle.YearFrom, vehicle.YearTo = vehicle.Year, vehicle.Year
 	}
-	return nil
+	return vehicle
 }
 
-// InsertParts adds the parts to the database in a batch.
-func (handler *PSQLHandler) InsertParts(vehicles []models.Vehicle) error {
-	// Prepare a transaction
-	tx, err := handler.DB.Begin()
-	if err != nil {
-		return err
+// insertVehicles executes the dialect specific vehicle upsert for each vehicle.
+// The upsert takes vehicle_type, brand_name, model_name, listing_url,
+// vehicle_id, year, model_key, model_family, displacement, year_from, year_to,
+// stroke and trim_level as parameters. It must only affect rows that are new
+// or changed, and never a vehicle of another listing. When no row is affected,
+// listingQuery selects the listing_url of the stored vehicle_id to tell an
+// unchanged vehicle from a collision.
+func insertVehicles(db *sql.DB, upsertQuery string, listingQuery string, vehicles []models.Vehicle, showProgress bool) error {
+	duplicates := hasDuplicateVehicleIDs(vehicles)
+	if duplicates {
+		return errors.New("duplicate id found")
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p)
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
+	var collisions []models.IdentifierCollision
+	err := withTransaction(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(upsertQuery)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		bar := newProgressBar(len(vehicles), showProgress)
+		for _, vehicle := range vehicles {
+			vehicle = withYearRange(withModelName(vehicle))
+			result, err := stmt.Exec(vehicle.VehicleType, vehicle.Brand, vehicle.Model, vehicle.Url, vehicle.Identifier, vehicle.Year,
+				vehicle.ModelKey, vehicle.ModelFamily, vehicle.Displacement, vehicle.YearFrom, vehicle.YearTo, vehicle.Stroke, vehicle.Trim)
 			if err != nil {
-				log.Printf("error while committing transaction: %v", err)
+				return err
 			}
-		}
-	}()
-
-	stmt, err := tx.Prepare("INSERT INTO Parts (part_name, description, part_id, vehicle_id, price, img_url, img_thumb_url) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING;")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	var totalPartCount int
-	// Get total part count for progress bar.
-	for _, vehicle := range vehicles {
-		totalPartCount += len(vehicle.Parts)
-	}
-	bar := progressbar.Default(int64(totalPartCount))
-
-	for _, vehicle := range vehicles {
-		vehicleId := vehicle.Identifier
-		for _, part := range vehicle.Parts {
-			_, err := stmt.Exec(part.Name, part.Description, part.PartIdentifier, vehicleId, part.Price, part.ImgUrl, part.ImgThumbUrl)
+			affected, err := result.RowsAffected()
 			if err != nil {
 				return err
 			}
+			if affected == 0 {
+				var storedURL string
+				err = tx.QueryRow(listingQuery, vehicle.Identifier).Scan(&storedURL)
+				if err != nil {
+					return err
+				}
+				if storedURL != vehicle.Url {
+					collisions = append(collisions, models.IdentifierCollision{Kind: models.KindVehicle, Identifier: vehicle.Identifier, Stored: storedURL, Scraped: vehicle.Url})
+				}
+			}
 			bar.Add(1)
 		}
-	}
-	return nil
+		return nil
+	})
+	return collisionError(err, collisions)
 }
 
-func (handler *PSQLHandler) GetVehicleCount() (int, error) {
-	count := 0
-	err := handler.DB.QueryRow("SELECT COUNT(vehicle_id) FROM Vehicles;").Scan(&count)
+// collisionError returns err, or the collisions when there is no error.
+func collisionError(err error, collisions []models.IdentifierCollision) error {
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return count, nil
-}
-
-func (handler *PSQLHandler) GetBrands(vehicleType string) ([]string, error) {
-	rows, err := handler.DB.Query("SELECT DISTINCT(brand_name) FROM Vehicles WHERE vehicle_type = $1 ORDER BY brand_name ASC;", vehicleType)
-	if err != nil {
-		return nil, err
+	if len(collisions) > 0 {
+		return &models.CollisionError{Collisions: collisions}
 	}
+	return nil
+}
 
-	var brands []string
-	for rows.Next() {
-		var brand string
-		err = rows.Scan(&brand)
+// insertParts executes the dialect specific part upsert for each part. The
+// upsert takes part_name, description, part_id, vehicle_id, price_amount,
+// price_currency, price_vat_included, img_url, img_thumb_url, part_number,
+// img_hash and img_thumb_hash as parameters and
+// must only affect rows that are new or changed, and never a part of another
+// vehicle. When no row is affected, ownerQuery selects the vehicle_id of the stored part_id to tell an
+// unchanged part from a collision. The update timestamp of vehicles with
+// affected parts is refreshed with touchVehicleQuery, which takes vehicle_id
+// as its parameter. The price of each part is then passed to
+// priceHistoryQuery as part_id, price_amount, price_currency and
+// price_vat_included, which must record it only if it
+// differs from the last observation.
+func insertParts(db *sql.DB, upsertQuery string, ownerQuery string, touchVehicleQuery string, priceHistoryQuery string, vehicles []models.Vehicle, showProgress bool) error {
+	var collisions []models.IdentifierCollision
+	err := withTransaction(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(upsertQuery)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		brands = append(brands, brand)
-	}
-	return brands, nil
+		defer stmt.Close()
+		priceStmt, err := tx.Prepare(priceHistoryQuery)
+		if err != nil {
+			return err
+		}
+		defer priceStmt.Close()
+		var totalPartCount int
+		// Get total part count for progress bar.
+		for _, vehicle := range vehicles {
+			totalPartCount += len(vehicle.Parts)
+		}
+		bar := newProgressBar(totalPartCount, showProgress)
+
+		for _, vehicle := range vehicles {
+			vehicleId := vehicle.Identifier
+			vehicleChanged := false
+			for _, part := range vehicle.Parts {
+				result, err := stmt.Exec(part.Name, part.Description, part.PartIdentifier, vehicleId, part.Price.Amount, part.Price.Currency, part.Price.VATIncluded, part.ImgUrl, part.ImgThumbUrl, part.PartNumber, part.ImgHash, part.ImgThumbHash)
+				if err != nil {
+					return err
+				}
+				affected, err := result.RowsAffected()
+				if err != nil {
+					return err
+				}
+				if affected == 0 {
+					var owner string
+					err = tx.QueryRow(ownerQuery, part.PartIdentifier).Scan(&owner)
+					if err != nil {
+						return err
+					}
+					if owner != vehicleId {
+						collisions = append(collisions, models.IdentifierCollision{Kind: models.KindPart, Identifier: part.PartIdentifier, Stored: owner, Scraped: vehicleId})
+						bar.Add(1)
+						continue
+					}
+				}
+				vehicleChanged = vehicleChanged || affected > 0
+				_, err = priceStmt.Exec(part.PartIdentifier, part.Price.Amount, part.Price.Currency, part.Price.VATIncluded)
+				if err != nil {
+					return err
+				}
+				bar.Add(1)
+			}
+			if vehicleChanged {
+				_, err = tx.Exec(touchVehicleQuery, vehicleId)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return collisionError(err, collisions)
 }
 
-func (handler *PSQLHandler) GetModelsForBrand(vehicleType string, brandName string) ([]string, error) {
-	rows, err := handler.DB.Query("SELECT DISTINCT(model_name) FROM Vehicles WHERE vehicle_type = $1 AND brand_name = $2 ORDER BY model_name ASC;", vehicleType, brandName)
-	if err != nil {
-		return nil, err
+// rekeyIdentifiers renames vehicles and parts in one transaction. The foreign
+// keys are deferred with deferQuery first. Each of vehicleQueries is run for
+// every vehicle change and then each of partQueries for every part change,
+// taking the new and the old identifier as parameters.
+func rekeyIdentifiers(db *sql.DB, deferQuery string, vehicleQueries []string, partQueries []string, vehicles []models.IdentifierChange, parts []models.IdentifierChange) error {
+	if len(vehicles) == 0 && len(parts) == 0 {
+		return nil
 	}
-
-	var models []string
-	for rows.Next() {
-		var model string
-		err = rows.Scan(&model)
+	return withTransaction(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(deferQuery)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		models = append(models, model)
-	}
-	return models, nil
+		for _, rekey := range []struct {
+			queries []string
+			changes []models.IdentifierChange
+		}{{vehicleQueries, vehicles}, {partQueries, parts}} {
+			for _, change := range rekey.changes {
+				for _, query := range rekey.queries {
+					_, err := tx.Exec(query, change.New, change.Old)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
 }
 
-func (handler *PSQLHandler) GetVehicle(vehicleType string, vehicleIdentifier string) (models.Vehicle, error) {
-	var vehicle models.Vehicle
-	err := handler.DB.QueryRow("SELECT vehicle_id, brand_name, model_name, vehicle_type, year, listing_url FROM Vehicles WHERE vehicle_type = $1 AND vehicle_id = $2 ORDER BY brand_name ASC;",
-		vehicleType, vehicleIdentifier).Scan(&vehicle.Identifier,
-		&vehicle.Brand, &vehicle.Model, &vehicle.VehicleType,
-		&vehicle.Year, &vehicle.Url)
-	if err != nil {
-		return vehicle, err
-	}
-	return vehicle, nil
+// execForEach runs each of the queries once for every identifier inside one transaction.
+func execForEach(db *sql.DB, identifiers []string, queries ...string) error {
+	return withTransaction(db, func(tx *sql.Tx) error {
+		for _, identifier := range identifiers {
+			for _, query := range queries {
+				_, err := tx.Exec(query, identifier)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
 }
 
-func (handler *PSQLHandler) GetVehicleTypes() ([]string, error) {
-	rows, err := handler.DB.Query("SELECT DISTINCT(vehicle_type) FROM Vehicles ORDER BY vehicle_type ASC;")
-	if err != nil {
-		log.Printf("error while getting vehicle types: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
-
-	var vehicleTypes []string
-	for rows.Next() {
-		var vehicleType string
-		err = rows.Scan(&vehicleType)
+// insertCompatibilities executes insertQuery for each compatibility. The query
+// takes part_id, brand_name, model_name, year_from, year_to, source and
+// model_key as parameters and must ignore compatibilities that are already
+// stored.
+func insertCompatibilities(db *sql.DB, insertQuery string, compatibilities []models.Compatibility) error {
+	return withTransaction(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(insertQuery)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		vehicleTypes = append(vehicleTypes, vehicleType)
-	}
-	return vehicleTypes, nil
+		defer stmt.Close()
+		for _, c := range compatibilities {
+			_, err := stmt.Exec(c.PartIdentifier, c.Brand, c.Model, c.YearFrom, c.YearTo, c.Source, modelname.Normalize(c.Model).Key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (handler *PSQLHandler) GetVehiclesForType(vehicleType string) ([]models.Vehicle, error) {
-	rows, err := handler.DB.Query("SELECT vehicle_id, brand_name, model_name, vehicle_type, year, listing_url FROM Vehicles WHERE vehicle_type = $1 ORDER BY brand_name ASC;", vehicleType)
-	if err != nil {
-		panic(err)
-	}
-	defer rows.Close()
-
-	var vehicles []models.Vehicle
-	for rows.Next() {
-		var vehicle models.Vehicle
-		err = rows.Scan(&vehicle.Identifier, &vehicle.Brand, &vehicle.Model, &vehicle.VehicleType, &vehicle.Year, &vehicle.Url)
+// insertImages executes insertQuery for each image. The query takes hash,
+// source_url, size, mime_type, width, height and phash as parameters and must
+// ignore images that are already stored.
+func insertImages(db *sql.DB, insertQuery string, images []models.Image) error {
+	return withTransaction(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(insertQuery)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		vehicles = append(vehicles, vehicle)
-	}
-	return vehicles, nil
+		defer stmt.Close()
+		for _, image := range images {
+			_, err := stmt.Exec(image.Hash, image.SourceURL, image.Size, image.MIMEType, image.Width, image.Height, image.PHash)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// queryImage runs a query selecting the hash, source_url, size, mime_type,
+// width, height and phash of an image.
+func queryImage(db *sql.DB, query string, args ...any) (models.Image, error) {
+	var image models.Image
+	err := db.QueryRow(query, args...).Scan(&image.Hash, &image.SourceURL, &image.Size, &image.MIMEType, &image.Width, &image.Height, &image.PHash)
+	return image, err
 }
 
-func (handler *PSQLHandler) GetVehiclesForModel(vehicleType string, brandName string, modelName string) ([]models.Vehicle, error) {
-	rows, err := handler.DB.Query("SELECT vehicle_id, brand_name, model_name, vehicle_type, year, listing_url FROM Vehicles WHERE vehicle_type = $1 AND brand_name = $2 AND model_name = $3 ORDER BY year ASC;", vehicleType, brandName, modelName)
+// queryStrings runs a query returning a single text column and collects the values.
+func queryStrings(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Printf("error while getting vehicles for model: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
-	var vehicles []models.Vehicle
+
+	var values []string
 	for rows.Next() {
-		var vehicle models.Vehicle
-		err = rows.Scan(&vehicle.Identifier, &vehicle.Brand, &vehicle.Model, &vehicle.VehicleType, &vehicle.Year, &vehicle.Url)
+		var value string
+		err = rows.Scan(&value)
 		if err != nil {
 			return nil, err
 		}
-		vehicles = append(vehicles, vehicle)
+		values = append(values, value)
 	}
-	return vehicles, nil
+	return values, rows.Err()
 }
 
-func (handler *PSQLHandler) GetPartsForVehicle(vehicleIdentifier string) (models.Vehicle, error) {
-	rows, err := handler.DB.Query("SELECT V.vehicle_id, V.year, V.model_name, V.brand_name, P.part_name, P.description, P.part_id, P.price, P.img_url, P.img_thumb_url FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.vehicle_id = $1 ORDER BY P.part_name ASC;", vehicleIdentifier)
+// queryVehicle runs a query selecting a single vehicle in the column order of vehicleListColumns.
+func queryVehicle(db *sql.DB, query string, args ...any) (models.Vehicle, error) {
 	var vehicle models.Vehicle
+	err := db.QueryRow(query, args...).Scan(vehicleColumns(&vehicle)...)
 	if err != nil {
-		log.Printf("error while getting parts for a vehicle: %v", err)
 		return vehicle, err
 	}
-	defer rows.Close()
-
-	vehicle.Parts = []models.Part{}
-	for rows.Next() {
-		var part models.Part
-		err := rows.Scan(
-			&vehicle.Identifier,
-			&vehicle.Year,
-			&vehicle.Model,
-			&vehicle.Brand,
-			&part.Name,
-			&part.Description,
-			&part.PartIdentifier,
-			&part.Price,
-			&part.ImgUrl,
-			&part.ImgThumbUrl,
-		)
-		if err != nil {
-			log.Printf("error while scanning database response for parts for a vehicle: %v", err)
-			return models.Vehicle{}, err
-		}
-		vehicle.Parts = append(vehicle.Parts, part)
-	}
 	return vehicle, nil
 }
 
-func (handler *PSQLHandler) GetPartsForModel(vehicleType string, brandName string, modelName string) ([]models.Vehicle, error) {
-	rows, err := handler.DB.Query("SELECT V.vehicle_id, V.year, V.model_name, V.brand_name, P.part_name, P.description, P.part_id, P.price, P.img_url, P.img_thumb_url FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.vehicle_type = $1 AND V.brand_name = $2 AND V.model_name = $3 ORDER BY V.year ASC;", vehicleType, brandName, modelName)
+// queryPricePoints runs a query selecting price_amount, price_currency,
+// price_vat_included and observed_at. No rows means the part does not exist,
+// as every stored part has been observed once.
+func queryPricePoints(db *sql.DB, query string, args ...any) ([]models.PricePoint, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Printf("error while getting parts for model: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	vehicleMap := make(map[string]models.Vehicle)
+	var pricePoints []models.PricePoint
 	for rows.Next() {
-		var vehicleID string
-		var vehicle models.Vehicle
-		var part models.Part
-		err = rows.Scan(&vehicleID,
-			&vehicle.Year,
-			&vehicle.Model,
-			&vehicle.Brand,
-			&part.Name,
-			&part.Description,
-			&part.PartIdentifier,
-			&part.Price,
-			&part.ImgUrl,
-			&part.ImgThumbUrl,
-		)
+		var pricePoint models.PricePoint
+		err = rows.Scan(&pricePoint.Price.Amount, &pricePoint.Price.Currency, &pricePoint.Price.VATIncluded, &pricePoint.ObservedAt)
 		if err != nil {
-			log.Printf("error while scanning database response for parts for model: %v", err)
 			return nil, err
 		}
-
-		// Check if a vehicle with the current vehicleID already exists in the vehicleMap
-		existingVehicle, exists := vehicleMap[vehicleID]
-		if exists {
-			// Append the part to the existing vehicle's Parts slice
-			existingVehicle.Parts = append(existingVehicle.Parts, part)
-			// Update the vehicleMap with the modified vehicle
-			vehicleMap[vehicleID] = existingVehicle
-		} else {
-			// Create a new vehicle object
-			vehicle.Identifier = vehicleID
-			// Initialize the Parts slice of the new vehicle with the current part
-			vehicle.Parts = []models.Part{part}
-			// Add the new vehicle to the vehicleMap
-			vehicleMap[vehicleID] = vehicle
-		}
-
+		pricePoints = append(pricePoints, pricePoint)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
+	if len(pricePoints) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return pricePoints, nil
+}
 
-	// Convert the map to a slice
-	vehicles := make([]models.Vehicle, 0, len(vehicleMap))
-	for _, value := range vehicleMap {
-		vehicles = append(vehicles, value)
+// scanSearchHit scans the leading columns into extra, followed by the hit
+// kind and the columns of partListColumns. The part columns are only kept for
+// part hits.
+func scanSearchHit(rows *sql.Rows, extra ...any) (models.SearchHit, error) {
+	var hit models.SearchHit
+	var part models.Part
+	dest := append(append(append(extra, &hit.Kind), vehicleColumns(&hit.Vehicle)...), partColumns(&part)...)
+	err := rows.Scan(dest...)
+	if err != nil {
+		return hit, err
 	}
+	if hit.Kind == models.SearchHitPart {
+		hit.Part = &part
+	}
+	return hit, nil
+}
 
-	return vehicles, nil
+// sortSearchHits orders hits by rank, vehicles before parts when the ranks
+// are equal, and keeps at most limit of them.
+func sortSearchHits(hits []models.SearchHit, limit int) []models.SearchHit {
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Kind != b.Kind {
+			return a.Kind == models.SearchHitVehicle
+		}
+		if a.Vehicle.Identifier != b.Vehicle.Identifier {
+			return a.Vehicle.Identifier < b.Vehicle.Identifier
+		}
+		return a.Part != nil && b.Part != nil && a.Part.PartIdentifier < b.Part.PartIdentifier
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
 }
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"database/sql"
+	"math/bits"
+	"sort"
+)
+
+// duplicateImagesQuery selects the mirrored photos of the listed parts: the
+// image columns followed by partListColumns. The query has no parameters and
+// is shared by the SQL dialects.
+const duplicateImagesQuery = `SELECT I.hash, I.source_url, I.size, I.mime_type, I.width, I.height, coalesce(I.phash, ''), ` + partListColumns + `
+FROM Vehicles V
+INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id
+INNER JOIN images I ON I.hash = P.img_hash
+WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL;`
+
+// imagePart is a listed part and the metadata of its mirrored photo.
+type imagePart struct {
+	image models.Image
+	part  models.VehicleAndPart
+}
+
+// queryDuplicateImages runs duplicateImagesQuery and groups the photos.
+func queryDuplicateImages(db *sql.DB, maxDistance int) ([]models.DuplicateImageGroup, error) {
+	rows, err := db.Query(duplicateImagesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var imageParts []imagePart
+	for rows.Next() {
+		var item imagePart
+		dest := append([]any{&item.image.Hash, &item.image.SourceURL, &item.image.Size, &item.image.MIMEType, &item.image.Width, &item.image.Height, &item.image.PHash},
+			vehicleColumns(&item.part.Vehicle)...)
+		if err := rows.Scan(append(dest, partColumns(&item.part.Part)...)...); err != nil {
+			return nil, err
+		}
+		imageParts = append(imageParts, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groupDuplicateImages(imageParts, maxDistance), nil
+}
+
+// groupDuplicateImages groups the photos whose perceptual hashes are at most
+// maxDistance bits apart. Photos without a perceptual hash are only grouped
+// with themselves. Only the groups used by parts of more than one vehicle are
+// returned, the groups with the most vehicles first.
+func groupDuplicateImages(imageParts []imagePart, maxDistance int) []models.DuplicateImageGroup {
+	var hashes []string
+	byHash := make(map[string]models.Image)
+	for _, item := range imageParts {
+		if _, exists := byHash[item.image.Hash]; !exists {
+			byHash[item.image.Hash] = item.image
+			hashes = append(hashes, item.image.Hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	// parent links the photos of a group to its first photo.
+	parent := make(map[string]string, len(hashes))
+	var root func(hash string) string
+	root = func(hash string) string {
+		if parent[hash] == hash {
+			return hash
+		}
+		parent[hash] = root(parent[hash])
+		return parent[hash]
+	}
+	for _, hash := range hashes {
+		parent[hash] = hash
+	}
+	for _, pair := range similarPairs(hashes, byHash, maxDistance) {
+		rootA, rootB := root(pair[0]), root(pair[1])
+		if rootA < rootB {
+			parent[rootB] = rootA
+		} else if rootB < rootA {
+			parent[rootA] = rootB
+		}
+	}
+
+	groups := make(map[string]*models.DuplicateImageGroup)
+	vehicles := make(map[string]map[string]bool)
+	for _, hash := range hashes {
+		group, exists := groups[root(hash)]
+		if !exists {
+			group = &models.DuplicateImageGroup{}
+			groups[root(hash)] = group
+			vehicles[root(hash)] = make(map[string]bool)
+		}
+		group.Images = append(group.Images, byHash[hash])
+	}
+	for _, item := range imageParts {
+		group := groups[root(item.image.Hash)]
+		group.Parts = append(group.Parts, item.part)
+		vehicles[root(item.image.Hash)][item.part.Vehicle.Identifier] = true
+	}
+
+	duplicates := []models.DuplicateImageGroup{}
+	for _, hash := range hashes {
+		if root(hash) != hash || len(vehicles[hash]) < 2 {
+			continue
+		}
+		group := groups[hash]
+		sort.Slice(group.Parts, func(i, j int) bool {
+			a, b := group.Parts[i], group.Parts[j]
+			if a.Vehicle.Identifier != b.Vehicle.Identifier {
+				return a.Vehicle.Identifier < b.Vehicle.Identifier
+			}
+			return a.Part.PartIdentifier < b.Part.PartIdentifier
+		})
+		duplicates = append(duplicates, *group)
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return len(vehicles[duplicates[i].Images[0].Hash]) > len(vehicles[duplicates[j].Images[0].Hash])
+	})
+	return duplicates
+}
+
+// similarPairs returns the pairs of photos whose perceptual hashes are at most
+// maxDistance bits apart, without comparing every pair. The 64 bits of the
+// hashes are split into maxDistance+1 runs. Hashes that close differ in at
+// most maxDistance runs and so agree on at least one, and only the hashes
+// that agree on a run are compared. Photos without a valid perceptual hash
+// are left out.
+func similarPairs(hashes []string, byHash map[string]models.Image, maxDistance int) [][2]string {
+	phashes := make(map[string]uint64, len(hashes))
+	for _, hash := range hashes {
+		if phash, err := images.ParseHash(byHash[hash].PHash); err == nil {
+			phashes[hash] = phash
+		}
+	}
+	runs := maxDistance + 1
+	if runs > 64 {
+		// Every pair is close enough, so a single bucket holds every hash.
+		runs = 1
+	}
+	seen := make(map[[2]string]bool)
+	var pairs [][2]string
+	for run := 0; run < runs; run++ {
+		low, high := run*64/runs, (run+1)*64/runs
+		mask := uint64(1)<<(high-low) - 1
+		if maxDistance >= 64 {
+			mask = 0
+		}
+		buckets := make(map[uint64][]string)
+		for _, hash := range hashes {
+			if phash, valid := phashes[hash]; valid {
+				key := phash >> low & mask
+				buckets[key] = append(buckets[key], hash)
+			}
+		}
+		for _, bucket := range buckets {
+			for i, a := range bucket {
+				for _, b := range bucket[i+1:] {
+					pair := [2]string{a, b}
+					if seen[pair] || bits.OnesCount64(phashes[a]^phashes[b]) > maxDistance {
+						continue
+					}
+					seen[pair] = true
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"Crawler/internal/images"
+	"Crawler/internal/models"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_similarPairs(t *testing.T) {
+	// Clusters of hashes a few bits apart, so that every distance finds pairs.
+	random := rand.New(rand.NewSource(1))
+	byHash := make(map[string]models.Image)
+	var hashes []string
+	for cluster := 0; cluster < 20; cluster++ {
+		center := random.Uint64()
+		for i := 0; i < 10; i++ {
+			phash := center
+			for flips := random.Intn(20); flips > 0; flips-- {
+				phash ^= 1 << random.Intn(64)
+			}
+			hash := fmt.Sprintf("%02d-%02d", cluster, i)
+			byHash[hash] = models.Image{Hash: hash, PHash: fmt.Sprintf("%016x", phash)}
+			hashes = append(hashes, hash)
+		}
+	}
+	byHash["invalid"] = models.Image{Hash: "invalid", PHash: "not a hash"}
+	byHash["missing"] = models.Image{Hash: "missing"}
+	hashes = append(hashes, "invalid", "missing")
+	sort.Strings(hashes)
+
+	for _, maxDistance := range []int{0, 1, 6, 16, 63, 64} {
+		t.Run(fmt.Sprintf("distance %d", maxDistance), func(t *testing.T) {
+			// The pairs found by comparing every pair of valid hashes.
+			want := [][2]string{}
+			for i, a := range hashes {
+				for _, b := range hashes[i+1:] {
+					distance, err := images.HashDistance(byHash[a].PHash, byHash[b].PHash)
+					if err == nil && distance <= maxDistance {
+						want = append(want, [2]string{a, b})
+					}
+				}
+			}
+			got := append([][2]string{}, similarPairs(hashes, byHash, maxDistance)...)
+			sort.Slice(got, func(i, j int) bool {
+				return got[i][0] < got[j][0] || got[i][0] == got[j][0] && got[i][1] < got[j][1]
+			})
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("similarPairs() found %d pairs, want %d", len(got), len(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"Crawler/internal/models"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vehicleSortColumns maps the sort keys of vehicle lists to columns.
+var vehicleSortColumns = map[string]string{
+	models.SortYear:      "V.year",
+	models.SortBrand:     "V.brand_name",
+	models.SortCreatedAt: "V.created_at",
+	models.SortUpdatedAt: "V.updated_at",
+}
+
+// partSortColumns maps the sort keys of part lists to columns.
+var partSortColumns = map[string]string{
+	models.SortYear:      "V.year",
+	models.SortBrand:     "V.brand_name",
+	models.SortPrice:     "P.price_amount",
+	models.SortName:      "coalesce(P.part_name, '')",
+	models.SortCreatedAt: "P.created_at",
+	models.SortUpdatedAt: "P.updated_at",
+}
+
+// vehicleListColumns are the vehicle columns selected by list queries.
+const vehicleListColumns = "V.vehicle_id, V.brand_name, V.model_name, V.vehicle_type, V.year, V.listing_url, V.created_at, V.updated_at" +
+	", coalesce(V.model_key, ''), coalesce(V.model_family, ''), coalesce(V.displacement, 0)" +
+	", coalesce(V.year_from, V.year), coalesce(V.year_to, V.year), coalesce(V.stroke, ''), coalesce(V.trim_level, '')"
+
+// partListColumns are the vehicle and part columns selected by part list queries.
+const partListColumns = vehicleListColumns + ", P.part_name, P.description, P.part_id, coalesce(P.part_number, ''), P.price_amount, P.price_currency, P.price_vat_included, P.img_url, P.img_thumb_url, coalesce(P.img_hash, ''), coalesce(P.img_thumb_hash, ''), P.created_at, P.updated_at"
+
+// vehicleColumns returns the scan destinations of vehicleListColumns.
+func vehicleColumns(vehicle *models.Vehicle) []any {
+	return []any{&vehicle.Identifier, &vehicle.Brand, &vehicle.Model, &vehicle.VehicleType, &vehicle.Year, &vehicle.Url, &vehicle.CreatedAt, &vehicle.UpdatedAt,
+		&vehicle.ModelKey, &vehicle.ModelFamily, &vehicle.Displacement, &vehicle.YearFrom, &vehicle.YearTo, &vehicle.Stroke, &vehicle.Trim}
+}
+
+// partColumns returns the scan destinations of the part columns of partListColumns.
+func partColumns(part *models.Part) []any {
+	return []any{&part.Name, &part.Description, &part.PartIdentifier, &part.PartNumber, &part.Price.Amount, &part.Price.Currency, &part.Price.VATIncluded, &part.ImgUrl, &part.ImgThumbUrl, &part.ImgHash, &part.ImgThumbHash, &part.CreatedAt, &part.UpdatedAt}
+}
+
+func postgresPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func sqlitePlaceholder(int) string {
+	return "?"
+}
+
+// listCursor is the position of the last item of a page: its sort value and
+// the identifier that breaks ties between equal sort values.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(cursor listCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// listOrder is the validated ordering and position of a list query.
+type listOrder struct {
+	key        string
+	descending bool
+	// after is nil for the first page.
+	after *listCursor
+	// currency limits the parts to the prices in it when it is not empty.
+	currency string
+}
+
+// parseListOptions validates the options of a list sortable by the keys of
+// sortColumns. The default sort may be descending too. Price filters only
+// apply to lists with prices, and limit them to a single currency as the
+// price sort does.
+func parseListOptions(options models.ListOptions, sortColumns map[string]string, defaultSort string, hasPrice bool) (listOrder, error) {
+	var order listOrder
+	sort := options.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	order.key, order.descending = strings.CutPrefix(sort, "-")
+	if _, exists := sortColumns[order.key]; !exists {
+		return order, fmt.Errorf("%w: cannot sort by %q", models.ErrInvalidListOptions, order.key)
+	}
+	if options.Limit < 0 {
+		return order, fmt.Errorf("%w: negative limit", models.ErrInvalidListOptions)
+	}
+	if !hasPrice && (options.MinPrice != nil || options.MaxPrice != nil || options.Currency != "") {
+		return order, fmt.Errorf("%w: cannot filter by price", models.ErrInvalidListOptions)
+	}
+	// Amounts in the minor units of different currencies do not compare.
+	if options.Currency != "" || options.MinPrice != nil || options.MaxPrice != nil || (hasPrice && order.key == models.SortPrice) {
+		order.currency = options.Currency
+		if order.currency == "" {
+			order.currency = models.DefaultCurrency
+		}
+	}
+	if options.Cursor != "" {
+		payload, err := base64.RawURLEncoding.DecodeString(options.Cursor)
+		if err != nil {
+			return order, fmt.Errorf("%w: malformed cursor", models.ErrInvalidListOptions)
+		}
+		var cursor listCursor
+		if err = json.Unmarshal(payload, &cursor); err != nil {
+			return order, fmt.Errorf("%w: malformed cursor", models.ErrInvalidListOptions)
+		}
+		// A cursor only continues a list in the order it was created for.
+		if cursor.Sort != options.Sort {
+			return order, fmt.Errorf("%w: cursor does not match sort %q", models.ErrInvalidListOptions, options.Sort)
+		}
+		order.after = &cursor
+	}
+	return order, nil
+}
+
+// listQuery is a SQL query of a vehicle or part list.
+type listQuery struct {
+	// columns are selected after the sort value and the identifier.
+	columns string
+	// from holds the FROM and WHERE clauses, with the bind parameters of args.
+	from string
+	args []any
+	// idColumn breaks ties between equal sort values.
+	idColumn    string
+	sortColumns map[string]string
+	defaultSort string
+	hasPrice    bool
+	placeholder func(n int) string
+}
+
+// build appends the filters, the position of the cursor, the ordering and the
+// limit of the options to the query. One row more than the limit is selected
+// to find out whether there is a next page.
+func (q listQuery) build(options models.ListOptions) (string, []any, string, error) {
+	order, err := parseListOptions(options, q.sortColumns, q.defaultSort, q.hasPrice)
+	if err != nil {
+		return "", nil, "", err
+	}
+	sortColumn := q.sortColumns[order.key]
+	sortValue := sortColumn
+	if order.key == models.SortCreatedAt || order.key == models.SortUpdatedAt {
+		// Timestamps are compared in the text form they were selected in.
+		sortValue = "CAST(" + sortColumn + " AS TEXT)"
+	}
+
+	args := append([]any{}, q.args...)
+	bind := func(value any) string {
+		args = append(args, value)
+		return q.placeholder(len(args))
+	}
+	var query strings.Builder
+	fmt.Fprintf(&query, "SELECT %s, %s, %s %s", sortValue, q.idColumn, q.columns, q.from)
+	// A vehicle of a year range is in the range of the year filters when the ranges overlap.
+	if options.MinYear != 0 {
+		fmt.Fprintf(&query, " AND coalesce(V.year_to, V.year) >= %s", bind(options.MinYear))
+	}
+	if options.MaxYear != 0 {
+		fmt.Fprintf(&query, " AND coalesce(V.year_from, V.year) <= %s", bind(options.MaxYear))
+	}
+	if options.MinDisplacement != 0 {
+		fmt.Fprintf(&query, " AND V.displacement >= %s", bind(options.MinDisplacement))
+	}
+	if options.MaxDisplacement != 0 {
+		// An unknown displacement is 0.
+		fmt.Fprintf(&query, " AND V.displacement > 0 AND V.displacement <= %s", bind(options.MaxDisplacement))
+	}
+	if options.Stroke != "" {
+		fmt.Fprintf(&query, " AND V.stroke = %s", bind(options.Stroke))
+	}
+	if order.currency != "" {
+		fmt.Fprintf(&query, " AND P.price_currency = %s", bind(order.currency))
+	}
+	if options.MinPrice != nil {
+		fmt.Fprintf(&query, " AND P.price_amount >= %s", bind(*options.MinPrice))
+	}
+	if options.MaxPrice != nil {
+		fmt.Fprintf(&query, " AND P.price_amount <= %s", bind(*options.MaxPrice))
+	}
+	if !options.UpdatedSince.IsZero() {
+		// SQLite compares timestamps as text in the format of current_timestamp.
+		fmt.Fprintf(&query, " AND %s >= %s", q.sortColumns[models.SortUpdatedAt], bind(options.UpdatedSince.UTC().Format(time.DateTime)))
+	}
+	direction, comparison := "ASC", ">"
+	if order.descending {
+		direction, comparison = "DESC", "<"
+	}
+	if order.after != nil {
+		fmt.Fprintf(&query, " AND (%s, %s) %s (%s, %s)", sortValue, q.idColumn, comparison, bind(order.after.Value), bind(order.after.ID))
+	}
+	fmt.Fprintf(&query, " ORDER BY %s %s, %s %s", sortValue, direction, q.idColumn, direction)
+	if options.Limit > 0 {
+		fmt.Fprintf(&query, " LIMIT %s", bind(options.Limit+1))
+	}
+	return query.String() + ";", args, options.Sort, nil
+}
+
+// runListQuery runs the list query and scans each row with scanRow, which
+// receives the destinations of the sort value and the identifier to scan
+// first. It returns the cursor of the next page.
+func runListQuery(db *sql.DB, q listQuery, options models.ListOptions, scanRow func(rows *sql.Rows, head ...any) error) (string, error) {
+	query, args, sort, err := q.build(options)
+	if err != nil {
+		return "", err
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var last listCursor
+	count := 0
+	for rows.Next() {
+		if options.Limit > 0 && count == options.Limit {
+			return encodeCursor(last), nil
+		}
+		var value any
+		var id string
+		err = scanRow(rows, &value, &id)
+		if err != nil {
+			return "", err
+		}
+		if bytes, isBytes := value.([]byte); isBytes {
+			value = string(bytes)
+		}
+		last = listCursor{Sort: sort, Value: value, ID: id}
+		count++
+	}
+	return "", rows.Err()
+}
+
+// queryVehiclePage runs a list query selecting vehicleListColumns.
+func queryVehiclePage(db *sql.DB, q listQuery, options models.ListOptions) (models.VehiclePage, error) {
+	q.columns = vehicleListColumns
+	q.idColumn = "V.vehicle_id"
+	q.sortColumns = vehicleSortColumns
+	page := models.VehiclePage{Vehicles: []models.Vehicle{}}
+	next, err := runListQuery(db, q, options, func(rows *sql.Rows, head ...any) error {
+		var vehicle models.Vehicle
+		err := rows.Scan(append(head, vehicleColumns(&vehicle)...)...)
+		page.Vehicles = append(page.Vehicles, vehicle)
+		return err
+	})
+	if err != nil {
+		return models.VehiclePage{}, err
+	}
+	page.NextCursor = next
+	return page, nil
+}
+
+// queryPartPage runs a list query selecting partListColumns.
+func queryPartPage(db *sql.DB, q listQuery, options models.ListOptions) (models.PartPage, error) {
+	q.columns = partListColumns
+	q.idColumn = "P.part_id"
+	q.sortColumns = partSortColumns
+	q.hasPrice = true
+	page := models.PartPage{Parts: []models.VehicleAndPart{}}
+	next, err := runListQuery(db, q, options, func(rows *sql.Rows, head ...any) error {
+		var vehicleAndPart models.VehicleAndPart
+		dest := append(append(head, vehicleColumns(&vehicleAndPart.Vehicle)...), partColumns(&vehicleAndPart.Part)...)
+		err := rows.Scan(dest...)
+		page.Parts = append(page.Parts, vehicleAndPart)
+		return err
+	})
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	page.NextCursor = next
+	return page, nil
+}
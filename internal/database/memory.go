@@ -0,0 +1,684 @@
+package database
+
+import (
+	"Crawler/internal/modelname"
+	"Crawler/internal/models"
+	"Crawler/internal/search"
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryHandler keeps vehicles and parts in memory. It mimics the behaviour of
+// the SQL handlers and is meant for tests and for serving crawler JSON output
+// without a database server.
+type MemoryHandler struct {
+	mu              sync.RWMutex
+	vehicles        map[string]*memoryVehicle
+	parts           map[string]*memoryPart
+	compatibilities map[compatibilityKey]models.Compatibility
+	images          map[string]models.Image
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// memoryVehicle is a stored vehicle row. The vehicle itself holds no parts.
+type memoryVehicle struct {
+	vehicle   models.Vehicle
+	createdAt time.Time
+	updatedAt time.Time
+	deleted   bool
+}
+
+// memoryPart is a stored part row.
+type memoryPart struct {
+	part      models.Part
+	vehicleID string
+	createdAt time.Time
+	updatedAt time.Time
+	deleted   bool
+	prices    []models.PricePoint
+}
+
+// compatibilityKey is the primary key of the part_compatibility table.
+type compatibilityKey struct {
+	partID   string
+	brand    string
+	model    string
+	yearFrom int
+	yearTo   int
+}
+
+// row returns the vehicle with the timestamps of the row.
+func (stored *memoryVehicle) row() models.Vehicle {
+	vehicle := stored.vehicle
+	vehicle.CreatedAt, vehicle.UpdatedAt = stored.createdAt, stored.updatedAt
+	return vehicle
+}
+
+// row returns the part with the timestamps of the row.
+func (stored *memoryPart) row() models.Part {
+	part := stored.part
+	part.CreatedAt, part.UpdatedAt = stored.createdAt, stored.updatedAt
+	return part
+}
+
+// CreateMemoryHandler returns an empty in-memory handler.
+func CreateMemoryHandler() *MemoryHandler {
+	return &MemoryHandler{
+		vehicles:        make(map[string]*memoryVehicle),
+		parts:           make(map[string]*memoryPart),
+		compatibilities: make(map[compatibilityKey]models.Compatibility),
+		images:          make(map[string]models.Image),
+		now:             time.Now,
+	}
+}
+
+// CreateMemoryHandlerFromDirectory returns an in-memory handler seeded from
+// every <category>_data.json file the crawler has written to dir.
+func CreateMemoryHandlerFromDirectory(dir string) (*MemoryHandler, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*_data.json"))
+	if err != nil {
+		return nil, err
+	}
+	handler := CreateMemoryHandler()
+	for _, path := range paths {
+		err = handler.LoadJSONFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Loaded %d vehicles from %d JSON files in %s", len(handler.vehicles), len(paths), dir)
+	return handler, nil
+}
+
+// ReadVehiclesFromJSONFile reads vehicles from a JSON file and returns a slice of vehicles
+func ReadVehiclesFromJSONFile(filePath string) ([]models.Vehicle, error) {
+	// Read the JSON file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Decode the JSON data into a slice of vehicles
+	var vehicles []models.Vehicle
+	err = json.NewDecoder(file).Decode(&vehicles)
+	if err != nil {
+		return nil, err
+	}
+
+	return vehicles, nil
+}
+
+// LoadJSONFile inserts the vehicles and parts of a crawler JSON output file.
+func (handler *MemoryHandler) LoadJSONFile(path string) error {
+	vehicles, err := ReadVehiclesFromJSONFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	err = handler.InsertVehicles(vehicles)
+	if err != nil {
+		return err
+	}
+	return handler.InsertParts(vehicles)
+}
+
+func (handler *MemoryHandler) Close() error {
+	return nil
+}
+
+func (handler *MemoryHandler) InsertVehicles(vehicles []models.Vehicle) error {
+	duplicates := hasDuplicateVehicleIDs(vehicles)
+	if duplicates {
+		return errors.New("duplicate id found")
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	now := handler.now()
+	var collisions []models.IdentifierCollision
+	for _, vehicle := range vehicles {
+		vehicle = withYearRange(withModelName(vehicle.Scraped()))
+		vehicle.Name = ""
+		vehicle.Parts = nil
+		stored, exists := handler.vehicles[vehicle.Identifier]
+		if !exists {
+			handler.vehicles[vehicle.Identifier] = &memoryVehicle{vehicle: vehicle, createdAt: now, updatedAt: now}
+			continue
+		}
+		if stored.vehicle.Url != vehicle.Url {
+			collisions = append(collisions, models.IdentifierCollision{Kind: models.KindVehicle, Identifier: vehicle.Identifier, Stored: stored.vehicle.Url, Scraped: vehicle.Url})
+			continue
+		}
+		if !reflect.DeepEqual(stored.vehicle, vehicle) || stored.deleted {
+			stored.vehicle = vehicle
+			stored.updatedAt = now
+			stored.deleted = false
+		}
+	}
+	return collisionError(nil, collisions)
+}
+
+// InsertParts adds the parts of already inserted vehicles.
+func (handler *MemoryHandler) InsertParts(vehicles []models.Vehicle) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	now := handler.now()
+	var collisions []models.IdentifierCollision
+	for _, vehicle := range vehicles {
+		storedVehicle, exists := handler.vehicles[vehicle.Identifier]
+		if !exists && len(vehicle.Parts) > 0 {
+			return fmt.Errorf("vehicle %s does not exist", vehicle.Identifier)
+		}
+		vehicleChanged := false
+		for _, part := range vehicle.Parts {
+			part = part.Scraped()
+			stored, exists := handler.parts[part.PartIdentifier]
+			if !exists {
+				stored = &memoryPart{part: part, vehicleID: vehicle.Identifier, createdAt: now, updatedAt: now}
+				handler.parts[part.PartIdentifier] = stored
+				vehicleChanged = true
+			} else if stored.vehicleID != vehicle.Identifier {
+				collisions = append(collisions, models.IdentifierCollision{Kind: models.KindPart, Identifier: part.PartIdentifier, Stored: stored.vehicleID, Scraped: vehicle.Identifier})
+				continue
+			} else if stored.part != part || stored.deleted {
+				stored.part = part
+				stored.updatedAt = now
+				stored.deleted = false
+				vehicleChanged = true
+			}
+			if len(stored.prices) == 0 || stored.prices[len(stored.prices)-1].Price != part.Price {
+				stored.prices = append(stored.prices, models.PricePoint{Price: part.Price, ObservedAt: now})
+			}
+		}
+		if vehicleChanged {
+			storedVehicle.updatedAt = now
+		}
+	}
+	return collisionError(nil, collisions)
+}
+
+func (handler *MemoryHandler) RekeyIdentifiers(vehicles []models.IdentifierChange, parts []models.IdentifierChange) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	for _, change := range vehicles {
+		stored, exists := handler.vehicles[change.Old]
+		if !exists {
+			continue
+		}
+		delete(handler.vehicles, change.Old)
+		stored.vehicle.Identifier = change.New
+		handler.vehicles[change.New] = stored
+		for _, part := range handler.parts {
+			if part.vehicleID == change.Old {
+				part.vehicleID = change.New
+			}
+		}
+	}
+	for _, change := range parts {
+		stored, exists := handler.parts[change.Old]
+		if !exists {
+			continue
+		}
+		delete(handler.parts, change.Old)
+		stored.part.PartIdentifier = change.New
+		handler.parts[change.New] = stored
+		for key, compatibility := range handler.compatibilities {
+			if key.partID == change.Old {
+				delete(handler.compatibilities, key)
+				key.partID = change.New
+				compatibility.PartIdentifier = change.New
+				handler.compatibilities[key] = compatibility
+			}
+		}
+	}
+	return nil
+}
+
+func (handler *MemoryHandler) DeleteVehicles(vehicleIdentifiers []string) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	now := handler.now()
+	for _, vehicleID := range vehicleIdentifiers {
+		stored, exists := handler.vehicles[vehicleID]
+		if !exists || stored.deleted {
+			continue
+		}
+		stored.deleted = true
+		stored.updatedAt = now
+		for _, part := range handler.parts {
+			if part.vehicleID == vehicleID && !part.deleted {
+				part.deleted = true
+				part.updatedAt = now
+			}
+		}
+	}
+	return nil
+}
+
+func (handler *MemoryHandler) DeleteParts(partIdentifiers []string) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	now := handler.now()
+	for _, partID := range partIdentifiers {
+		stored, exists := handler.parts[partID]
+		if !exists || stored.deleted {
+			continue
+		}
+		stored.deleted = true
+		stored.updatedAt = now
+		if vehicle, exists := handler.vehicles[stored.vehicleID]; exists {
+			vehicle.updatedAt = now
+		}
+	}
+	return nil
+}
+
+func (handler *MemoryHandler) GetVehicleCount() (int, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	count := 0
+	for _, stored := range handler.vehicles {
+		if !stored.deleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// filterVehicles returns the vehicles that are not deleted and are accepted by keep.
+func (handler *MemoryHandler) filterVehicles(keep func(vehicle *models.Vehicle) bool) []models.Vehicle {
+	var vehicles []models.Vehicle
+	for _, stored := range handler.vehicles {
+		if !stored.deleted && keep(&stored.vehicle) {
+			vehicles = append(vehicles, stored.vehicle)
+		}
+	}
+	return vehicles
+}
+
+// distinct returns the sorted distinct values picked from the vehicles accepted by keep.
+func (handler *MemoryHandler) distinct(keep func(vehicle *models.Vehicle) bool, pick func(vehicle *models.Vehicle) string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, vehicle := range handler.filterVehicles(keep) {
+		value := pick(&vehicle)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (handler *MemoryHandler) GetVehicleTypes() ([]string, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	return handler.distinct(func(*models.Vehicle) bool { return true },
+		func(vehicle *models.Vehicle) string { return vehicle.VehicleType }), nil
+}
+
+func (handler *MemoryHandler) GetBrands(vehicleType string) ([]string, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	return handler.distinct(func(vehicle *models.Vehicle) bool { return vehicle.VehicleType == vehicleType },
+		func(vehicle *models.Vehicle) string { return vehicle.Brand }), nil
+}
+
+func (handler *MemoryHandler) GetModelsForBrand(vehicleType string, brandName string) ([]string, error) {
+	families, err := handler.GetModelFamilies(vehicleType, brandName)
+	if err != nil {
+		return nil, err
+	}
+	return modelNames(families), nil
+}
+
+func (handler *MemoryHandler) GetModelFamilies(vehicleType string, brandName string) ([]models.ModelFamily, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	type spelling struct {
+		model, key, family string
+		displacement       int
+	}
+	counts := make(map[spelling]int)
+	for _, vehicle := range handler.filterVehicles(func(vehicle *models.Vehicle) bool {
+		return vehicle.VehicleType == vehicleType && vehicle.Brand == brandName
+	}) {
+		counts[spelling{vehicle.Model, vehicle.ModelKey, vehicle.ModelFamily, vehicle.Displacement}]++
+	}
+	names := make([]modelNameRow, 0, len(counts))
+	for s, count := range counts {
+		vehicle := models.Vehicle{Model: s.model, ModelKey: s.key, ModelFamily: s.family, Displacement: s.displacement}
+		names = append(names, modelNameRow{vehicle: vehicle, count: count})
+	}
+	return groupModelFamilies(names), nil
+}
+
+func (handler *MemoryHandler) GetVehicle(vehicleType string, vehicleIdentifier string) (models.Vehicle, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	stored, exists := handler.vehicles[vehicleIdentifier]
+	if !exists || stored.deleted || stored.vehicle.VehicleType != vehicleType {
+		return models.Vehicle{}, sql.ErrNoRows
+	}
+	return stored.row(), nil
+}
+
+// memoryListItem is a vehicle or part of a list with the values it is sorted by.
+type memoryListItem struct {
+	id             string
+	values         map[string]any
+	vehicleAndPart models.VehicleAndPart
+}
+
+// memoryTimeLayout formats timestamps so that they sort as text.
+const memoryTimeLayout = "2006-01-02 15:04:05.000000000"
+
+func vehicleListItem(stored *memoryVehicle) memoryListItem {
+	return memoryListItem{
+		id: stored.vehicle.Identifier,
+		values: map[string]any{
+			models.SortYear:      float64(stored.vehicle.Year),
+			models.SortBrand:     stored.vehicle.Brand,
+			models.SortCreatedAt: stored.createdAt.UTC().Format(memoryTimeLayout),
+			models.SortUpdatedAt: stored.updatedAt.UTC().Format(memoryTimeLayout),
+		},
+		vehicleAndPart: models.VehicleAndPart{Vehicle: stored.row()},
+	}
+}
+
+func partListItem(part *memoryPart, vehicle *memoryVehicle) memoryListItem {
+	return memoryListItem{
+		id: part.part.PartIdentifier,
+		values: map[string]any{
+			models.SortYear:      float64(vehicle.vehicle.Year),
+			models.SortBrand:     vehicle.vehicle.Brand,
+			models.SortPrice:     float64(part.part.Price.Amount),
+			models.SortName:      part.part.Name,
+			models.SortCreatedAt: part.createdAt.UTC().Format(memoryTimeLayout),
+			models.SortUpdatedAt: part.updatedAt.UTC().Format(memoryTimeLayout),
+		},
+		vehicleAndPart: models.VehicleAndPart{Part: part.row(), Vehicle: vehicle.row()},
+	}
+}
+
+// compareListValues compares sort values, which are numbers or text.
+func compareListValues(a any, b any) int {
+	x, aIsNumber := a.(float64)
+	y, bIsNumber := b.(float64)
+	if aIsNumber && bIsNumber {
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// pageList filters, orders and pages list items like the SQL list queries do.
+func pageList(items []memoryListItem, options models.ListOptions, sortColumns map[string]string, defaultSort string, hasPrice bool) ([]memoryListItem, string, error) {
+	order, err := parseListOptions(options, sortColumns, defaultSort, hasPrice)
+	if err != nil {
+		return nil, "", err
+	}
+	compare := func(item memoryListItem, value any, id string) int {
+		c := compareListValues(item.values[order.key], value)
+		if c == 0 {
+			c = strings.Compare(item.id, id)
+		}
+		if order.descending {
+			return -c
+		}
+		return c
+	}
+
+	var page []memoryListItem
+	for _, item := range items {
+		vehicle := item.vehicleAndPart.Vehicle
+		if (options.MinYear != 0 && vehicle.YearTo < options.MinYear) || (options.MaxYear != 0 && vehicle.YearFrom > options.MaxYear) {
+			continue
+		}
+		if (options.MinDisplacement != 0 && vehicle.Displacement < options.MinDisplacement) ||
+			(options.MaxDisplacement != 0 && (vehicle.Displacement == 0 || vehicle.Displacement > options.MaxDisplacement)) {
+			continue
+		}
+		if options.Stroke != "" && vehicle.Stroke != options.Stroke {
+			continue
+		}
+		if order.currency != "" && item.vehicleAndPart.Part.Price.Currency != order.currency {
+			continue
+		}
+		if price, isPart := item.values[models.SortPrice].(float64); isPart &&
+			((options.MinPrice != nil && price < float64(*options.MinPrice)) || (options.MaxPrice != nil && price > float64(*options.MaxPrice))) {
+			continue
+		}
+		if !options.UpdatedSince.IsZero() && compareListValues(item.values[models.SortUpdatedAt], options.UpdatedSince.UTC().Format(memoryTimeLayout)) < 0 {
+			continue
+		}
+		if order.after != nil && compare(item, order.after.Value, order.after.ID) <= 0 {
+			continue
+		}
+		page = append(page, item)
+	}
+	sort.Slice(page, func(i, j int) bool { return compare(page[i], page[j].values[order.key], page[j].id) < 0 })
+
+	if options.Limit == 0 || len(page) <= options.Limit {
+		return page, "", nil
+	}
+	last := page[options.Limit-1]
+	return page[:options.Limit], encodeCursor(listCursor{Sort: options.Sort, Value: last.values[order.key], ID: last.id}), nil
+}
+
+// listVehicles returns a page of the vehicles that are not deleted and are accepted by keep.
+func (handler *MemoryHandler) listVehicles(options models.ListOptions, defaultSort string, keep func(vehicle *models.Vehicle) bool) (models.VehiclePage, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	var items []memoryListItem
+	for _, stored := range handler.vehicles {
+		if !stored.deleted && keep(&stored.vehicle) {
+			items = append(items, vehicleListItem(stored))
+		}
+	}
+	items, next, err := pageList(items, options, vehicleSortColumns, defaultSort, false)
+	if err != nil {
+		return models.VehiclePage{}, err
+	}
+	page := models.VehiclePage{Vehicles: []models.Vehicle{}, NextCursor: next}
+	for _, item := range items {
+		page.Vehicles = append(page.Vehicles, item.vehicleAndPart.Vehicle)
+	}
+	return page, nil
+}
+
+// listParts returns a page of the parts that are not deleted, belong to a
+// vehicle that is not deleted and are accepted by keep.
+func (handler *MemoryHandler) listParts(options models.ListOptions, defaultSort string, keep func(part *memoryPart, vehicle *models.Vehicle) bool) (models.PartPage, error) {
+	var items []memoryListItem
+	for _, part := range handler.parts {
+		vehicle, exists := handler.vehicles[part.vehicleID]
+		if !part.deleted && exists && !vehicle.deleted && keep(part, &vehicle.vehicle) {
+			items = append(items, partListItem(part, vehicle))
+		}
+	}
+	items, next, err := pageList(items, options, partSortColumns, defaultSort, true)
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	page := models.PartPage{Parts: []models.VehicleAndPart{}, NextCursor: next}
+	for _, item := range items {
+		page.Parts = append(page.Parts, item.vehicleAndPart)
+	}
+	return page, nil
+}
+
+func (handler *MemoryHandler) GetVehiclesForType(vehicleType string, options models.ListOptions) (models.VehiclePage, error) {
+	return handler.listVehicles(options, models.SortBrand, func(vehicle *models.Vehicle) bool {
+		return vehicle.VehicleType == vehicleType
+	})
+}
+
+func (handler *MemoryHandler) GetVehiclesForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.VehiclePage, error) {
+	modelKey := modelname.Normalize(modelName).Key
+	return handler.listVehicles(options, models.SortYear, func(vehicle *models.Vehicle) bool {
+		return vehicle.VehicleType == vehicleType && vehicle.Brand == brandName && vehicle.ModelKey == modelKey
+	})
+}
+
+func (handler *MemoryHandler) GetPartsForVehicle(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	stored, exists := handler.vehicles[vehicleIdentifier]
+	if !exists || stored.deleted || stored.vehicle.VehicleType != vehicleType {
+		return models.PartPage{}, sql.ErrNoRows
+	}
+	return handler.listParts(options, models.SortName, func(part *memoryPart, vehicle *models.Vehicle) bool {
+		return vehicle.Identifier == vehicleIdentifier
+	})
+}
+
+func (handler *MemoryHandler) GetPartsForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.PartPage, error) {
+	modelKey := modelname.Normalize(modelName).Key
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	return handler.listParts(options, models.SortYear, func(part *memoryPart, vehicle *models.Vehicle) bool {
+		return vehicle.VehicleType == vehicleType && vehicle.Brand == brandName && vehicle.ModelKey == modelKey
+	})
+}
+
+func (handler *MemoryHandler) GetRecentVehicles(options models.ListOptions) (models.VehiclePage, error) {
+	return handler.listVehicles(options, "-"+models.SortUpdatedAt, func(vehicle *models.Vehicle) bool { return true })
+}
+
+func (handler *MemoryHandler) GetRecentParts(options models.ListOptions) (models.PartPage, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	return handler.listParts(options, "-"+models.SortUpdatedAt, func(part *memoryPart, vehicle *models.Vehicle) bool { return true })
+}
+
+func (handler *MemoryHandler) GetPartPriceHistory(vehicleType string, vehicleIdentifier string, partIdentifier string) ([]models.PricePoint, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	part, exists := handler.parts[partIdentifier]
+	if !exists || part.deleted || part.vehicleID != vehicleIdentifier {
+		return nil, sql.ErrNoRows
+	}
+	vehicle, exists := handler.vehicles[vehicleIdentifier]
+	if !exists || vehicle.deleted || vehicle.vehicle.VehicleType != vehicleType {
+		return nil, sql.ErrNoRows
+	}
+	return append([]models.PricePoint{}, part.prices...), nil
+}
+
+func (handler *MemoryHandler) InsertCompatibilities(compatibilities []models.Compatibility) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	for _, compatibility := range compatibilities {
+		if _, exists := handler.parts[compatibility.PartIdentifier]; !exists {
+			return fmt.Errorf("part %s does not exist", compatibility.PartIdentifier)
+		}
+		key := compatibilityKey{compatibility.PartIdentifier, compatibility.Brand, compatibility.Model, compatibility.YearFrom, compatibility.YearTo}
+		if _, exists := handler.compatibilities[key]; !exists {
+			handler.compatibilities[key] = compatibility
+		}
+	}
+	return nil
+}
+
+func (handler *MemoryHandler) InsertImages(images []models.Image) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	for _, image := range images {
+		if _, exists := handler.images[image.Hash]; !exists {
+			handler.images[image.Hash] = image
+		}
+	}
+	return nil
+}
+
+func (handler *MemoryHandler) GetImage(hash string) (models.Image, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	image, exists := handler.images[hash]
+	if !exists {
+		return models.Image{}, sql.ErrNoRows
+	}
+	return image, nil
+}
+
+func (handler *MemoryHandler) GetDuplicateImages(maxDistance int) ([]models.DuplicateImageGroup, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	var imageParts []imagePart
+	for _, part := range handler.parts {
+		vehicle := handler.vehicles[part.vehicleID]
+		image, exists := handler.images[part.part.ImgHash]
+		if part.deleted || vehicle == nil || vehicle.deleted || !exists {
+			continue
+		}
+		imageParts = append(imageParts, imagePart{image: image, part: models.VehicleAndPart{Part: part.row(), Vehicle: vehicle.row()}})
+	}
+	return groupDuplicateImages(imageParts, maxDistance), nil
+}
+
+func (handler *MemoryHandler) GetCompatibleParts(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	target, exists := handler.vehicles[vehicleIdentifier]
+	if !exists || target.deleted || target.vehicle.VehicleType != vehicleType {
+		return models.PartPage{}, sql.ErrNoRows
+	}
+	compatible := make(map[string]bool)
+	for _, compatibility := range handler.compatibilities {
+		// Every spelling of the model is compatible.
+		if compatibility.Brand == target.vehicle.Brand && modelname.Normalize(compatibility.Model).Key == target.vehicle.ModelKey &&
+			target.vehicle.Year >= compatibility.YearFrom && target.vehicle.Year <= compatibility.YearTo {
+			compatible[compatibility.PartIdentifier] = true
+		}
+	}
+	return handler.listParts(options, models.SortName, func(part *memoryPart, vehicle *models.Vehicle) bool {
+		return compatible[part.part.PartIdentifier] && vehicle.Identifier != vehicleIdentifier
+	})
+}
+
+// Search matches the query against the brand and model of vehicles and
+// against the brand, model, name and description of parts.
+func (handler *MemoryHandler) Search(query string, limit int) ([]models.SearchHit, error) {
+	root, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	hits := []models.SearchHit{}
+	for _, vehicle := range handler.vehicles {
+		words := search.Tokenize(vehicle.vehicle.Brand + " " + vehicle.vehicle.Model)
+		if !vehicle.deleted && search.Match(root, words) {
+			hits = append(hits, models.SearchHit{Kind: models.SearchHitVehicle, Rank: search.Rank(root, words), Vehicle: vehicle.row()})
+		}
+	}
+	for _, part := range handler.parts {
+		vehicle, exists := handler.vehicles[part.vehicleID]
+		if part.deleted || !exists || vehicle.deleted {
+			continue
+		}
+		words := search.Tokenize(vehicle.vehicle.Brand + " " + vehicle.vehicle.Model + " " + part.part.Name + " " + part.part.Description)
+		if search.Match(root, words) {
+			hitPart := part.row()
+			hits = append(hits, models.SearchHit{Kind: models.SearchHitPart, Rank: search.Rank(root, words), Vehicle: vehicle.row(), Part: &hitPart})
+		}
+	}
+	return sortSearchHits(hits, limit), nil
+}
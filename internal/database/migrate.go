@@ -0,0 +1,330 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrSchemaOutdated is returned when the database has migrations that are not applied yet.
+var ErrSchemaOutdated = errors.New("database schema is out of date, run `migrate up`")
+
+// ErrNoLegacySchema is returned by Baseline when the database does not have
+// the tables created before migrations, or already has migrations applied.
+var ErrNoLegacySchema = errors.New("database does not have the schema from before migrations")
+
+// Migration is one versioned schema change with the SQL to apply and revert it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied to the database.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations of one SQL dialect and keeps track
+// of them in the schema_migrations table.
+type Migrator struct {
+	DB         *sql.DB
+	Driver     string
+	Migrations []Migration
+}
+
+// NewMigrator returns a migrator for the migrations of the given driver ("postgres" or "sqlite").
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Driver: driver, Migrations: migrations}, nil
+}
+
+// loadMigrations reads the <version>_<name>.up.sql and .down.sql files of a
+// driver and returns them ordered by version.
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionText, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionText)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// placeholder returns the n:th bind parameter in the syntax of the driver.
+func (m *Migrator) placeholder(n int) string {
+	if m.Driver == "postgres" {
+		return postgresPlaceholder(n)
+	}
+	return sqlitePlaceholder(n)
+}
+
+func (m *Migrator) ensureBookkeepingTable() error {
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP DEFAULT current_timestamp
+);`)
+	return err
+}
+
+// tableExists tells whether the database has a table of the given lower case name.
+func (m *Migrator) tableExists(name string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND lower(name) = ?);"
+	if m.Driver == "postgres" {
+		query = "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1);"
+	}
+	var exists bool
+	err := m.DB.QueryRow(query, name).Scan(&exists)
+	return exists, err
+}
+
+// applied returns the applied migration versions and when they were applied.
+// A database without the bookkeeping table has none applied; the table is
+// only created by the commands that change the schema.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	exists, err := m.tableExists("schema_migrations")
+	if err != nil || !exists {
+		return map[int]time.Time{}, err
+	}
+	rows, err := m.DB.Query("SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		appliedAt, applied := versions[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: applied, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// run executes the migration SQL and the bookkeeping statement in one transaction.
+//
+// SQLite can only change table constraints by rebuilding the table, which the
+// foreign keys of other tables would prevent. As SQLite recommends, foreign
+// key enforcement is switched off for the duration of the migration and the
+// constraints are verified before committing. The SQLite handler uses a single
+// connection, so the pragma applies to the transaction.
+func (m *Migrator) run(migrationSQL string, bookkeeping string, args ...any) error {
+	if m.Driver == "sqlite" {
+		_, err := m.DB.Exec("PRAGMA foreign_keys = OFF;")
+		if err != nil {
+			return err
+		}
+		defer m.DB.Exec("PRAGMA foreign_keys = ON;")
+	}
+	return withTransaction(m.DB, func(tx *sql.Tx) error {
+		_, err := tx.Exec(migrationSQL)
+		if err != nil {
+			return err
+		}
+		if m.Driver == "sqlite" {
+			var table string
+			err = tx.QueryRow("PRAGMA foreign_key_check;").Scan(&table)
+			if err == nil {
+				return fmt.Errorf("migration leaves rows in %s violating foreign keys", table)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		_, err = tx.Exec(bookkeeping, args...)
+		return err
+	})
+}
+
+// hasLegacySchema tells whether the database was created from the schema
+// file used before migrations: it has the tables of the initial migration
+// but no migration has been applied.
+func (m *Migrator) hasLegacySchema() (bool, error) {
+	versions, err := m.applied()
+	if err != nil || len(versions) > 0 {
+		return false, err
+	}
+	for _, table := range []string{"vehicles", "parts"} {
+		exists, err := m.tableExists(table)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Baseline records the initial migration as applied without running it, so
+// that a database created from the schema file used before migrations can
+// be migrated from there. It returns ErrNoLegacySchema for other databases.
+func (m *Migrator) Baseline() (Migration, error) {
+	legacy, err := m.hasLegacySchema()
+	if err != nil {
+		return Migration{}, err
+	}
+	if !legacy {
+		return Migration{}, ErrNoLegacySchema
+	}
+	if err := m.ensureBookkeepingTable(); err != nil {
+		return Migration{}, err
+	}
+	initial := m.Migrations[0]
+	_, err = m.DB.Exec(fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%s, %s);", m.placeholder(1), m.placeholder(2)),
+		initial.Version, initial.Name)
+	return initial, err
+}
+
+// Up applies every pending migration in version order and returns the applied
+// ones. A database created before migrations is baselined first.
+func (m *Migrator) Up() ([]Migration, error) {
+	legacy, err := m.hasLegacySchema()
+	if err != nil {
+		return nil, err
+	}
+	if legacy {
+		if _, err := m.Baseline(); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.ensureBookkeepingTable(); err != nil {
+		return nil, err
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		migration := status.Migration
+		err = m.run(migration.Up, fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%s, %s);", m.placeholder(1), m.placeholder(2)),
+			migration.Version, migration.Name)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down reverts the latest applied migration and returns it. It returns
+// false if there is nothing to revert.
+func (m *Migrator) Down() (Migration, bool, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return Migration{}, false, err
+	}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+		migration := statuses[i].Migration
+		err = m.run(migration.Down, fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %s;", m.placeholder(1)), migration.Version)
+		if err != nil {
+			return migration, false, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		return migration, true, nil
+	}
+	return Migration{}, false, nil
+}
+
+// CheckCurrent returns ErrSchemaOutdated if any migration is still pending.
+// It only reads the database, an uninitialised one is reported as such.
+func (m *Migrator) CheckCurrent() error {
+	exists, err := m.tableExists("schema_migrations")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: database is not initialised", ErrSchemaOutdated)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			return fmt.Errorf("%w: migration %d (%s) is pending", ErrSchemaOutdated, status.Version, status.Name)
+		}
+	}
+	return nil
+}
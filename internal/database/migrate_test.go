@@ -0,0 +1,186 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	postgres, err := loadMigrations("postgres")
+	if err != nil {
+		t.Fatalf("loadMigrations(postgres) error = %v", err)
+	}
+	sqlite, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("loadMigrations(sqlite) error = %v", err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != i+1 {
+			t.Errorf("migration %d has version %d, versions must be contiguous", i, postgres[i].Version)
+		}
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("postgres migration %d_%s does not match sqlite %d_%s", postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+	if _, err := loadMigrations("oracle"); err == nil {
+		t.Errorf("loadMigrations(oracle) should fail")
+	}
+}
+
+func Test_Migrator(t *testing.T) {
+	handler, err := CreateSQLiteHandler(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLiteHandler() error = %v", err)
+	}
+	defer handler.Close()
+	migrator, err := NewMigrator(handler.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+
+	if err := migrator.CheckCurrent(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckCurrent() on empty database = %v, want ErrSchemaOutdated", err)
+	}
+	if _, err := migrator.Baseline(); !errors.Is(err, ErrNoLegacySchema) {
+		t.Errorf("Baseline() on empty database error = %v, want ErrNoLegacySchema", err)
+	}
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.Migrations) {
+		t.Fatalf("Up() = %d migrations, %v, want %d", len(applied), err, len(migrator.Migrations))
+	}
+	if err := migrator.CheckCurrent(); err != nil {
+		t.Errorf("CheckCurrent() after Up() = %v", err)
+	}
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("second Up() = %d migrations, %v, want none", len(applied), err)
+	}
+
+	latest := migrator.Migrations[len(migrator.Migrations)-1]
+	reverted, ok, err := migrator.Down()
+	if err != nil || !ok || reverted.Version != latest.Version {
+		t.Fatalf("Down() = %d, %v, %v, want %d", reverted.Version, ok, err, latest.Version)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if statuses[len(statuses)-1].Applied {
+		t.Errorf("latest migration is still applied after Down()")
+	}
+	if err := migrator.CheckCurrent(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckCurrent() after Down() = %v, want ErrSchemaOutdated", err)
+	}
+
+	for {
+		_, ok, err := migrator.Down()
+		if err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Errorf("Up() after reverting everything = %v", err)
+	}
+}
+
+// legacySQLiteSchema is the schema the SQLite handler created before
+// migrations, mirroring the PostgreSQL schema of Database-Creation-Commands.txt.
+const legacySQLiteSchema = `
+CREATE TABLE IF NOT EXISTS Vehicles (
+    vehicle_id VARCHAR(100) PRIMARY KEY,
+    listing_url VARCHAR(100) NOT NULL,
+    brand_name VARCHAR(50) NOT NULL,
+    model_name VARCHAR(50) NOT NULL,
+    year INTEGER NOT NULL,
+    vehicle_type VARCHAR(30),
+    created_at TIMESTAMP DEFAULT current_timestamp,
+    CONSTRAINT unique_vehicle UNIQUE (brand_name, model_name, year)
+);
+
+CREATE TABLE IF NOT EXISTS Parts (
+    part_id VARCHAR(50) PRIMARY KEY,
+    vehicle_id VARCHAR(100) REFERENCES Vehicles(vehicle_id),
+    img_url VARCHAR(255),
+    img_thumb_url VARCHAR(255),
+    part_name VARCHAR(255),
+    description VARCHAR(255),
+    price FLOAT,
+    created_at TIMESTAMP DEFAULT current_timestamp,
+    CONSTRAINT unique_part UNIQUE (part_id, vehicle_id)
+);
+`
+
+func Test_Migrator_legacySchema(t *testing.T) {
+	handler, err := CreateSQLiteHandler(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLiteHandler() error = %v", err)
+	}
+	defer handler.Close()
+	if _, err := handler.DB.Exec(legacySQLiteSchema); err != nil {
+		t.Fatalf("creating the legacy schema failed: %v", err)
+	}
+	_, err = handler.DB.Exec(`INSERT INTO Vehicles (vehicle_id, listing_url, brand_name, model_name, year, vehicle_type) VALUES ('1', 'https://www.purkuosat.net/suzukirx19.htm', 'Suzuki', 'RX', 2019, 'moped');
+INSERT INTO Parts (part_id, vehicle_id, part_name, price) VALUES ('11', '1', 'Satula', 30);`)
+	if err != nil {
+		t.Fatalf("inserting legacy rows failed: %v", err)
+	}
+	migrator, err := NewMigrator(handler.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+
+	if err := migrator.CheckCurrent(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckCurrent() on legacy database = %v, want ErrSchemaOutdated", err)
+	}
+	if exists, err := migrator.tableExists("schema_migrations"); err != nil || exists {
+		t.Errorf("CheckCurrent() created schema_migrations: %v, %v", exists, err)
+	}
+
+	initial, err := migrator.Baseline()
+	if err != nil || initial.Version != 1 {
+		t.Fatalf("Baseline() = %d, %v, want 1", initial.Version, err)
+	}
+	if _, err := migrator.Baseline(); !errors.Is(err, ErrNoLegacySchema) {
+		t.Errorf("second Baseline() error = %v, want ErrNoLegacySchema", err)
+	}
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.Migrations)-1 {
+		t.Fatalf("Up() = %d migrations, %v, want %d", len(applied), err, len(migrator.Migrations)-1)
+	}
+	if err := migrator.CheckCurrent(); err != nil {
+		t.Errorf("CheckCurrent() after Up() = %v", err)
+	}
+	var price int64
+	if err := handler.DB.QueryRow("SELECT price_amount FROM Parts WHERE part_id = '11';").Scan(&price); err != nil || price != 3000 {
+		t.Errorf("legacy part price = %d, %v, want 3000", price, err)
+	}
+}
+
+func Test_Migrator_Up_legacySchema(t *testing.T) {
+	handler, err := CreateSQLiteHandler(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLiteHandler() error = %v", err)
+	}
+	defer handler.Close()
+	if _, err := handler.DB.Exec(legacySQLiteSchema); err != nil {
+		t.Fatalf("creating the legacy schema failed: %v", err)
+	}
+	migrator, err := NewMigrator(handler.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	// Up baselines the legacy schema instead of creating its tables again.
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.Migrations)-1 {
+		t.Fatalf("Up() = %d migrations, %v, want %d", len(applied), err, len(migrator.Migrations)-1)
+	}
+	if err := migrator.CheckCurrent(); err != nil {
+		t.Errorf("CheckCurrent() after Up() = %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE Parts;
+DROP TABLE Vehicles;
@@ -1,3 +1,13 @@
+CREATE TABLE Vehicles (
+    vehicle_id VARCHAR(100) PRIMARY KEY,
+    listing_url VARCHAR(100) NOT NULL,
+    brand_name VARCHAR(50) NOT NULL,
+    model_name VARCHAR(50) NOT NULL,
+    year INTEGER NOT NULL,
+    vehicle_type VARCHAR(30),
+    created_at TIMESTAMP DEFAULT current_timestamp
+);
+
 CREATE TABLE Parts (
     part_id VARCHAR(50) PRIMARY KEY,
     vehicle_id VARCHAR(100) REFERENCES Vehicles(vehicle_id),
@@ -9,16 +19,6 @@ CREATE TABLE Parts (
     created_at TIMESTAMP DEFAULT current_timestamp
 );
 
-CREATE TABLE Vehicles (
-    vehicle_id VARCHAR(100) PRIMARY KEY,
-    listing_url VARCHAR(100) NOT NULL,
-    brand_name VARCHAR(50) NOT NULL,
-    model_name VARCHAR(50) NOT NULL,
-    year INTEGER NOT NULL,
-    vehicle_type VARCHAR(30),
-    created_at TIMESTAMP DEFAULT current_timestamp
-);
-
 ALTER TABLE Vehicles
 ADD CONSTRAINT unique_vehicle UNIQUE (brand_name, model_name, year);
 
@@ -0,0 +1,8 @@
+ALTER TABLE Parts DROP COLUMN deleted_at;
+ALTER TABLE Parts DROP COLUMN updated_at;
+
+ALTER TABLE Vehicles DROP COLUMN deleted_at;
+ALTER TABLE Vehicles DROP COLUMN updated_at;
+
+ALTER TABLE Vehicles
+ADD CONSTRAINT unique_vehicle UNIQUE (brand_name, model_name, year);
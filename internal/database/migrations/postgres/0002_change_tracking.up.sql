@@ -0,0 +1,11 @@
+-- Listings of the same brand, model and year are separate vehicles. The
+-- constraint made the crawler drop such vehicles and fail on their parts.
+ALTER TABLE Vehicles DROP CONSTRAINT unique_vehicle;
+
+ALTER TABLE Vehicles ADD COLUMN updated_at TIMESTAMP DEFAULT current_timestamp;
+ALTER TABLE Vehicles ADD COLUMN deleted_at TIMESTAMP;
+UPDATE Vehicles SET updated_at = created_at;
+
+ALTER TABLE Parts ADD COLUMN updated_at TIMESTAMP DEFAULT current_timestamp;
+ALTER TABLE Parts ADD COLUMN deleted_at TIMESTAMP;
+UPDATE Parts SET updated_at = created_at;
@@ -0,0 +1,12 @@
+CREATE TABLE part_price_history (
+    id SERIAL PRIMARY KEY,
+    part_id VARCHAR(50) NOT NULL REFERENCES Parts(part_id),
+    price FLOAT,
+    observed_at TIMESTAMP DEFAULT current_timestamp
+);
+
+CREATE INDEX part_price_history_part_id ON part_price_history (part_id, observed_at);
+
+-- The current price of every known part is its first observation.
+INSERT INTO part_price_history (part_id, price, observed_at)
+SELECT part_id, price, created_at FROM Parts;
@@ -0,0 +1 @@
+DROP TABLE part_compatibility;
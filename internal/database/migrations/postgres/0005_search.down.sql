@@ -0,0 +1,5 @@
+DROP INDEX parts_search;
+DROP INDEX vehicles_search;
+
+ALTER TABLE Parts DROP COLUMN search_vector;
+ALTER TABLE Vehicles DROP COLUMN search_vector;
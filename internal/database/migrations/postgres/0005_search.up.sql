@@ -0,0 +1,8 @@
+ALTER TABLE Vehicles ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(brand_name, '') || ' ' || coalesce(model_name, ''))) STORED;
+
+ALTER TABLE Parts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(part_name, '') || ' ' || coalesce(description, ''))) STORED;
+
+CREATE INDEX vehicles_search ON Vehicles USING GIN (search_vector);
+CREATE INDEX parts_search ON Parts USING GIN (search_vector);
@@ -0,0 +1,5 @@
+DROP INDEX vehicles_model_key;
+
+ALTER TABLE Vehicles DROP COLUMN displacement;
+ALTER TABLE Vehicles DROP COLUMN model_family;
+ALTER TABLE Vehicles DROP COLUMN model_key;
@@ -0,0 +1,5 @@
+ALTER TABLE part_compatibility ALTER CONSTRAINT part_compatibility_part_id_fkey NOT DEFERRABLE;
+ALTER TABLE part_price_history ALTER CONSTRAINT part_price_history_part_id_fkey NOT DEFERRABLE;
+ALTER TABLE Parts ALTER CONSTRAINT parts_vehicle_id_fkey NOT DEFERRABLE;
+
+ALTER TABLE Parts DROP COLUMN part_number;
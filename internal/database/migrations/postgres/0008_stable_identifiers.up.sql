@@ -0,0 +1,9 @@
+-- The part number of the site was only kept hashed into the part identifier.
+-- Stored parts get it on their next sync, when the crawler re-keys them from
+-- the 32-bit identifiers to the 128-bit ones. The foreign keys are deferred
+-- in the re-keying transaction, so that both ends of a reference can change.
+ALTER TABLE Parts ADD COLUMN part_number VARCHAR(50);
+
+ALTER TABLE Parts ALTER CONSTRAINT parts_vehicle_id_fkey DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE part_price_history ALTER CONSTRAINT part_price_history_part_id_fkey DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE part_compatibility ALTER CONSTRAINT part_compatibility_part_id_fkey DEFERRABLE INITIALLY IMMEDIATE;
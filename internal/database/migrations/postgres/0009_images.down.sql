@@ -0,0 +1,3 @@
+ALTER TABLE Parts DROP COLUMN img_thumb_hash;
+ALTER TABLE Parts DROP COLUMN img_hash;
+DROP TABLE images;
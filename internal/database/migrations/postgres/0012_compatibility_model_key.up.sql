@@ -0,0 +1,9 @@
+-- Compatibilities match vehicles by the normalized model name, so that every
+-- spelling of a model, such as "RX-3", "RX 3" and "RX3", is compatible. The
+-- existing compatibilities get the key of a stored vehicle of their model;
+-- the ones of a model without stored vehicles keep matching by model name.
+ALTER TABLE part_compatibility ADD COLUMN model_key VARCHAR(50);
+UPDATE part_compatibility SET model_key = (SELECT V.model_key FROM Vehicles V
+WHERE V.brand_name = part_compatibility.brand_name AND V.model_name = part_compatibility.model_name AND V.model_key IS NOT NULL LIMIT 1);
+
+CREATE INDEX part_compatibility_model_key ON part_compatibility (brand_name, model_key);
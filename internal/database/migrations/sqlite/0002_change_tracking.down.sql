@@ -0,0 +1,17 @@
+ALTER TABLE Parts DROP COLUMN deleted_at;
+ALTER TABLE Parts DROP COLUMN updated_at;
+
+CREATE TABLE Vehicles_old (
+    vehicle_id VARCHAR(100) PRIMARY KEY,
+    listing_url VARCHAR(100) NOT NULL,
+    brand_name VARCHAR(50) NOT NULL,
+    model_name VARCHAR(50) NOT NULL,
+    year INTEGER NOT NULL,
+    vehicle_type VARCHAR(30),
+    created_at TIMESTAMP DEFAULT current_timestamp,
+    CONSTRAINT unique_vehicle UNIQUE (brand_name, model_name, year)
+);
+INSERT INTO Vehicles_old (vehicle_id, listing_url, brand_name, model_name, year, vehicle_type, created_at)
+SELECT vehicle_id, listing_url, brand_name, model_name, year, vehicle_type, created_at FROM Vehicles;
+DROP TABLE Vehicles;
+ALTER TABLE Vehicles_old RENAME TO Vehicles;
@@ -0,0 +1,21 @@
+-- Listings of the same brand, model and year are separate vehicles. SQLite
+-- cannot drop a table constraint, so the Vehicles table is rebuilt without it.
+CREATE TABLE Vehicles_new (
+    vehicle_id VARCHAR(100) PRIMARY KEY,
+    listing_url VARCHAR(100) NOT NULL,
+    brand_name VARCHAR(50) NOT NULL,
+    model_name VARCHAR(50) NOT NULL,
+    year INTEGER NOT NULL,
+    vehicle_type VARCHAR(30),
+    created_at TIMESTAMP DEFAULT current_timestamp,
+    updated_at TIMESTAMP DEFAULT current_timestamp,
+    deleted_at TIMESTAMP
+);
+INSERT INTO Vehicles_new (vehicle_id, listing_url, brand_name, model_name, year, vehicle_type, created_at, updated_at)
+SELECT vehicle_id, listing_url, brand_name, model_name, year, vehicle_type, created_at, created_at FROM Vehicles;
+DROP TABLE Vehicles;
+ALTER TABLE Vehicles_new RENAME TO Vehicles;
+
+ALTER TABLE Parts ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE Parts ADD COLUMN deleted_at TIMESTAMP;
+UPDATE Parts SET updated_at = created_at;
@@ -0,0 +1 @@
+DROP TABLE part_price_history;
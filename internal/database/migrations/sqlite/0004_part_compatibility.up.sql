@@ -0,0 +1,12 @@
+CREATE TABLE part_compatibility (
+    part_id VARCHAR(50) NOT NULL REFERENCES Parts(part_id),
+    brand_name VARCHAR(50) NOT NULL,
+    model_name VARCHAR(50) NOT NULL,
+    year_from INTEGER NOT NULL,
+    year_to INTEGER NOT NULL,
+    source VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT current_timestamp,
+    PRIMARY KEY (part_id, brand_name, model_name, year_from, year_to)
+);
+
+CREATE INDEX part_compatibility_model ON part_compatibility (brand_name, model_name);
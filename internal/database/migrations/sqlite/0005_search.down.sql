@@ -0,0 +1,9 @@
+DROP TRIGGER parts_search_delete;
+DROP TRIGGER parts_search_update;
+DROP TRIGGER parts_search_insert;
+DROP TRIGGER vehicles_search_delete;
+DROP TRIGGER vehicles_search_update;
+DROP TRIGGER vehicles_search_insert;
+
+DROP TABLE search_index;
+DROP TABLE search_documents;
@@ -0,0 +1,72 @@
+-- Every searchable vehicle and part gets a document in the FTS4 index. The
+-- document of a part also contains the brand and model of its vehicle.
+CREATE TABLE search_documents (
+    docid INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind VARCHAR(10) NOT NULL,
+    ref_id VARCHAR(50) NOT NULL,
+    UNIQUE (kind, ref_id)
+);
+
+CREATE VIRTUAL TABLE search_index USING fts4 (body, tokenize=unicode61);
+
+INSERT INTO search_documents (kind, ref_id) SELECT 'vehicle', vehicle_id FROM Vehicles;
+INSERT INTO search_documents (kind, ref_id) SELECT 'part', part_id FROM Parts;
+
+INSERT INTO search_index (docid, body)
+SELECT D.docid, coalesce(V.brand_name, '') || ' ' || coalesce(V.model_name, '')
+FROM search_documents D INNER JOIN Vehicles V ON D.kind = 'vehicle' AND V.vehicle_id = D.ref_id;
+
+INSERT INTO search_index (docid, body)
+SELECT D.docid, coalesce(V.brand_name, '') || ' ' || coalesce(V.model_name, '') || ' ' || coalesce(P.part_name, '') || ' ' || coalesce(P.description, '')
+FROM search_documents D INNER JOIN Parts P ON D.kind = 'part' AND P.part_id = D.ref_id
+INNER JOIN Vehicles V ON V.vehicle_id = P.vehicle_id;
+
+CREATE TRIGGER vehicles_search_insert AFTER INSERT ON Vehicles BEGIN
+    INSERT OR IGNORE INTO search_documents (kind, ref_id) VALUES ('vehicle', NEW.vehicle_id);
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'vehicle' AND ref_id = NEW.vehicle_id);
+    INSERT INTO search_index (docid, body)
+    SELECT docid, coalesce(NEW.brand_name, '') || ' ' || coalesce(NEW.model_name, '')
+    FROM search_documents WHERE kind = 'vehicle' AND ref_id = NEW.vehicle_id;
+END;
+
+CREATE TRIGGER vehicles_search_update AFTER UPDATE OF brand_name, model_name ON Vehicles BEGIN
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'vehicle' AND ref_id = NEW.vehicle_id);
+    INSERT INTO search_index (docid, body)
+    SELECT docid, coalesce(NEW.brand_name, '') || ' ' || coalesce(NEW.model_name, '')
+    FROM search_documents WHERE kind = 'vehicle' AND ref_id = NEW.vehicle_id;
+    -- The documents of the vehicle's parts contain the brand and model too.
+    DELETE FROM search_index WHERE docid IN (
+        SELECT D.docid FROM search_documents D INNER JOIN Parts P ON D.kind = 'part' AND D.ref_id = P.part_id
+        WHERE P.vehicle_id = NEW.vehicle_id);
+    INSERT INTO search_index (docid, body)
+    SELECT D.docid, coalesce(NEW.brand_name, '') || ' ' || coalesce(NEW.model_name, '') || ' ' || coalesce(P.part_name, '') || ' ' || coalesce(P.description, '')
+    FROM search_documents D INNER JOIN Parts P ON D.kind = 'part' AND D.ref_id = P.part_id
+    WHERE P.vehicle_id = NEW.vehicle_id;
+END;
+
+CREATE TRIGGER vehicles_search_delete AFTER DELETE ON Vehicles BEGIN
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'vehicle' AND ref_id = OLD.vehicle_id);
+    DELETE FROM search_documents WHERE kind = 'vehicle' AND ref_id = OLD.vehicle_id;
+END;
+
+CREATE TRIGGER parts_search_insert AFTER INSERT ON Parts BEGIN
+    INSERT OR IGNORE INTO search_documents (kind, ref_id) VALUES ('part', NEW.part_id);
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'part' AND ref_id = NEW.part_id);
+    INSERT INTO search_index (docid, body)
+    SELECT D.docid, coalesce(V.brand_name, '') || ' ' || coalesce(V.model_name, '') || ' ' || coalesce(NEW.part_name, '') || ' ' || coalesce(NEW.description, '')
+    FROM search_documents D INNER JOIN Vehicles V ON V.vehicle_id = NEW.vehicle_id
+    WHERE D.kind = 'part' AND D.ref_id = NEW.part_id;
+END;
+
+CREATE TRIGGER parts_search_update AFTER UPDATE OF part_name, description, vehicle_id ON Parts BEGIN
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'part' AND ref_id = NEW.part_id);
+    INSERT INTO search_index (docid, body)
+    SELECT D.docid, coalesce(V.brand_name, '') || ' ' || coalesce(V.model_name, '') || ' ' || coalesce(NEW.part_name, '') || ' ' || coalesce(NEW.description, '')
+    FROM search_documents D INNER JOIN Vehicles V ON V.vehicle_id = NEW.vehicle_id
+    WHERE D.kind = 'part' AND D.ref_id = NEW.part_id;
+END;
+
+CREATE TRIGGER parts_search_delete AFTER DELETE ON Parts BEGIN
+    DELETE FROM search_index WHERE docid = (SELECT docid FROM search_documents WHERE kind = 'part' AND ref_id = OLD.part_id);
+    DELETE FROM search_documents WHERE kind = 'part' AND ref_id = OLD.part_id;
+END;
@@ -0,0 +1,8 @@
+-- The normalized model name groups the spellings of a model, such as "RX125"
+-- and "RX 125", and the family groups the models by displacement. Existing
+-- vehicles get them on their next sync.
+ALTER TABLE Vehicles ADD COLUMN model_key VARCHAR(50);
+ALTER TABLE Vehicles ADD COLUMN model_family VARCHAR(50);
+ALTER TABLE Vehicles ADD COLUMN displacement INTEGER;
+
+CREATE INDEX vehicles_model_key ON Vehicles (vehicle_type, brand_name, model_key);
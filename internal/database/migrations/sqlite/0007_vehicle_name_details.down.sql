@@ -0,0 +1,4 @@
+ALTER TABLE Vehicles DROP COLUMN trim_level;
+ALTER TABLE Vehicles DROP COLUMN stroke;
+ALTER TABLE Vehicles DROP COLUMN year_to;
+ALTER TABLE Vehicles DROP COLUMN year_from;
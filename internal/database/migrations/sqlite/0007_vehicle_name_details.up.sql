@@ -0,0 +1,6 @@
+-- The year range, stroke and trim parsed from the listing names. Existing
+-- vehicles get them on their next sync, until then their range is their year.
+ALTER TABLE Vehicles ADD COLUMN year_from INTEGER;
+ALTER TABLE Vehicles ADD COLUMN year_to INTEGER;
+ALTER TABLE Vehicles ADD COLUMN stroke VARCHAR(2);
+ALTER TABLE Vehicles ADD COLUMN trim_level VARCHAR(50);
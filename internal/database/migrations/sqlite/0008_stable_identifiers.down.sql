@@ -0,0 +1 @@
+ALTER TABLE Parts DROP COLUMN part_number;
@@ -0,0 +1,5 @@
+-- The part number of the site was only kept hashed into the part identifier.
+-- Stored parts get it on their next sync, when the crawler re-keys them from
+-- the 32-bit identifiers to the 128-bit ones. SQLite defers the foreign keys
+-- of the re-keying transaction with a pragma.
+ALTER TABLE Parts ADD COLUMN part_number VARCHAR(50);
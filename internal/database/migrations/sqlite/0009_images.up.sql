@@ -0,0 +1,14 @@
+-- Part photos mirrored into the local image store, keyed by the sha256 of
+-- their content. source_url is where the image was first downloaded from.
+CREATE TABLE images (
+    hash VARCHAR(64) PRIMARY KEY,
+    source_url VARCHAR(255) NOT NULL,
+    size INTEGER NOT NULL,
+    mime_type VARCHAR(50) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT current_timestamp
+);
+
+ALTER TABLE Parts ADD COLUMN img_hash VARCHAR(64);
+ALTER TABLE Parts ADD COLUMN img_thumb_hash VARCHAR(64);
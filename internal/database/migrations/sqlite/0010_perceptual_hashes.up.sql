@@ -0,0 +1,3 @@
+-- Perceptual hash of the mirrored images in hex, empty when the image could
+-- not be decoded. Photos with close hashes are the same photo.
+ALTER TABLE images ADD COLUMN phash VARCHAR(16);
@@ -0,0 +1,13 @@
+-- The price column has no currency, so only prices in euros are written back.
+-- Prices in other currencies are left without a price.
+ALTER TABLE part_price_history ADD COLUMN price FLOAT;
+UPDATE part_price_history SET price = price_amount / 100.0 WHERE price_currency = 'EUR';
+ALTER TABLE part_price_history DROP COLUMN price_vat_included;
+ALTER TABLE part_price_history DROP COLUMN price_currency;
+ALTER TABLE part_price_history DROP COLUMN price_amount;
+
+ALTER TABLE Parts ADD COLUMN price FLOAT;
+UPDATE Parts SET price = price_amount / 100.0 WHERE price_currency = 'EUR';
+ALTER TABLE Parts DROP COLUMN price_vat_included;
+ALTER TABLE Parts DROP COLUMN price_currency;
+ALTER TABLE Parts DROP COLUMN price_amount;
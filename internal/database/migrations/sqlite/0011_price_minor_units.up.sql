@@ -0,0 +1,15 @@
+-- Prices are stored as integer amounts in the minor units of their currency,
+-- such as cents, with the ISO 4217 code of the currency and whether they
+-- include VAT. The prices stored before were euros with VAT included. A part
+-- without a price has an empty currency.
+ALTER TABLE Parts ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE Parts ADD COLUMN price_currency VARCHAR(3) NOT NULL DEFAULT '';
+ALTER TABLE Parts ADD COLUMN price_vat_included BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE Parts SET price_amount = CAST(round(price * 100) AS INTEGER), price_currency = 'EUR', price_vat_included = TRUE WHERE price IS NOT NULL;
+ALTER TABLE Parts DROP COLUMN price;
+
+ALTER TABLE part_price_history ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE part_price_history ADD COLUMN price_currency VARCHAR(3) NOT NULL DEFAULT '';
+ALTER TABLE part_price_history ADD COLUMN price_vat_included BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE part_price_history SET price_amount = CAST(round(price * 100) AS INTEGER), price_currency = 'EUR', price_vat_included = TRUE WHERE price IS NOT NULL;
+ALTER TABLE part_price_history DROP COLUMN price;
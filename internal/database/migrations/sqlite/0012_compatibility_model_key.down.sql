@@ -0,0 +1,3 @@
+DROP INDEX part_compatibility_model_key;
+
+ALTER TABLE part_compatibility DROP COLUMN model_key;
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"Crawler/internal/modelname"
+	"Crawler/internal/models"
+	"database/sql"
+	"slices"
+	"sort"
+)
+
+// withModelName fills in the model key, family and displacement of a vehicle
+// that was scraped before they were derived.
+func withModelName(vehicle models.Vehicle) models.Vehicle {
+	if vehicle.ModelKey == "" && vehicle.Model != "" {
+		name := modelname.Normalize(vehicle.Model)
+		vehicle.ModelKey, vehicle.ModelFamily, vehicle.Displacement = name.Key, name.Family, name.Displacement
+	}
+	return vehicle
+}
+
+// modelNameRow is a spelling of a model name and the number of vehicles with it.
+type modelNameRow struct {
+	vehicle models.Vehicle
+	count   int
+}
+
+// queryModelFamilies runs a query selecting model_key, model_family,
+// model_name, displacement and the number of vehicles, and groups the models
+// by family. Rows stored before the keys were derived get them from the name.
+func queryModelFamilies(db *sql.DB, query string, args ...any) ([]models.ModelFamily, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []modelNameRow
+	for rows.Next() {
+		var row modelNameRow
+		err = rows.Scan(&row.vehicle.ModelKey, &row.vehicle.ModelFamily, &row.vehicle.Model, &row.vehicle.Displacement, &row.count)
+		if err != nil {
+			return nil, err
+		}
+		row.vehicle = withModelName(row.vehicle)
+		names = append(names, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return groupModelFamilies(names), nil
+}
+
+// groupModelFamilies groups the spellings of model names by model key and the
+// models by family. The families are ordered by name and their models by
+// displacement. The most common spelling names a model.
+func groupModelFamilies(names []modelNameRow) []models.ModelFamily {
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].count != names[j].count {
+			return names[i].count > names[j].count
+		}
+		return names[i].vehicle.Model < names[j].vehicle.Model
+	})
+	families := []models.ModelFamily{}
+	familyIndex := make(map[string]int)
+	modelIndex := make(map[string][2]int)
+	for _, row := range names {
+		vehicle := row.vehicle
+		if at, exists := modelIndex[vehicle.ModelKey]; exists {
+			model := &families[at[0]].Models[at[1]]
+			// Vehicles stored with and without the key repeat a spelling.
+			if !slices.Contains(model.Spellings, vehicle.Model) {
+				model.Spellings = append(model.Spellings, vehicle.Model)
+			}
+			continue
+		}
+		f, exists := familyIndex[vehicle.ModelFamily]
+		if !exists {
+			f = len(families)
+			familyIndex[vehicle.ModelFamily] = f
+			families = append(families, models.ModelFamily{Family: vehicle.ModelFamily})
+		}
+		modelIndex[vehicle.ModelKey] = [2]int{f, len(families[f].Models)}
+		families[f].Models = append(families[f].Models, models.VehicleModel{
+			Key: vehicle.ModelKey, Name: vehicle.Model, Displacement: vehicle.Displacement, Spellings: []string{vehicle.Model},
+		})
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Family < families[j].Family })
+	for _, family := range families {
+		sort.SliceStable(family.Models, func(i, j int) bool {
+			if family.Models[i].Displacement != family.Models[j].Displacement {
+				return family.Models[i].Displacement < family.Models[j].Displacement
+			}
+			return family.Models[i].Key < family.Models[j].Key
+		})
+		for _, model := range family.Models {
+			sort.Strings(model.Spellings)
+		}
+	}
+	return families
+}
+
+// modelNames returns the names of the models of the families in name order.
+func modelNames(families []models.ModelFamily) []string {
+	var names []string
+	for _, family := range families {
+		for _, model := range family.Models {
+			names = append(names, model.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"Crawler/internal/modelname"
+	"Crawler/internal/models"
+	"Crawler/internal/search"
+	"database/sql"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+type PSQLHandler struct {
+	DB *sql.DB
+	// CopyBatchSize is the number of vehicles or parts that InsertVehicles and
+	// InsertParts copy into a staging table and merge at a time. With zero or
+	// less, the default, they are upserted row by row.
+	CopyBatchSize int
+}
+
+// CreatePSQLHandler connects to PostgreSQL database and returns the handler.
+func CreatePSQLHandler(connectionString string) (*PSQLHandler, error) {
+	// Connect to the PostgreSQL database
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the connection by pinging the database
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Println("Successfully connected to PostgreSQL!")
+	return &PSQLHandler{DB: db}, nil
+}
+
+func (handler *PSQLHandler) Close() error {
+	return handler.DB.Close()
+}
+
+func (handler *PSQLHandler) InsertVehicles(vehicles []models.Vehicle) error {
+	if handler.CopyBatchSize > 0 {
+		return copyVehicles(handler.DB, handler.CopyBatchSize, vehicles)
+	}
+	return insertVehicles(handler.DB, "INSERT INTO Vehicles ("+psqlVehicleColumns+`, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, current_timestamp)`+psqlVehicleConflict+";",
+		"SELECT listing_url FROM Vehicles WHERE vehicle_id = $1;",
+		vehicles, true)
+}
+
+// InsertParts adds the parts to the database in a batch.
+func (handler *PSQLHandler) InsertParts(vehicles []models.Vehicle) error {
+	if handler.CopyBatchSize > 0 {
+		return copyParts(handler.DB, handler.CopyBatchSize, vehicles)
+	}
+	return insertParts(handler.DB, "INSERT INTO Parts ("+psqlPartColumns+`, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, current_timestamp)`+psqlPartConflict+";",
+		"SELECT vehicle_id FROM Parts WHERE part_id = $1;",
+		"UPDATE Vehicles SET updated_at = current_timestamp WHERE vehicle_id = $1;",
+		`INSERT INTO part_price_history (part_id, price_amount, price_currency, price_vat_included) SELECT $1::VARCHAR, $2::BIGINT, $3::VARCHAR, $4::BOOLEAN
+WHERE NOT EXISTS (SELECT 1 FROM (SELECT price_amount, price_currency, price_vat_included FROM part_price_history WHERE part_id = $1::VARCHAR ORDER BY observed_at DESC, id DESC LIMIT 1) L
+WHERE (L.price_amount, L.price_currency, L.price_vat_included) = ($2::BIGINT, $3::VARCHAR, $4::BOOLEAN));`,
+		vehicles, true)
+}
+
+// RekeyIdentifiers renames the vehicles and parts. The search vectors are
+// columns of the renamed rows.
+func (handler *PSQLHandler) RekeyIdentifiers(vehicles []models.IdentifierChange, parts []models.IdentifierChange) error {
+	return rekeyIdentifiers(handler.DB, "SET CONSTRAINTS ALL DEFERRED;",
+		[]string{
+			"UPDATE Vehicles SET vehicle_id = $1 WHERE vehicle_id = $2;",
+			"UPDATE Parts SET vehicle_id = $1 WHERE vehicle_id = $2;",
+		},
+		[]string{
+			"UPDATE Parts SET part_id = $1 WHERE part_id = $2;",
+			"UPDATE part_price_history SET part_id = $1 WHERE part_id = $2;",
+			"UPDATE part_compatibility SET part_id = $1 WHERE part_id = $2;",
+		},
+		vehicles, parts)
+}
+
+func (handler *PSQLHandler) DeleteVehicles(vehicleIdentifiers []string) error {
+	return execForEach(handler.DB, vehicleIdentifiers,
+		"UPDATE Vehicles SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE vehicle_id = $1 AND deleted_at IS NULL;",
+		"UPDATE Parts SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE vehicle_id = $1 AND deleted_at IS NULL;")
+}
+
+func (handler *PSQLHandler) DeleteParts(partIdentifiers []string) error {
+	return execForEach(handler.DB, partIdentifiers,
+		"UPDATE Vehicles SET updated_at = current_timestamp WHERE vehicle_id = (SELECT vehicle_id FROM Parts WHERE part_id = $1 AND deleted_at IS NULL);",
+		"UPDATE Parts SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE part_id = $1 AND deleted_at IS NULL;")
+}
+
+func (handler *PSQLHandler) GetVehicleCount() (int, error) {
+	count := 0
+	err := handler.DB.QueryRow("SELECT COUNT(vehicle_id) FROM Vehicles WHERE deleted_at IS NULL;").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (handler *PSQLHandler) GetBrands(vehicleType string) ([]string, error) {
+	return queryStrings(handler.DB, "SELECT DISTINCT(brand_name) FROM Vehicles WHERE deleted_at IS NULL AND vehicle_type = $1 ORDER BY brand_name ASC;", vehicleType)
+}
+
+func (handler *PSQLHandler) GetModelsForBrand(vehicleType string, brandName string) ([]string, error) {
+	families, err := handler.GetModelFamilies(vehicleType, brandName)
+	if err != nil {
+		return nil, err
+	}
+	return modelNames(families), nil
+}
+
+func (handler *PSQLHandler) GetModelFamilies(vehicleType string, brandName string) ([]models.ModelFamily, error) {
+	return queryModelFamilies(handler.DB, `SELECT coalesce(model_key, ''), coalesce(model_family, ''), model_name, coalesce(displacement, 0), COUNT(*)
+FROM Vehicles WHERE deleted_at IS NULL AND vehicle_type = $1 AND brand_name = $2 GROUP BY model_key, model_family, model_name, displacement;`, vehicleType, brandName)
+}
+
+func (handler *PSQLHandler) GetVehicle(vehicleType string, vehicleIdentifier string) (models.Vehicle, error) {
+	return queryVehicle(handler.DB, "SELECT "+vehicleListColumns+" FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = $1 AND V.vehicle_id = $2;",
+		vehicleType, vehicleIdentifier)
+}
+
+func (handler *PSQLHandler) GetVehicleTypes() ([]string, error) {
+	vehicleTypes, err := queryStrings(handler.DB, "SELECT DISTINCT(vehicle_type) FROM Vehicles WHERE deleted_at IS NULL ORDER BY vehicle_type ASC;")
+	if err != nil {
+		log.Printf("error while getting vehicle types: %v", err)
+		return nil, err
+	}
+	return vehicleTypes, nil
+}
+
+func (handler *PSQLHandler) GetVehiclesForType(vehicleType string, options models.ListOptions) (models.VehiclePage, error) {
+	return queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = $1",
+		args:        []any{vehicleType},
+		defaultSort: models.SortBrand,
+		placeholder: postgresPlaceholder,
+	}, options)
+}
+
+func (handler *PSQLHandler) GetVehiclesForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.VehiclePage, error) {
+	page, err := queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = $1 AND V.brand_name = $2 AND (V.model_key = $3 OR V.model_key IS NULL AND V.model_name = $4)",
+		args:        []any{vehicleType, brandName, modelname.Normalize(modelName).Key, modelName},
+		defaultSort: models.SortYear,
+		placeholder: postgresPlaceholder,
+	}, options)
+	if err != nil {
+		log.Printf("error while getting vehicles for model: %v", err)
+		return models.VehiclePage{}, err
+	}
+	return page, nil
+}
+
+func (handler *PSQLHandler) GetPartsForVehicle(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	// Distinguish an unknown vehicle from a vehicle without parts.
+	_, err := handler.GetVehicle(vehicleType, vehicleIdentifier)
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	page, err := queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = $1 AND V.vehicle_id = $2",
+		args:        []any{vehicleType, vehicleIdentifier},
+		defaultSort: models.SortName,
+		placeholder: postgresPlaceholder,
+	}, options)
+	if err != nil {
+		log.Printf("error while getting parts for a vehicle: %v", err)
+		return models.PartPage{}, err
+	}
+	return page, nil
+}
+
+func (handler *PSQLHandler) GetPartsForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.PartPage, error) {
+	page, err := queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = $1 AND V.brand_name = $2 AND (V.model_key = $3 OR V.model_key IS NULL AND V.model_name = $4)",
+		args:        []any{vehicleType, brandName, modelname.Normalize(modelName).Key, modelName},
+		defaultSort: models.SortYear,
+		placeholder: postgresPlaceholder,
+	}, options)
+	if err != nil {
+		log.Printf("error while getting parts for model: %v", err)
+		return models.PartPage{}, err
+	}
+	return page, nil
+}
+
+func (handler *PSQLHandler) GetRecentVehicles(options models.ListOptions) (models.VehiclePage, error) {
+	return queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL",
+		defaultSort: "-" + models.SortUpdatedAt,
+		placeholder: postgresPlaceholder,
+	}, options)
+}
+
+func (handler *PSQLHandler) GetRecentParts(options models.ListOptions) (models.PartPage, error) {
+	return queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL",
+		defaultSort: "-" + models.SortUpdatedAt,
+		placeholder: postgresPlaceholder,
+	}, options)
+}
+
+func (handler *PSQLHandler) GetPartPriceHistory(vehicleType string, vehicleIdentifier string, partIdentifier string) ([]models.PricePoint, error) {
+	return queryPricePoints(handler.DB, "SELECT H.price_amount, H.price_currency, H.price_vat_included, H.observed_at FROM part_price_history H INNER JOIN Parts P ON H.part_id = P.part_id INNER JOIN Vehicles V ON P.vehicle_id = V.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = $1 AND V.vehicle_id = $2 AND P.part_id = $3 ORDER BY H.observed_at ASC, H.id ASC;",
+		vehicleType, vehicleIdentifier, partIdentifier)
+}
+
+func (handler *PSQLHandler) InsertCompatibilities(compatibilities []models.Compatibility) error {
+	return insertCompatibilities(handler.DB, "INSERT INTO part_compatibility (part_id, brand_name, model_name, year_from, year_to, source, model_key) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING;", compatibilities)
+}
+
+func (handler *PSQLHandler) InsertImages(images []models.Image) error {
+	return insertImages(handler.DB, "INSERT INTO images (hash, source_url, size, mime_type, width, height, phash) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING;", images)
+}
+
+func (handler *PSQLHandler) GetImage(hash string) (models.Image, error) {
+	return queryImage(handler.DB, "SELECT hash, source_url, size, mime_type, width, height, coalesce(phash, '') FROM images WHERE hash = $1;", hash)
+}
+
+func (handler *PSQLHandler) GetDuplicateImages(maxDistance int) ([]models.DuplicateImageGroup, error) {
+	return queryDuplicateImages(handler.DB, maxDistance)
+}
+
+func (handler *PSQLHandler) GetCompatibleParts(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	// Distinguish an unknown vehicle from a vehicle without compatible parts.
+	_, err := handler.GetVehicle(vehicleType, vehicleIdentifier)
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	return queryPartPage(handler.DB, listQuery{
+		from: `FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id
+WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_id <> $2 AND EXISTS (
+SELECT 1 FROM part_compatibility C
+INNER JOIN Vehicles T ON C.brand_name = T.brand_name AND T.year BETWEEN C.year_from AND C.year_to
+AND (C.model_key = T.model_key OR (C.model_key IS NULL OR T.model_key IS NULL) AND C.model_name = T.model_name)
+WHERE C.part_id = P.part_id AND T.vehicle_type = $1 AND T.vehicle_id = $2)`,
+		args:        []any{vehicleType, vehicleIdentifier},
+		defaultSort: models.SortName,
+		placeholder: postgresPlaceholder,
+	}, options)
+}
+
+// Search matches the query against the search vectors of vehicles and parts.
+// A part matches on the brand and model of its vehicle too.
+func (handler *PSQLHandler) Search(query string, limit int) ([]models.SearchHit, error) {
+	root, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := handler.DB.Query(`SELECT * FROM (
+SELECT ts_rank(V.search_vector, Q.query) AS rank, 'vehicle' AS kind, `+vehicleListColumns+`,
+'' AS part_name, '' AS description, '' AS part_id, '' AS part_number, 0::BIGINT AS price_amount, '' AS price_currency, FALSE AS price_vat_included, '' AS img_url, '' AS img_thumb_url, '' AS img_hash, '' AS img_thumb_hash, V.created_at AS part_created_at, V.updated_at AS part_updated_at
+FROM Vehicles V, to_tsquery('simple', $1) AS Q(query)
+WHERE V.deleted_at IS NULL AND V.search_vector @@ Q.query
+UNION ALL
+SELECT ts_rank(V.search_vector || P.search_vector, Q.query), 'part', `+partListColumns+`
+FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id, to_tsquery('simple', $1) AS Q(query)
+WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND (V.search_vector || P.search_vector) @@ Q.query
+) hits
+ORDER BY rank DESC, kind DESC, vehicle_id ASC, part_id ASC
+LIMIT $2;`, search.TSQuery(root), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var rank float64
+		hit, err := scanSearchHit(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		hit.Rank = rank
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"Crawler/internal/models"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// The columns upserted into Vehicles and Parts, in the order of the upsert parameters.
+const (
+	psqlVehicleColumns = "vehicle_type, brand_name, model_name, listing_url, vehicle_id, year, model_key, model_family, displacement, year_from, year_to, stroke, trim_level"
+	psqlPartColumns    = "part_name, description, part_id, vehicle_id, price_amount, price_currency, price_vat_included, img_url, img_thumb_url, part_number, img_hash, img_thumb_hash"
+)
+
+// psqlVehicleConflict only updates vehicles of the same listing that changed or were deleted.
+const psqlVehicleConflict = `
+ON CONFLICT (vehicle_id) DO UPDATE SET vehicle_type = EXCLUDED.vehicle_type, brand_name = EXCLUDED.brand_name, model_name = EXCLUDED.model_name, year = EXCLUDED.year,
+model_key = EXCLUDED.model_key, model_family = EXCLUDED.model_family, displacement = EXCLUDED.displacement,
+year_from = EXCLUDED.year_from, year_to = EXCLUDED.year_to, stroke = EXCLUDED.stroke, trim_level = EXCLUDED.trim_level, updated_at = current_timestamp, deleted_at = NULL
+WHERE Vehicles.listing_url = EXCLUDED.listing_url AND ((Vehicles.vehicle_type, Vehicles.brand_name, Vehicles.model_name, Vehicles.year, Vehicles.model_key, Vehicles.model_family,
+Vehicles.displacement, Vehicles.year_from, Vehicles.year_to, Vehicles.stroke, Vehicles.trim_level)
+IS DISTINCT FROM (EXCLUDED.vehicle_type, EXCLUDED.brand_name, EXCLUDED.model_name, EXCLUDED.year, EXCLUDED.model_key, EXCLUDED.model_family,
+EXCLUDED.displacement, EXCLUDED.year_from, EXCLUDED.year_to, EXCLUDED.stroke, EXCLUDED.trim_level) OR Vehicles.deleted_at IS NOT NULL)`
+
+// psqlPartConflict only updates parts of the same vehicle that changed or were deleted.
+const psqlPartConflict = `
+ON CONFLICT (part_id) DO UPDATE SET part_name = EXCLUDED.part_name, description = EXCLUDED.description, price_amount = EXCLUDED.price_amount, price_currency = EXCLUDED.price_currency,
+price_vat_included = EXCLUDED.price_vat_included, img_url = EXCLUDED.img_url, img_thumb_url = EXCLUDED.img_thumb_url,
+part_number = EXCLUDED.part_number, img_hash = EXCLUDED.img_hash, img_thumb_hash = EXCLUDED.img_thumb_hash, updated_at = current_timestamp, deleted_at = NULL
+WHERE Parts.vehicle_id = EXCLUDED.vehicle_id AND ((Parts.part_name, Parts.description, Parts.price_amount, Parts.price_currency, Parts.price_vat_included, Parts.img_url, Parts.img_thumb_url, Parts.part_number, Parts.img_hash, Parts.img_thumb_hash)
+IS DISTINCT FROM (EXCLUDED.part_name, EXCLUDED.description, EXCLUDED.price_amount, EXCLUDED.price_currency, EXCLUDED.price_vat_included, EXCLUDED.img_url, EXCLUDED.img_thumb_url, EXCLUDED.part_number, EXCLUDED.img_hash, EXCLUDED.img_thumb_hash) OR Parts.deleted_at IS NOT NULL)`
+
+// copyInBatches creates a staging table with createQuery and copies the rows
+// into it batchSize rows at a time. After each batch has been copied, merge
+// moves it from the staging table into the real tables, and the staging table
+// is emptied for the next batch. The staging table is dropped on commit.
+func copyInBatches(tx *sql.Tx, createQuery string, table string, columns []string, rows [][]any, batchSize int, merge func(batch [][]any) error) error {
+	_, err := tx.Exec(createQuery)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+		stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			_, err = stmt.Exec(row...)
+			if err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		// Exec without arguments flushes the copied rows.
+		_, err = stmt.Exec()
+		if err == nil {
+			err = stmt.Close()
+		} else {
+			stmt.Close()
+		}
+		if err != nil {
+			return err
+		}
+		err = merge(batch)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("TRUNCATE " + pq.QuoteIdentifier(table) + ";")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryCollisions returns the identifiers selected by the query mapped to the
+// stored value they collide with.
+func queryCollisions(tx *sql.Tx, query string) (map[string]string, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := make(map[string]string)
+	for rows.Next() {
+		var identifier, value string
+		if err := rows.Scan(&identifier, &value); err != nil {
+			return nil, err
+		}
+		stored[identifier] = value
+	}
+	return stored, rows.Err()
+}
+
+// copyVehicles upserts the vehicles like InsertVehicles does row by row, but
+// streams them into a staging table with COPY and merges each batch with a
+// single upsert.
+func copyVehicles(db *sql.DB, batchSize int, vehicles []models.Vehicle) error {
+	if hasDuplicateVehicleIDs(vehicles) {
+		return errors.New("duplicate id found")
+	}
+	rows := make([][]any, len(vehicles))
+	for i, vehicle := range vehicles {
+		vehicle = withYearRange(withModelName(vehicle))
+		rows[i] = []any{vehicle.VehicleType, vehicle.Brand, vehicle.Model, vehicle.Url, vehicle.Identifier, vehicle.Year,
+			vehicle.ModelKey, vehicle.ModelFamily, vehicle.Displacement, vehicle.YearFrom, vehicle.YearTo, vehicle.Stroke, vehicle.Trim}
+	}
+	var collisions []models.IdentifierCollision
+	bar := newProgressBar(len(vehicles), true)
+	err := withTransaction(db, func(tx *sql.Tx) error {
+		return copyInBatches(tx, "CREATE TEMP TABLE staging_vehicles ON COMMIT DROP AS SELECT "+psqlVehicleColumns+" FROM Vehicles WITH NO DATA;",
+			"staging_vehicles", []string{"vehicle_type", "brand_name", "model_name", "listing_url", "vehicle_id", "year",
+				"model_key", "model_family", "displacement", "year_from", "year_to", "stroke", "trim_level"},
+			rows, batchSize, func(batch [][]any) error {
+				storedURLs, err := queryCollisions(tx, `SELECT S.vehicle_id, V.listing_url FROM staging_vehicles S
+INNER JOIN Vehicles V ON V.vehicle_id = S.vehicle_id WHERE V.listing_url <> S.listing_url;`)
+				if err != nil {
+					return err
+				}
+				for _, row := range batch {
+					identifier, url := row[4].(string), row[3].(string)
+					if storedURL, exists := storedURLs[identifier]; exists {
+						collisions = append(collisions, models.IdentifierCollision{Kind: models.KindVehicle, Identifier: identifier, Stored: storedURL, Scraped: url})
+					}
+				}
+				_, err = tx.Exec("INSERT INTO Vehicles (" + psqlVehicleColumns + ", updated_at) SELECT " + psqlVehicleColumns +
+					", current_timestamp FROM staging_vehicles" + psqlVehicleConflict + ";")
+				if err != nil {
+					return err
+				}
+				bar.Add(len(batch))
+				return nil
+			})
+	})
+	return collisionError(err, collisions)
+}
+
+// copyParts upserts the parts like InsertParts does row by row, but streams
+// them into a staging table with COPY. Each batch is merged with an upsert
+// that also refreshes the update timestamp of the vehicles with affected
+// parts, and the changed prices are recorded with a single insert. A part
+// listed more than once is written with its last values.
+func copyParts(db *sql.DB, batchSize int, vehicles []models.Vehicle) error {
+	var rows [][]any
+	rowOfPart := make(map[string]int)
+	for _, vehicle := range vehicles {
+		for _, part := range vehicle.Parts {
+			row := []any{part.Name, part.Description, part.PartIdentifier, vehicle.Identifier, part.Price.Amount, part.Price.Currency, part.Price.VATIncluded, part.ImgUrl, part.ImgThumbUrl, part.PartNumber, part.ImgHash, part.ImgThumbHash}
+			if i, exists := rowOfPart[part.PartIdentifier]; exists {
+				rows[i] = row
+				continue
+			}
+			rowOfPart[part.PartIdentifier] = len(rows)
+			rows = append(rows, row)
+		}
+	}
+	var collisions []models.IdentifierCollision
+	bar := newProgressBar(len(rows), true)
+	err := withTransaction(db, func(tx *sql.Tx) error {
+		return copyInBatches(tx, "CREATE TEMP TABLE staging_parts ON COMMIT DROP AS SELECT "+psqlPartColumns+" FROM Parts WITH NO DATA;",
+			"staging_parts", []string{"part_name", "description", "part_id", "vehicle_id", "price_amount", "price_currency", "price_vat_included", "img_url", "img_thumb_url", "part_number", "img_hash", "img_thumb_hash"},
+			rows, batchSize, func(batch [][]any) error {
+				owners, err := queryCollisions(tx, `SELECT S.part_id, P.vehicle_id FROM staging_parts S
+INNER JOIN Parts P ON P.part_id = S.part_id WHERE P.vehicle_id <> S.vehicle_id;`)
+				if err != nil {
+					return err
+				}
+				for _, row := range batch {
+					identifier, vehicleID := row[2].(string), row[3].(string)
+					if owner, exists := owners[identifier]; exists {
+						collisions = append(collisions, models.IdentifierCollision{Kind: models.KindPart, Identifier: identifier, Stored: owner, Scraped: vehicleID})
+					}
+				}
+				_, err = tx.Exec("WITH upserted AS (INSERT INTO Parts (" + psqlPartColumns + ", updated_at) SELECT " + psqlPartColumns +
+					", current_timestamp FROM staging_parts" + psqlPartConflict + `
+RETURNING vehicle_id)
+UPDATE Vehicles SET updated_at = current_timestamp WHERE vehicle_id IN (SELECT vehicle_id FROM upserted);`)
+				if err != nil {
+					return err
+				}
+				// The prices of collided parts are not recorded.
+				_, err = tx.Exec(`INSERT INTO part_price_history (part_id, price_amount, price_currency, price_vat_included)
+SELECT S.part_id, S.price_amount, S.price_currency, S.price_vat_included FROM staging_parts S INNER JOIN Parts P ON P.part_id = S.part_id AND P.vehicle_id = S.vehicle_id
+WHERE NOT EXISTS (SELECT 1 FROM (SELECT H.price_amount, H.price_currency, H.price_vat_included FROM part_price_history H
+WHERE H.part_id = S.part_id ORDER BY H.observed_at DESC, H.id DESC LIMIT 1) L
+WHERE (L.price_amount, L.price_currency, L.price_vat_included) = (S.price_amount, S.price_currency, S.price_vat_included));`)
+				if err != nil {
+					return err
+				}
+				bar.Add(len(batch))
+				return nil
+			})
+	})
+	return collisionError(err, collisions)
+}
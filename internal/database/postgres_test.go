@@ -0,0 +1,161 @@
+package database
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPSQLHandler returns a handler for a fully migrated schema of its own
+// in the PostgreSQL database of POSTGRES_TEST_URL. Without it the test is skipped.
+func newTestPSQLHandler(tb testing.TB) *PSQLHandler {
+	tb.Helper()
+	connectionString := os.Getenv("POSTGRES_TEST_URL")
+	if connectionString == "" {
+		tb.Skip("POSTGRES_TEST_URL is not set")
+	}
+	admin, err := CreatePSQLHandler(connectionString)
+	if err != nil {
+		tb.Fatalf("CreatePSQLHandler() error = %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.DB.Exec("CREATE SCHEMA " + schema + ";"); err != nil {
+		tb.Fatalf("CREATE SCHEMA error = %v", err)
+	}
+	tb.Cleanup(func() {
+		admin.DB.Exec("DROP SCHEMA " + schema + " CASCADE;")
+		admin.Close()
+	})
+
+	// Unknown connection parameters are sent to the server as settings.
+	if strings.Contains(connectionString, "://") {
+		separator := "?"
+		if strings.Contains(connectionString, "?") {
+			separator = "&"
+		}
+		connectionString += separator + "search_path=" + schema
+	} else {
+		connectionString += " search_path=" + schema
+	}
+	handler, err := CreatePSQLHandler(connectionString)
+	if err != nil {
+		tb.Fatalf("CreatePSQLHandler() error = %v", err)
+	}
+	tb.Cleanup(func() { handler.Close() })
+	migrator, err := NewMigrator(handler.DB, "postgres")
+	if err != nil {
+		tb.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		tb.Fatalf("Up() error = %v", err)
+	}
+	return handler
+}
+
+func Test_PSQLHandler_CopyIn(t *testing.T) {
+	for _, batchSize := range []int{0, 2} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			handler := newTestPSQLHandler(t)
+			handler.CopyBatchSize = batchSize
+			vehicles := []models.Vehicle{
+				{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+					Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Etulokasuoja", PartIdentifier: "12", Price: euros(15.5)}}},
+				{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "2", Year: 2017, Url: "https://www.purkuosat.net/suzukirx17.htm",
+					Parts: []models.Part{{Name: "Satula", PartIdentifier: "21", PartNumber: "SR-1", Price: euros(30)}}},
+				{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "3", Year: 2004, Url: "https://www.purkuosat.net/apriliamx12504.htm"},
+			}
+			for _, price := range []float64{30, 25, 25} {
+				vehicles[1].Parts[0].Price = euros(price)
+				if err := handler.InsertVehicles(vehicles); err != nil {
+					t.Fatalf("InsertVehicles() error = %v", err)
+				}
+				if err := handler.InsertParts(vehicles); err != nil {
+					t.Fatalf("InsertParts() error = %v", err)
+				}
+			}
+
+			if count, err := handler.GetVehicleCount(); err != nil || count != 3 {
+				t.Errorf("GetVehicleCount() = %v, %v, want 3", count, err)
+			}
+			parts, err := handler.GetPartsForModel("moped", "Suzuki", "RX", models.ListOptions{})
+			if err != nil || len(parts.Parts) != 3 {
+				t.Errorf("GetPartsForModel() = %+v, %v, want 3 parts", parts, err)
+			}
+			history, err := handler.GetPartPriceHistory("moped", "2", "21")
+			var prices []float64
+			for _, pricePoint := range history {
+				prices = append(prices, pricePoint.Price.Major())
+			}
+			if err != nil || !reflect.DeepEqual(prices, []float64{30, 25}) {
+				t.Errorf("GetPartPriceHistory() prices = %v, %v, want [30 25]", prices, err)
+			}
+
+			// A listing and a part colliding with the stored ones are reported and skipped.
+			others := []models.Vehicle{
+				{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2018, Url: "https://www.purkuosat.net/suzukirx18.htm"},
+				{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "3", Year: 2004, Url: "https://www.purkuosat.net/apriliamx12504.htm",
+					Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "11", Price: euros(12)}}},
+			}
+			var collisionErr *models.CollisionError
+			err = handler.InsertVehicles(others)
+			if !errors.As(err, &collisionErr) || len(collisionErr.Collisions) != 1 || collisionErr.Collisions[0].Stored != vehicles[0].Url {
+				t.Errorf("InsertVehicles() error = %v, want a collision with vehicle 1", err)
+			}
+			err = handler.InsertParts(others)
+			if !errors.As(err, &collisionErr) || len(collisionErr.Collisions) != 1 || collisionErr.Collisions[0].Stored != "1" {
+				t.Errorf("InsertParts() error = %v, want a collision with the part of vehicle 1", err)
+			}
+			if parts, err := handler.GetPartsForVehicle("motorcycle", "3", models.ListOptions{}); err != nil || len(parts.Parts) != 0 {
+				t.Errorf("GetPartsForVehicle() = %+v, %v, want no parts", parts, err)
+			}
+		})
+	}
+}
+
+func Test_PSQLHandler_ListOptions_timestampCursor(t *testing.T) {
+	handler := newTestPSQLHandler(t)
+	testTimestampCursor(t, handler, handler.DB, postgresPlaceholder)
+}
+
+// benchmarkVehicles returns 100 vehicles of 50 parts each with identifiers
+// unique to the round.
+func benchmarkVehicles(round int) []models.Vehicle {
+	vehicles := make([]models.Vehicle, 100)
+	for i := range vehicles {
+		identifier := fmt.Sprintf("%d-%d", round, i)
+		parts := make([]models.Part, 50)
+		for j := range parts {
+			parts[j] = models.Part{Name: "Takarengas", Description: "Hyvä kunto", PartIdentifier: fmt.Sprintf("%s-%d", identifier, j), Price: euros(float64(j))}
+		}
+		vehicles[i] = models.Vehicle{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: identifier, Year: 2019,
+			Url: "https://www.purkuosat.net/" + identifier + ".htm", Parts: parts}
+	}
+	return vehicles
+}
+
+func BenchmarkPSQLHandler_InsertParts(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		batchSize int
+	}{{"row by row", 0}, {"copy", 5000}} {
+		b.Run(bench.name, func(b *testing.B) {
+			handler := newTestPSQLHandler(b)
+			handler.CopyBatchSize = bench.batchSize
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				vehicles := benchmarkVehicles(i)
+				if err := handler.InsertVehicles(vehicles); err != nil {
+					b.Fatalf("InsertVehicles() error = %v", err)
+				}
+				if err := handler.InsertParts(vehicles); err != nil {
+					b.Fatalf("InsertParts() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"Crawler/internal/helpers"
+	"Crawler/internal/models"
+	"database/sql"
+	"strconv"
+)
+
+// isLegacyIdentifier tells whether the identifier is a 32-bit FNV-1a hash in
+// decimal, the format vehicles and parts were stored with before the 128-bit
+// identifiers of helpers.GenerateHash.
+func isLegacyIdentifier(identifier string) bool {
+	_, err := strconv.ParseUint(identifier, 10, 32)
+	return err == nil
+}
+
+// RekeyLegacyIdentifiers moves the removed vehicles and parts still stored
+// with legacy identifiers to 128-bit ones, along with their price history and
+// compatibilities. The crawl re-keys the listings still on the site to the
+// identifiers of their listing name and site part numbers, but neither was
+// stored before migration 0008, so the removed ones cannot get the identifiers
+// the crawl would give them. Instead a vehicle is identified by its listing
+// URL and legacy identifier, and a part by its vehicle and legacy identifier.
+// These listings are not crawled again: a listing that comes back is stored
+// as a new vehicle, as it would be with its legacy identifier.
+//
+// Vehicles still listed are left for the crawl, so this is best run after a
+// crawl of every category. It returns the number of re-keyed vehicles and
+// parts.
+func (m *Migrator) RekeyLegacyIdentifiers() (int, int, error) {
+	if err := m.CheckCurrent(); err != nil {
+		return 0, 0, err
+	}
+	removedVehicles, err := queryPairs(m.DB, "SELECT vehicle_id, listing_url FROM Vehicles WHERE deleted_at IS NOT NULL;")
+	if err != nil {
+		return 0, 0, err
+	}
+	newVehicleIDs := make(map[string]string)
+	var vehicleChanges []models.IdentifierChange
+	for _, vehicle := range removedVehicles {
+		if isLegacyIdentifier(vehicle[0]) {
+			newVehicleIDs[vehicle[0]] = helpers.GenerateHash(vehicle[1], vehicle[0])
+			vehicleChanges = append(vehicleChanges, models.IdentifierChange{Old: vehicle[0], New: newVehicleIDs[vehicle[0]]})
+		}
+	}
+
+	// Removing a vehicle removes its parts too.
+	removedParts, err := queryPairs(m.DB, "SELECT part_id, vehicle_id FROM Parts WHERE deleted_at IS NOT NULL;")
+	if err != nil {
+		return 0, 0, err
+	}
+	var partChanges []models.IdentifierChange
+	for _, part := range removedParts {
+		if !isLegacyIdentifier(part[0]) {
+			continue
+		}
+		vehicleID := part[1]
+		if newID, rekeyed := newVehicleIDs[vehicleID]; rekeyed {
+			vehicleID = newID
+		}
+		partChanges = append(partChanges, models.IdentifierChange{Old: part[0], New: helpers.GenerateHash(vehicleID, part[0])})
+	}
+
+	var handler models.DatabaseHandler = &SQLiteHandler{DB: m.DB}
+	if m.Driver == "postgres" {
+		handler = &PSQLHandler{DB: m.DB}
+	}
+	err = handler.RekeyIdentifiers(vehicleChanges, partChanges)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(vehicleChanges), len(partChanges), nil
+}
+
+// queryPairs returns the rows of a query selecting two text columns.
+func queryPairs(db *sql.DB, query string) ([][2]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs [][2]string
+	for rows.Next() {
+		var pair [2]string
+		err = rows.Scan(&pair[0], &pair[1])
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, rows.Err()
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"Crawler/internal/helpers"
+	"Crawler/internal/models"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_Migrator_RekeyLegacyIdentifiers(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	current := helpers.GenerateHash("https://www.purkuosat.net/suzukirx19.htm", "Suzuki RX 2019")
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "123", Year: 2017, Url: "https://www.purkuosat.net/suzukirx17.htm",
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "1231", Price: euros(30)}}},
+		{Brand: "Polini", Model: "XP4 50", VehicleType: "moped", Identifier: "456", Year: 2007, Url: "https://www.purkuosat.net/polinixp450.htm",
+			Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "4561", Price: euros(10)}, {Name: "Vilkku", PartIdentifier: "4562", Price: euros(5)}}},
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: current, Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "789", Price: euros(20)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	if err := handler.InsertCompatibilities([]models.Compatibility{{PartIdentifier: "1231", Brand: "Polini", Model: "XP4 50", YearFrom: 2007, YearTo: 2007, Source: "part_name"}}); err != nil {
+		t.Fatalf("InsertCompatibilities() error = %v", err)
+	}
+	// The Suzuki of 2017 was sold, so were the blinker of the Polini and the
+	// rear wheel of the Suzuki of 2019, whose listing a crawl re-keyed already.
+	if err := handler.DeleteVehicles([]string{"123"}); err != nil {
+		t.Fatalf("DeleteVehicles() error = %v", err)
+	}
+	if err := handler.DeleteParts([]string{"4562", "789"}); err != nil {
+		t.Fatalf("DeleteParts() error = %v", err)
+	}
+
+	migrator, err := NewMigrator(handler.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	vehicleCount, partCount, err := migrator.RekeyLegacyIdentifiers()
+	if err != nil || vehicleCount != 1 || partCount != 3 {
+		t.Fatalf("RekeyLegacyIdentifiers() = %d, %d, %v, want 1 vehicle and 3 parts", vehicleCount, partCount, err)
+	}
+
+	sold := helpers.GenerateHash("https://www.purkuosat.net/suzukirx17.htm", "123")
+	vehicleIDs, _ := queryStrings(handler.DB, "SELECT vehicle_id FROM Vehicles;")
+	// The Polini is still listed and left for the crawl.
+	if want := []string{current, "456", sold}; !sameStrings(vehicleIDs, want) {
+		t.Errorf("vehicle ids = %v, want %v", vehicleIDs, want)
+	}
+	soldPart := helpers.GenerateHash(sold, "1231")
+	partIDs, _ := queryStrings(handler.DB, "SELECT part_id FROM Parts;")
+	if want := []string{soldPart, "4561", helpers.GenerateHash("456", "4562"), helpers.GenerateHash(current, "789")}; !sameStrings(partIDs, want) {
+		t.Errorf("part ids = %v, want %v", partIDs, want)
+	}
+	if vehicleID, _ := queryStrings(handler.DB, "SELECT vehicle_id FROM Parts WHERE part_id = ?;", soldPart); !reflect.DeepEqual(vehicleID, []string{sold}) {
+		t.Errorf("vehicle of the re-keyed part = %v, want %v", vehicleID, sold)
+	}
+	if history, _ := queryStrings(handler.DB, "SELECT part_id FROM part_price_history WHERE part_id = ?;", soldPart); len(history) != 1 {
+		t.Errorf("price history of the re-keyed part = %v", history)
+	}
+	if compatible, _ := queryStrings(handler.DB, "SELECT part_id FROM part_compatibility;"); !reflect.DeepEqual(compatible, []string{soldPart}) {
+		t.Errorf("compatible parts = %v, want %v", compatible, soldPart)
+	}
+
+	// Nothing is left to re-key.
+	if vehicleCount, partCount, err := migrator.RekeyLegacyIdentifiers(); err != nil || vehicleCount != 0 || partCount != 0 {
+		t.Errorf("second RekeyLegacyIdentifiers() = %d, %d, %v, want nothing re-keyed", vehicleCount, partCount, err)
+	}
+}
+
+// sameStrings tells whether the strings are the same in any order.
+func sameStrings(got []string, want []string) bool {
+	got, want = append([]string{}, got...), append([]string{}, want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	return reflect.DeepEqual(got, want)
+}
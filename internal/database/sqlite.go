@@ -0,0 +1,280 @@
+package database
+
+import (
+	"Crawler/internal/modelname"
+	"Crawler/internal/models"
+	"Crawler/internal/search"
+	"database/sql"
+	"errors"
+	"log"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteHandler stores vehicles and parts in an embedded SQLite database file.
+type SQLiteHandler struct {
+	DB *sql.DB
+}
+
+// CreateSQLiteHandler opens (or creates) the SQLite database file at path and returns the handler.
+func CreateSQLiteHandler(path string) (*SQLiteHandler, error) {
+	if len(path) == 0 {
+		return nil, errors.New("sqlite database path is not configured")
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows only one writer at a time.
+	db.SetMaxOpenConns(1)
+
+	// Verify the database file can be opened
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Printf("Successfully opened SQLite database %s!", path)
+	return &SQLiteHandler{DB: db}, nil
+}
+
+func (handler *SQLiteHandler) Close() error {
+	return handler.DB.Close()
+}
+
+func (handler *SQLiteHandler) InsertVehicles(vehicles []models.Vehicle) error {
+	return insertVehicles(handler.DB, `INSERT INTO Vehicles (vehicle_type, brand_name, model_name, listing_url, vehicle_id, year, model_key, model_family, displacement, year_from, year_to, stroke, trim_level, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, current_timestamp)
+ON CONFLICT (vehicle_id) DO UPDATE SET vehicle_type = excluded.vehicle_type, brand_name = excluded.brand_name, model_name = excluded.model_name, listing_url = excluded.listing_url, year = excluded.year,
+model_key = excluded.model_key, model_family = excluded.model_family, displacement = excluded.displacement,
+year_from = excluded.year_from, year_to = excluded.year_to, stroke = excluded.stroke, trim_level = excluded.trim_level, updated_at = current_timestamp, deleted_at = NULL
+WHERE Vehicles.listing_url = excluded.listing_url AND (Vehicles.vehicle_type IS NOT excluded.vehicle_type OR Vehicles.brand_name IS NOT excluded.brand_name
+OR Vehicles.model_name IS NOT excluded.model_name OR Vehicles.year IS NOT excluded.year OR Vehicles.model_key IS NOT excluded.model_key
+OR Vehicles.model_family IS NOT excluded.model_family OR Vehicles.displacement IS NOT excluded.displacement
+OR Vehicles.year_from IS NOT excluded.year_from OR Vehicles.year_to IS NOT excluded.year_to OR Vehicles.stroke IS NOT excluded.stroke
+OR Vehicles.trim_level IS NOT excluded.trim_level OR Vehicles.deleted_at IS NOT NULL);`,
+		"SELECT listing_url FROM Vehicles WHERE vehicle_id = ?;",
+		vehicles, false)
+}
+
+// InsertParts adds the parts to the database in a batch.
+func (handler *SQLiteHandler) InsertParts(vehicles []models.Vehicle) error {
+	return insertParts(handler.DB, `INSERT INTO Parts (part_name, description, part_id, vehicle_id, price_amount, price_currency, price_vat_included, img_url, img_thumb_url, part_number, img_hash, img_thumb_hash, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, current_timestamp)
+ON CONFLICT (part_id) DO UPDATE SET part_name = excluded.part_name, description = excluded.description, price_amount = excluded.price_amount, price_currency = excluded.price_currency,
+price_vat_included = excluded.price_vat_included, img_url = excluded.img_url, img_thumb_url = excluded.img_thumb_url,
+part_number = excluded.part_number, img_hash = excluded.img_hash, img_thumb_hash = excluded.img_thumb_hash, updated_at = current_timestamp, deleted_at = NULL
+WHERE Parts.vehicle_id = excluded.vehicle_id AND (Parts.part_name IS NOT excluded.part_name OR Parts.description IS NOT excluded.description OR Parts.price_amount IS NOT excluded.price_amount
+OR Parts.price_currency IS NOT excluded.price_currency OR Parts.price_vat_included IS NOT excluded.price_vat_included OR Parts.img_url IS NOT excluded.img_url OR Parts.img_thumb_url IS NOT excluded.img_thumb_url OR Parts.part_number IS NOT excluded.part_number
+OR Parts.img_hash IS NOT excluded.img_hash OR Parts.img_thumb_hash IS NOT excluded.img_thumb_hash OR Parts.deleted_at IS NOT NULL);`,
+		"SELECT vehicle_id FROM Parts WHERE part_id = ?;",
+		"UPDATE Vehicles SET updated_at = current_timestamp WHERE vehicle_id = ?;",
+		`INSERT INTO part_price_history (part_id, price_amount, price_currency, price_vat_included) SELECT ?1, ?2, ?3, ?4
+WHERE (?2, ?3, ?4) IS NOT (SELECT price_amount, price_currency, price_vat_included FROM part_price_history WHERE part_id = ?1 ORDER BY observed_at DESC, id DESC LIMIT 1);`,
+		vehicles, false)
+}
+
+// RekeyIdentifiers renames the vehicles and parts. The search documents are
+// renamed before the vehicles, so that the triggers find them.
+func (handler *SQLiteHandler) RekeyIdentifiers(vehicles []models.IdentifierChange, parts []models.IdentifierChange) error {
+	return rekeyIdentifiers(handler.DB, "PRAGMA defer_foreign_keys = ON;",
+		[]string{
+			"UPDATE search_documents SET ref_id = ? WHERE kind = 'vehicle' AND ref_id = ?;",
+			"UPDATE Vehicles SET vehicle_id = ? WHERE vehicle_id = ?;",
+			"UPDATE Parts SET vehicle_id = ? WHERE vehicle_id = ?;",
+		},
+		[]string{
+			"UPDATE search_documents SET ref_id = ? WHERE kind = 'part' AND ref_id = ?;",
+			"UPDATE Parts SET part_id = ? WHERE part_id = ?;",
+			"UPDATE part_price_history SET part_id = ? WHERE part_id = ?;",
+			"UPDATE part_compatibility SET part_id = ? WHERE part_id = ?;",
+		},
+		vehicles, parts)
+}
+
+func (handler *SQLiteHandler) DeleteVehicles(vehicleIdentifiers []string) error {
+	return execForEach(handler.DB, vehicleIdentifiers,
+		"UPDATE Vehicles SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE vehicle_id = ? AND deleted_at IS NULL;",
+		"UPDATE Parts SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE vehicle_id = ? AND deleted_at IS NULL;")
+}
+
+func (handler *SQLiteHandler) DeleteParts(partIdentifiers []string) error {
+	return execForEach(handler.DB, partIdentifiers,
+		"UPDATE Vehicles SET updated_at = current_timestamp WHERE vehicle_id = (SELECT vehicle_id FROM Parts WHERE part_id = ? AND deleted_at IS NULL);",
+		"UPDATE Parts SET deleted_at = current_timestamp, updated_at = current_timestamp WHERE part_id = ? AND deleted_at IS NULL;")
+}
+
+func (handler *SQLiteHandler) GetVehicleCount() (int, error) {
+	count := 0
+	err := handler.DB.QueryRow("SELECT COUNT(vehicle_id) FROM Vehicles WHERE deleted_at IS NULL;").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (handler *SQLiteHandler) GetBrands(vehicleType string) ([]string, error) {
+	return queryStrings(handler.DB, "SELECT DISTINCT(brand_name) FROM Vehicles WHERE deleted_at IS NULL AND vehicle_type = ? ORDER BY brand_name ASC;", vehicleType)
+}
+
+func (handler *SQLiteHandler) GetModelsForBrand(vehicleType string, brandName string) ([]string, error) {
+	families, err := handler.GetModelFamilies(vehicleType, brandName)
+	if err != nil {
+		return nil, err
+	}
+	return modelNames(families), nil
+}
+
+func (handler *SQLiteHandler) GetModelFamilies(vehicleType string, brandName string) ([]models.ModelFamily, error) {
+	return queryModelFamilies(handler.DB, `SELECT coalesce(model_key, ''), coalesce(model_family, ''), model_name, coalesce(displacement, 0), COUNT(*)
+FROM Vehicles WHERE deleted_at IS NULL AND vehicle_type = ? AND brand_name = ? GROUP BY model_key, model_family, model_name, displacement;`, vehicleType, brandName)
+}
+
+func (handler *SQLiteHandler) GetVehicle(vehicleType string, vehicleIdentifier string) (models.Vehicle, error) {
+	return queryVehicle(handler.DB, "SELECT "+vehicleListColumns+" FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = ? AND V.vehicle_id = ?;",
+		vehicleType, vehicleIdentifier)
+}
+
+func (handler *SQLiteHandler) GetVehicleTypes() ([]string, error) {
+	return queryStrings(handler.DB, "SELECT DISTINCT(vehicle_type) FROM Vehicles WHERE deleted_at IS NULL ORDER BY vehicle_type ASC;")
+}
+
+func (handler *SQLiteHandler) GetVehiclesForType(vehicleType string, options models.ListOptions) (models.VehiclePage, error) {
+	return queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = ?",
+		args:        []any{vehicleType},
+		defaultSort: models.SortBrand,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetVehiclesForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.VehiclePage, error) {
+	return queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL AND V.vehicle_type = ? AND V.brand_name = ? AND (V.model_key = ? OR V.model_key IS NULL AND V.model_name = ?)",
+		args:        []any{vehicleType, brandName, modelname.Normalize(modelName).Key, modelName},
+		defaultSort: models.SortYear,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetPartsForVehicle(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	// Distinguish an unknown vehicle from a vehicle without parts.
+	_, err := handler.GetVehicle(vehicleType, vehicleIdentifier)
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	return queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = ? AND V.vehicle_id = ?",
+		args:        []any{vehicleType, vehicleIdentifier},
+		defaultSort: models.SortName,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetPartsForModel(vehicleType string, brandName string, modelName string, options models.ListOptions) (models.PartPage, error) {
+	return queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = ? AND V.brand_name = ? AND (V.model_key = ? OR V.model_key IS NULL AND V.model_name = ?)",
+		args:        []any{vehicleType, brandName, modelname.Normalize(modelName).Key, modelName},
+		defaultSort: models.SortYear,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetRecentVehicles(options models.ListOptions) (models.VehiclePage, error) {
+	return queryVehiclePage(handler.DB, listQuery{
+		from:        "FROM Vehicles V WHERE V.deleted_at IS NULL",
+		defaultSort: "-" + models.SortUpdatedAt,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetRecentParts(options models.ListOptions) (models.PartPage, error) {
+	return queryPartPage(handler.DB, listQuery{
+		from:        "FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL",
+		defaultSort: "-" + models.SortUpdatedAt,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+func (handler *SQLiteHandler) GetPartPriceHistory(vehicleType string, vehicleIdentifier string, partIdentifier string) ([]models.PricePoint, error) {
+	return queryPricePoints(handler.DB, "SELECT H.price_amount, H.price_currency, H.price_vat_included, H.observed_at FROM part_price_history H INNER JOIN Parts P ON H.part_id = P.part_id INNER JOIN Vehicles V ON P.vehicle_id = V.vehicle_id WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_type = ? AND V.vehicle_id = ? AND P.part_id = ? ORDER BY H.observed_at ASC, H.id ASC;",
+		vehicleType, vehicleIdentifier, partIdentifier)
+}
+
+func (handler *SQLiteHandler) InsertCompatibilities(compatibilities []models.Compatibility) error {
+	return insertCompatibilities(handler.DB, "INSERT INTO part_compatibility (part_id, brand_name, model_name, year_from, year_to, source, model_key) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;", compatibilities)
+}
+
+func (handler *SQLiteHandler) InsertImages(images []models.Image) error {
+	return insertImages(handler.DB, "INSERT INTO images (hash, source_url, size, mime_type, width, height, phash) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;", images)
+}
+
+func (handler *SQLiteHandler) GetImage(hash string) (models.Image, error) {
+	return queryImage(handler.DB, "SELECT hash, source_url, size, mime_type, width, height, coalesce(phash, '') FROM images WHERE hash = ?;", hash)
+}
+
+func (handler *SQLiteHandler) GetDuplicateImages(maxDistance int) ([]models.DuplicateImageGroup, error) {
+	return queryDuplicateImages(handler.DB, maxDistance)
+}
+
+func (handler *SQLiteHandler) GetCompatibleParts(vehicleType string, vehicleIdentifier string, options models.ListOptions) (models.PartPage, error) {
+	// Distinguish an unknown vehicle from a vehicle without compatible parts.
+	_, err := handler.GetVehicle(vehicleType, vehicleIdentifier)
+	if err != nil {
+		return models.PartPage{}, err
+	}
+	return queryPartPage(handler.DB, listQuery{
+		from: `FROM Vehicles V INNER JOIN Parts P ON V.vehicle_id = P.vehicle_id
+WHERE V.deleted_at IS NULL AND P.deleted_at IS NULL AND V.vehicle_id <> ? AND EXISTS (
+SELECT 1 FROM part_compatibility C
+INNER JOIN Vehicles T ON C.brand_name = T.brand_name AND T.year BETWEEN C.year_from AND C.year_to
+AND (C.model_key = T.model_key OR (C.model_key IS NULL OR T.model_key IS NULL) AND C.model_name = T.model_name)
+WHERE C.part_id = P.part_id AND T.vehicle_type = ? AND T.vehicle_id = ?)`,
+		args:        []any{vehicleIdentifier, vehicleType, vehicleIdentifier},
+		defaultSort: models.SortName,
+		placeholder: sqlitePlaceholder,
+	}, options)
+}
+
+// Search matches the query against the FTS4 search index. FTS4 has no
+// ranking function of its own, so the hits are ranked from their documents.
+func (handler *SQLiteHandler) Search(query string, limit int) ([]models.SearchHit, error) {
+	root, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	// Vehicle hits repeat the vehicle timestamps as part timestamps: the
+	// timestamps are only parsed when the first SELECT selects plain columns.
+	ftsQuery := search.FTSQuery(root)
+	rows, err := handler.DB.Query(`SELECT S.body, D.kind, `+vehicleListColumns+`, '', '', '', '', 0, '', 0, '', '', '', '', V.created_at, V.updated_at
+FROM search_index S
+INNER JOIN search_documents D ON D.docid = S.docid
+INNER JOIN Vehicles V ON D.kind = 'vehicle' AND V.vehicle_id = D.ref_id
+WHERE search_index MATCH ? AND V.deleted_at IS NULL
+UNION ALL
+SELECT S.body, D.kind, `+partListColumns+`
+FROM search_index S
+INNER JOIN search_documents D ON D.docid = S.docid
+INNER JOIN Parts P ON D.kind = 'part' AND P.part_id = D.ref_id
+INNER JOIN Vehicles V ON V.vehicle_id = P.vehicle_id
+WHERE search_index MATCH ? AND V.deleted_at IS NULL AND P.deleted_at IS NULL;`, ftsQuery, ftsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var document string
+		hit, err := scanSearchHit(rows, &document)
+		if err != nil {
+			return nil, err
+		}
+		hit.Rank = search.Rank(root, search.Tokenize(document))
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortSearchHits(hits, limit), nil
+}
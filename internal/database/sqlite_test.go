@@ -0,0 +1,767 @@
+package database
+
+import (
+	"Crawler/internal/models"
+	"Crawler/internal/search"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSQLiteHandler returns a handler for a fully migrated temporary SQLite database.
+func newTestSQLiteHandler(t *testing.T) *SQLiteHandler {
+	t.Helper()
+	handler, err := CreateSQLiteHandler(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLiteHandler() error = %v", err)
+	}
+	t.Cleanup(func() { handler.Close() })
+	migrator, err := NewMigrator(handler.DB, "sqlite")
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	return handler
+}
+
+// euros returns a price in euros with VAT included.
+func euros(amount float64) models.Price {
+	return models.NewPrice(amount, models.DefaultCurrency, true)
+}
+
+func Test_SQLiteHandler(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Etulokasuoja", PartIdentifier: "12", Price: euros(15.5)}}},
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "2", Year: 2017, Url: "https://www.purkuosat.net/suzukirx17.htm",
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "21", Price: euros(30)}}},
+		{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "3", Year: 2004, Url: "https://www.purkuosat.net/apriliamx12504.htm"},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	count, err := handler.GetVehicleCount()
+	if err != nil || count != 3 {
+		t.Errorf("GetVehicleCount() = %v, %v, want 3", count, err)
+	}
+	types, err := handler.GetVehicleTypes()
+	if err != nil || !reflect.DeepEqual(types, []string{"moped", "motorcycle"}) {
+		t.Errorf("GetVehicleTypes() = %v, %v", types, err)
+	}
+	brands, err := handler.GetBrands("moped")
+	if err != nil || !reflect.DeepEqual(brands, []string{"Suzuki"}) {
+		t.Errorf("GetBrands() = %v, %v", brands, err)
+	}
+	parts, err := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 2 || parts.Parts[0].Part.Name != "Etulokasuoja" {
+		t.Errorf("GetPartsForVehicle() = %+v, %v", parts, err)
+	}
+	parts, err = handler.GetPartsForModel("moped", "Suzuki", "RX", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 3 || parts.Parts[0].Vehicle.Year != 2017 {
+		t.Errorf("GetPartsForModel() = %+v, %v", parts, err)
+	}
+	if _, err := handler.GetVehicle("moped", "3"); err == nil {
+		t.Errorf("GetVehicle() with wrong vehicle type should fail")
+	}
+	if _, err := handler.GetPartsForVehicle("motorcycle", "1", models.ListOptions{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPartsForVehicle() with wrong vehicle type error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func Test_SQLiteHandler_ChangeTracking(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Satula", PartIdentifier: "12", Price: euros(30)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	// Move the timestamps to the past to see which rows the next writes touch.
+	handler.DB.Exec("UPDATE Vehicles SET updated_at = '2000-01-01 00:00:00';")
+	handler.DB.Exec("UPDATE Parts SET updated_at = '2000-01-01 00:00:00';")
+	partUpdated := func(partID string) bool {
+		var updated bool
+		handler.DB.QueryRow("SELECT updated_at > '2000-01-01 00:00:00' FROM Parts WHERE part_id = ?;", partID).Scan(&updated)
+		return updated
+	}
+	vehicleUpdated := func() bool {
+		var updated bool
+		handler.DB.QueryRow("SELECT updated_at > '2000-01-01 00:00:00' FROM Vehicles WHERE vehicle_id = '1';").Scan(&updated)
+		return updated
+	}
+
+	// Re-inserting unchanged rows must not touch them.
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	if vehicleUpdated() || partUpdated("11") || partUpdated("12") {
+		t.Fatalf("unchanged rows were updated")
+	}
+
+	vehicles[0].Parts = []models.Part{{Name: "Satula", PartIdentifier: "12", Price: euros(25)}}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	if !partUpdated("12") || partUpdated("11") || !vehicleUpdated() {
+		t.Errorf("changed part or its vehicle was not updated")
+	}
+
+	if err := handler.DeleteParts([]string{"11"}); err != nil {
+		t.Fatalf("DeleteParts() error = %v", err)
+	}
+	parts, err := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.Price != euros(25) {
+		t.Errorf("GetPartsForVehicle() after DeleteParts() = %+v, %v", parts, err)
+	}
+
+	if err := handler.DeleteVehicles([]string{"1"}); err != nil {
+		t.Fatalf("DeleteVehicles() error = %v", err)
+	}
+	if count, _ := handler.GetVehicleCount(); count != 0 {
+		t.Errorf("GetVehicleCount() after DeleteVehicles() = %d, want 0", count)
+	}
+
+	// Inserting a deleted vehicle and part again restores them.
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	parts, err = handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 {
+		t.Errorf("GetPartsForVehicle() after restoring = %+v, %v", parts, err)
+	}
+}
+
+func Test_SQLiteHandler_GetPartPriceHistory(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "12", Price: euros(30)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	// A price without VAT is another price even when the amount is the same.
+	withoutVAT := models.NewPrice(30, models.DefaultCurrency, false)
+	for _, price := range []models.Price{euros(30), euros(30), euros(25), euros(25), euros(30), withoutVAT} {
+		vehicles[0].Parts[0].Price = price
+		if err := handler.InsertParts(vehicles); err != nil {
+			t.Fatalf("InsertParts() error = %v", err)
+		}
+	}
+
+	history, err := handler.GetPartPriceHistory("moped", "1", "12")
+	if err != nil {
+		t.Fatalf("GetPartPriceHistory() error = %v", err)
+	}
+	var prices []models.Price
+	for _, pricePoint := range history {
+		prices = append(prices, pricePoint.Price)
+	}
+	if want := []models.Price{euros(30), euros(25), euros(30), withoutVAT}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("GetPartPriceHistory() prices = %v, want %v", prices, want)
+	}
+	if _, err := handler.GetPartPriceHistory("moped", "2", "12"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPartPriceHistory() of another vehicle error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func Test_SQLiteHandler_GetCompatibleParts(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Yamaha", Model: "DT 50", VehicleType: "moped", Identifier: "1", Year: 2004, Url: "https://www.purkuosat.net/yamahadt5004.htm",
+			Parts: []models.Part{{Name: "Jarrukahva", PartIdentifier: "11"}}},
+		{Brand: "MBK", Model: "X-Limit", VehicleType: "moped", Identifier: "2", Year: 2005, Url: "https://www.purkuosat.net/mbkxlimit05.htm",
+			Parts: []models.Part{{Name: "Jarrukahva", PartIdentifier: "21"}}},
+		{Brand: "Yamaha", Model: "DT50", VehicleType: "moped", Identifier: "3", Year: 2004, Url: "https://www.purkuosat.net/yamahadt50_04.htm"},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	compatibilities := []models.Compatibility{
+		{PartIdentifier: "11", Brand: "MBK", Model: "X-Limit", YearFrom: 2005, YearTo: 2005, Source: "part_number"},
+		{PartIdentifier: "21", Brand: "Yamaha", Model: "DT 50", YearFrom: 2004, YearTo: 2004, Source: "part_number"},
+	}
+	// Inserting twice must not fail.
+	for i := 0; i < 2; i++ {
+		if err := handler.InsertCompatibilities(compatibilities); err != nil {
+			t.Fatalf("InsertCompatibilities() error = %v", err)
+		}
+	}
+
+	parts, err := handler.GetCompatibleParts("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.PartIdentifier != "21" || parts.Parts[0].Vehicle.Brand != "MBK" {
+		t.Errorf("GetCompatibleParts() = %+v, %v", parts, err)
+	}
+	// Another spelling of the model gets the same compatible parts.
+	parts, err = handler.GetCompatibleParts("moped", "3", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.PartIdentifier != "21" {
+		t.Errorf("GetCompatibleParts() of a spelling variant = %+v, %v", parts, err)
+	}
+	if _, err := handler.GetCompatibleParts("moped", "4", models.ListOptions{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetCompatibleParts() of an unknown vehicle error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// searchHitIDs returns the vehicle id of vehicle hits and the part id of part hits.
+func searchHitIDs(hits []models.SearchHit) []string {
+	ids := []string{}
+	for _, hit := range hits {
+		if hit.Part != nil {
+			ids = append(ids, hit.Part.PartIdentifier)
+		} else {
+			ids = append(ids, hit.Vehicle.Identifier)
+		}
+	}
+	return ids
+}
+
+func Test_SQLiteHandler_Search(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", Description: "Hyvä kunto", PartIdentifier: "11"}, {Name: "Etulokasuoja", Description: "Naarmuja", PartIdentifier: "12"}}},
+		{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "2", Year: 2004, Url: "https://www.purkuosat.net/apriliamx12504.htm",
+			Parts: []models.Part{{Name: "Eturengas", Description: "Kulunut", PartIdentifier: "21"}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Test vehicle ranks above its parts", "suzuki", []string{"1", "12", "11"}},
+		{"Test part matches on its vehicle", "aprilia eturengas", []string{"21"}},
+		{"Test OR", "takarengas OR eturengas", []string{"11", "21"}},
+		{"Test NOT", "suzuki -taka* -etu*", []string{"1"}},
+		{"Test phrase", `"hyvä kunto"`, []string{"11"}},
+		{"Test phrase in wrong order", `"kunto hyvä"`, []string{}},
+		{"Test prefix", "etu*", []string{"12", "21"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := handler.Search(tt.query, 10)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if got := searchHitIDs(hits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Renamed vehicles and parts are reindexed, deleted ones are not found.
+	vehicles[0].Brand = "Yamaha"
+	vehicles[0].Parts[0].Name = "Rengas"
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	if err := handler.DeleteParts([]string{"12"}); err != nil {
+		t.Fatalf("DeleteParts() error = %v", err)
+	}
+	hits, err := handler.Search("yamaha", 10)
+	if got := searchHitIDs(hits); err != nil || !reflect.DeepEqual(got, []string{"1", "11"}) {
+		t.Errorf("Search() after changes = %v, %v, want [1 11]", got, err)
+	}
+	hits, err = handler.Search("suzuki OR takarengas", 10)
+	if err != nil || len(hits) != 0 {
+		t.Errorf("Search() of old names = %v, %v, want no hits", searchHitIDs(hits), err)
+	}
+	if hits, err = handler.Search("suzuki OR aprilia", 1); err != nil || len(hits) != 1 {
+		t.Errorf("Search() with limit 1 = %v, %v", searchHitIDs(hits), err)
+	}
+	if _, err = handler.Search("-suzuki", 10); !errors.Is(err, search.ErrInvalidQuery) {
+		t.Errorf("Search() of an invalid query error = %v, want search.ErrInvalidQuery", err)
+	}
+}
+
+// listAllParts follows the cursors of GetPartsForModel and returns the part ids of every page.
+func listAllParts(t *testing.T, handler models.DatabaseHandler, options models.ListOptions) []string {
+	t.Helper()
+	ids := []string{}
+	for pages := 0; ; pages++ {
+		if pages == 10 {
+			t.Fatalf("pagination did not end, got %v", ids)
+		}
+		page, err := handler.GetPartsForModel("moped", "Suzuki", "RX", options)
+		if err != nil {
+			t.Fatalf("GetPartsForModel() error = %v", err)
+		}
+		for _, vehicleAndPart := range page.Parts {
+			ids = append(ids, vehicleAndPart.Part.PartIdentifier)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		options.Cursor = page.NextCursor
+	}
+}
+
+func Test_SQLiteHandler_ListOptions(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	// The amount of the crowns is within the euro price range, but is not compared with euros.
+	crowns := models.Price{Amount: 1500, Currency: "SEK", VATIncluded: true}
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Etulokasuoja", PartIdentifier: "12", Price: euros(15.5)}, {Name: "Satula", PartIdentifier: "13", Price: euros(20)}}},
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "2", Year: 2017, Url: "https://www.purkuosat.net/suzukirx17.htm",
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "21", Price: euros(30)}, {Name: "Vilkku", PartIdentifier: "22", Price: euros(5)}}},
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "3", Year: 2012, Url: "https://www.purkuosat.net/suzukirx12.htm",
+			Parts: []models.Part{{Name: "Peili", PartIdentifier: "31", Price: euros(8)}, {Name: "Peili", PartIdentifier: "32", Price: crowns}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	minPrice, maxPrice := int64(800), int64(2000)
+	minCrowns := int64(1000)
+
+	tests := []struct {
+		name    string
+		options models.ListOptions
+		want    []string
+	}{
+		{"Test default order", models.ListOptions{Limit: 2}, []string{"31", "32", "21", "22", "11", "12", "13"}},
+		{"Test price", models.ListOptions{Limit: 2, Sort: "price"}, []string{"22", "31", "12", "11", "13", "21"}},
+		{"Test descending price", models.ListOptions{Limit: 4, Sort: "-price"}, []string{"21", "13", "11", "12", "31", "22"}},
+		{"Test price in another currency", models.ListOptions{Sort: "price", Currency: "SEK"}, []string{"32"}},
+		{"Test name", models.ListOptions{Limit: 1, Sort: "name"}, []string{"12", "31", "32", "13", "21", "11", "22"}},
+		{"Test created_at", models.ListOptions{Limit: 5, Sort: "created_at"}, []string{"11", "12", "13", "21", "22", "31", "32"}},
+		{"Test year range", models.ListOptions{Limit: 1, MinYear: 2013, MaxYear: 2018}, []string{"21", "22"}},
+		{"Test price range", models.ListOptions{Limit: 2, Sort: "-year", MinPrice: &minPrice, MaxPrice: &maxPrice}, []string{"13", "12", "11", "31"}},
+		{"Test price range in another currency", models.ListOptions{MinPrice: &minCrowns, Currency: "SEK"}, []string{"32"}},
+		{"Test without limit", models.ListOptions{Sort: "brand"}, []string{"11", "12", "13", "21", "22", "31", "32"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listAllParts(t, handler, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	page, err := handler.GetVehiclesForModel("moped", "Suzuki", "RX", models.ListOptions{Limit: 2, Sort: "-year"})
+	if err != nil || len(page.Vehicles) != 2 || page.Vehicles[0].Identifier != "1" || page.NextCursor == "" {
+		t.Errorf("GetVehiclesForModel() = %+v, %v", page, err)
+	}
+	invalid := []models.ListOptions{
+		{Sort: "price"},
+		{MinPrice: &minPrice},
+		{Currency: "EUR"},
+		{Cursor: "not a cursor"},
+		{Cursor: page.NextCursor, Sort: "year"},
+	}
+	for _, options := range invalid {
+		if _, err := handler.GetVehiclesForModel("moped", "Suzuki", "RX", options); !errors.Is(err, models.ErrInvalidListOptions) {
+			t.Errorf("GetVehiclesForModel(%+v) error = %v, want models.ErrInvalidListOptions", options, err)
+		}
+	}
+}
+
+// testTimestampCursor pages through parts sorted by timestamps that are equal
+// or apart by less than a second. placeholder is the bind placeholder of db.
+func testTimestampCursor(t *testing.T, handler models.DatabaseHandler, db *sql.DB, placeholder func(int) string) {
+	t.Helper()
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11"}, {Name: "Etulokasuoja", PartIdentifier: "12"}, {Name: "Satula", PartIdentifier: "13"},
+				{Name: "Vilkku", PartIdentifier: "14"}, {Name: "Peili", PartIdentifier: "15"}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	update := "UPDATE Parts SET updated_at = " + placeholder(1) + " WHERE part_id = " + placeholder(2) + ";"
+	for partID, updatedAt := range map[string]string{"11": "2000-01-01 00:00:00.5", "12": "2000-01-01 00:00:00", "13": "2000-01-01 00:00:00.25",
+		"14": "2000-01-01 00:00:00", "15": "2000-01-01 00:00:00.5"} {
+		if _, err := db.Exec(update, updatedAt, partID); err != nil {
+			t.Fatalf("setting updated_at error = %v", err)
+		}
+	}
+
+	for _, limit := range []int{1, 2, 3} {
+		if got, want := listAllParts(t, handler, models.ListOptions{Limit: limit, Sort: "updated_at"}), []string{"12", "14", "13", "11", "15"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parts by updated_at with limit %d = %v, want %v", limit, got, want)
+		}
+		if got, want := listAllParts(t, handler, models.ListOptions{Limit: limit, Sort: "-updated_at"}), []string{"15", "11", "13", "14", "12"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parts by -updated_at with limit %d = %v, want %v", limit, got, want)
+		}
+	}
+}
+
+func Test_SQLiteHandler_ListOptions_timestampCursor(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	testTimestampCursor(t, handler, handler.DB, sqlitePlaceholder)
+}
+
+func Test_SQLiteHandler_Recent(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019,
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20)}, {Name: "Satula", PartIdentifier: "12", Price: euros(30)}}},
+		{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "2", Year: 2004,
+			Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "21", Price: euros(12)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	handler.DB.Exec("UPDATE Vehicles SET updated_at = '2000-01-01 00:00:00' WHERE vehicle_id = '2';")
+	handler.DB.Exec("UPDATE Parts SET updated_at = '2000-01-01 00:00:00' WHERE part_id = '21';")
+	handler.DB.Exec("UPDATE Parts SET updated_at = '2000-01-02 00:00:00' WHERE part_id = '11';")
+
+	page, err := handler.GetRecentParts(models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetRecentParts() error = %v", err)
+	}
+	var ids []string
+	for _, part := range page.Parts {
+		ids = append(ids, part.Part.PartIdentifier)
+		if part.Part.CreatedAt.IsZero() || part.Vehicle.UpdatedAt.IsZero() {
+			t.Errorf("GetRecentParts() part %s has no timestamps", part.Part.PartIdentifier)
+		}
+	}
+	if want := []string{"12", "11", "21"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("GetRecentParts() = %v, want %v", ids, want)
+	}
+
+	since, _ := time.Parse(time.DateOnly, "2000-01-02")
+	page, err = handler.GetRecentParts(models.ListOptions{UpdatedSince: since})
+	if err != nil || len(page.Parts) != 2 {
+		t.Errorf("GetRecentParts() since %v = %+v, %v, want 2 parts", since, page, err)
+	}
+	vehiclePage, err := handler.GetRecentVehicles(models.ListOptions{UpdatedSince: since})
+	if err != nil || len(vehiclePage.Vehicles) != 1 || vehiclePage.Vehicles[0].Identifier != "1" {
+		t.Errorf("GetRecentVehicles() since %v = %+v, %v, want vehicle 1", since, vehiclePage, err)
+	}
+}
+
+func Test_SQLiteHandler_ModelFamilies(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Polini", Model: "XP4 50", VehicleType: "moped", Identifier: "1", Year: 2007, Url: "https://www.purkuosat.net/polinixp450.htm"},
+		{Brand: "Polini", Model: "XP4 50", VehicleType: "moped", Identifier: "2", Year: 2008, Url: "https://www.purkuosat.net/polinixp45008.htm"},
+		{Brand: "Polini", Model: "XP4-50", VehicleType: "moped", Identifier: "3", Year: 2009, Url: "https://www.purkuosat.net/polinixp4-50.htm"},
+		{Brand: "Polini", Model: "XP4 125", VehicleType: "moped", Identifier: "4", Year: 2010, Url: "https://www.purkuosat.net/polinixp4125.htm"},
+		{Brand: "Polini", Model: "RX-3", VehicleType: "moped", Identifier: "5", Year: 2011, Url: "https://www.purkuosat.net/polinirx3.htm"},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	// A vehicle stored before the model keys were derived.
+	_, err := handler.DB.Exec("INSERT INTO Vehicles (vehicle_type, brand_name, model_name, listing_url, vehicle_id, year) VALUES ('moped', 'Polini', 'RX3', 'https://www.purkuosat.net/polinirx312.htm', '6', 2012);")
+	if err != nil {
+		t.Fatalf("inserting a vehicle without a model key: %v", err)
+	}
+
+	families, err := handler.GetModelFamilies("moped", "Polini")
+	want := []models.ModelFamily{
+		{Family: "RX3", Models: []models.VehicleModel{{Key: "RX3", Name: "RX-3", Spellings: []string{"RX-3", "RX3"}}}},
+		{Family: "XP4", Models: []models.VehicleModel{
+			{Key: "XP4 50", Name: "XP4 50", Displacement: 50, Spellings: []string{"XP4 50", "XP4-50"}},
+			{Key: "XP4 125", Name: "XP4 125", Displacement: 125, Spellings: []string{"XP4 125"}},
+		}},
+	}
+	if err != nil || !reflect.DeepEqual(families, want) {
+		t.Errorf("GetModelFamilies() = %+v, %v, want %+v", families, err, want)
+	}
+	names, err := handler.GetModelsForBrand("moped", "Polini")
+	if err != nil || !reflect.DeepEqual(names, []string{"RX-3", "XP4 125", "XP4 50"}) {
+		t.Errorf("GetModelsForBrand() = %v, %v", names, err)
+	}
+
+	page, err := handler.GetVehiclesForModel("moped", "Polini", "XP4-50", models.ListOptions{})
+	if err != nil || len(page.Vehicles) != 3 {
+		t.Errorf("GetVehiclesForModel() = %+v, %v, want the 3 vehicles of any spelling", page.Vehicles, err)
+	}
+	page, err = handler.GetVehiclesForModel("moped", "Polini", "RX3", models.ListOptions{})
+	if err != nil || len(page.Vehicles) != 2 {
+		t.Errorf("GetVehiclesForModel() = %+v, %v, want the vehicle with a model key and the one without", page.Vehicles, err)
+	}
+}
+
+func Test_SQLiteHandler_NameDetails(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "KTM", Model: "EXC 125", VehicleType: "motorcycle", Identifier: "1", Year: 2004, YearFrom: 2004, YearTo: 2006,
+			Displacement: 125, Stroke: models.StrokeTwo, Trim: "Six Days", Url: "https://www.purkuosat.net/ktmexc125.htm"},
+		{Brand: "Yamaha", Model: "WR 250", VehicleType: "motorcycle", Identifier: "2", Year: 2010, Displacement: 250, Stroke: models.StrokeFour,
+			Url: "https://www.purkuosat.net/yamahawr250.htm"},
+		{Brand: "Suzuki", Model: "RX", VehicleType: "motorcycle", Identifier: "3", Year: 2005, Url: "https://www.purkuosat.net/suzukirx05.htm"},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	vehicle, err := handler.GetVehicle("motorcycle", "1")
+	if err != nil || vehicle.YearFrom != 2004 || vehicle.YearTo != 2006 || vehicle.Stroke != models.StrokeTwo || vehicle.Trim != "Six Days" {
+		t.Errorf("GetVehicle() = %+v, %v, want the parsed details", vehicle, err)
+	}
+	vehicle, err = handler.GetVehicle("motorcycle", "2")
+	if err != nil || vehicle.YearFrom != 2010 || vehicle.YearTo != 2010 {
+		t.Errorf("GetVehicle() = %+v, %v, want the year as the range", vehicle, err)
+	}
+
+	tests := []struct {
+		name    string
+		options models.ListOptions
+		want    []string
+	}{
+		{"year within range", models.ListOptions{MinYear: 2005, MaxYear: 2005}, []string{"1", "3"}},
+		{"range overlapping years", models.ListOptions{MinYear: 2006, MaxYear: 2010}, []string{"1", "2"}},
+		{"displacement", models.ListOptions{MinDisplacement: 200}, []string{"2"}},
+		{"unknown displacement left out", models.ListOptions{MaxDisplacement: 200}, []string{"1"}},
+		{"stroke", models.ListOptions{Stroke: models.StrokeFour}, []string{"2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := handler.GetVehiclesForType("motorcycle", tt.options)
+			var got []string
+			for _, vehicle := range page.Vehicles {
+				got = append(got, vehicle.Identifier)
+			}
+			sort.Strings(got)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetVehiclesForType() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SQLiteHandler_RekeyIdentifiers(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Yamaha", Model: "DT 50", VehicleType: "moped", Identifier: "1", Year: 2004, Url: "https://www.purkuosat.net/yamahadt5004.htm",
+			Parts: []models.Part{{Name: "Jarrukahva", PartIdentifier: "11", Price: euros(10)}}},
+		{Brand: "MBK", Model: "X-Limit", VehicleType: "moped", Identifier: "2", Year: 2005, Url: "https://www.purkuosat.net/mbkxlimit05.htm",
+			Parts: []models.Part{{Name: "Jarrukahva", PartIdentifier: "21", Price: euros(12)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	for _, price := range []float64{10, 8} {
+		vehicles[0].Parts[0].Price = euros(price)
+		if err := handler.InsertParts(vehicles); err != nil {
+			t.Fatalf("InsertParts() error = %v", err)
+		}
+	}
+	compatibilities := []models.Compatibility{{PartIdentifier: "21", Brand: "Yamaha", Model: "DT 50", YearFrom: 2004, YearTo: 2004, Source: "part_number"}}
+	if err := handler.InsertCompatibilities(compatibilities); err != nil {
+		t.Fatalf("InsertCompatibilities() error = %v", err)
+	}
+
+	err := handler.RekeyIdentifiers(
+		[]models.IdentifierChange{{Old: "1", New: "a1"}, {Old: "2", New: "a2"}},
+		[]models.IdentifierChange{{Old: "11", New: "a11"}, {Old: "21", New: "a21"}})
+	if err != nil {
+		t.Fatalf("RekeyIdentifiers() error = %v", err)
+	}
+
+	if _, err := handler.GetVehicle("moped", "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetVehicle() of the old identifier error = %v, want sql.ErrNoRows", err)
+	}
+	history, err := handler.GetPartPriceHistory("moped", "a1", "a11")
+	if err != nil || len(history) != 2 {
+		t.Errorf("GetPartPriceHistory() = %+v, %v, want the 2 prices", history, err)
+	}
+	parts, err := handler.GetCompatibleParts("moped", "a1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.PartIdentifier != "a21" {
+		t.Errorf("GetCompatibleParts() = %+v, %v, want part a21", parts, err)
+	}
+	hits, err := handler.Search("yamaha", 10)
+	if got := searchHitIDs(hits); err != nil || !reflect.DeepEqual(got, []string{"a1", "a11"}) {
+		t.Errorf("Search() = %v, %v, want [a1 a11]", got, err)
+	}
+}
+
+func Test_SQLiteHandler_Collisions(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Satula", PartIdentifier: "11", PartNumber: "SR-1", Price: euros(30)}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	// Another listing and a part of another vehicle with the same identifiers.
+	others := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2017, Url: "https://www.purkuosat.net/suzukirx17.htm"},
+		{Brand: "Aprilia", Model: "MX 125", VehicleType: "motorcycle", Identifier: "2", Year: 2004, Url: "https://www.purkuosat.net/apriliamx12504.htm",
+			Parts: []models.Part{{Name: "Kaasukahva", PartIdentifier: "11", Price: euros(12)}}},
+	}
+	var collisionErr *models.CollisionError
+	err := handler.InsertVehicles(others)
+	want := []models.IdentifierCollision{{Kind: models.KindVehicle, Identifier: "1", Stored: vehicles[0].Url, Scraped: others[0].Url}}
+	if !errors.As(err, &collisionErr) || !reflect.DeepEqual(collisionErr.Collisions, want) {
+		t.Errorf("InsertVehicles() error = %v, want %+v", err, want)
+	}
+	err = handler.InsertParts(others[1:])
+	want = []models.IdentifierCollision{{Kind: models.KindPart, Identifier: "11", Stored: "1", Scraped: "2"}}
+	if !errors.As(err, &collisionErr) || !reflect.DeepEqual(collisionErr.Collisions, want) {
+		t.Errorf("InsertParts() error = %v, want %+v", err, want)
+	}
+
+	vehicle, err := handler.GetVehicle("moped", "1")
+	if err != nil || vehicle.Year != 2019 {
+		t.Errorf("GetVehicle() = %+v, %v, want the stored vehicle unchanged", vehicle, err)
+	}
+	if count, _ := handler.GetVehicleCount(); count != 2 {
+		t.Errorf("GetVehicleCount() = %d, want 2", count)
+	}
+	parts, err := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.Name != "Satula" || parts.Parts[0].Part.PartNumber != "SR-1" {
+		t.Errorf("GetPartsForVehicle() = %+v, %v, want the stored part with its part number", parts, err)
+	}
+}
+
+func Test_SQLiteHandler_Images(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	photo := models.Image{Hash: strings.Repeat("ab", 32), SourceURL: "https://www.purkuosat.net/kuvat/11.jpg", Size: 2048, MIMEType: "image/jpeg", Width: 640, Height: 480, PHash: "8f3c00ff12345678"}
+	// Inserting twice must not fail.
+	for i := 0; i < 2; i++ {
+		if err := handler.InsertImages([]models.Image{photo}); err != nil {
+			t.Fatalf("InsertImages() error = %v", err)
+		}
+	}
+	if got, err := handler.GetImage(photo.Hash); err != nil || got != photo {
+		t.Errorf("GetImage() = %+v, %v, want %+v", got, err, photo)
+	}
+	if _, err := handler.GetImage(strings.Repeat("cd", 32)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetImage() of an unknown image error = %v, want sql.ErrNoRows", err)
+	}
+
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm",
+			Parts: []models.Part{{Name: "Takarengas", PartIdentifier: "11", Price: euros(20), ImgUrl: photo.SourceURL, ImgHash: photo.Hash}}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+	parts, err := handler.GetPartsForVehicle("moped", "1", models.ListOptions{})
+	if err != nil || len(parts.Parts) != 1 || parts.Parts[0].Part.ImgHash != photo.Hash || parts.Parts[0].Part.ImgThumbHash != "" {
+		t.Errorf("GetPartsForVehicle() = %+v, %v, want the part with its image hash", parts, err)
+	}
+}
+
+func Test_SQLiteHandler_GetDuplicateImages(t *testing.T) {
+	handler := newTestSQLiteHandler(t)
+	stock := models.Image{Hash: strings.Repeat("01", 32), SourceURL: "https://www.purkuosat.net/kuvat/11.jpg", Size: 2048, MIMEType: "image/jpeg", Width: 640, Height: 480, PHash: "8f3c00ff12345678"}
+	// The stock photo recompressed differs in a few bits of its perceptual hash.
+	recompressed := models.Image{Hash: strings.Repeat("02", 32), SourceURL: "https://www.purkuosat.net/kuvat/21.jpg", Size: 1024, MIMEType: "image/jpeg", Width: 320, Height: 240, PHash: "8f3c00ff12345679"}
+	unique := models.Image{Hash: strings.Repeat("03", 32), SourceURL: "https://www.purkuosat.net/kuvat/12.jpg", Size: 4096, MIMEType: "image/jpeg", Width: 640, Height: 480, PHash: "70c3ff00edcba987"}
+	undecoded := models.Image{Hash: strings.Repeat("04", 32), SourceURL: "https://www.purkuosat.net/kuvat/13.bmp", Size: 512, MIMEType: "image/bmp"}
+	if err := handler.InsertImages([]models.Image{stock, recompressed, unique, undecoded}); err != nil {
+		t.Fatalf("InsertImages() error = %v", err)
+	}
+	vehicles := []models.Vehicle{
+		{Brand: "Suzuki", Model: "RX", VehicleType: "moped", Identifier: "1", Year: 2019, Url: "https://www.purkuosat.net/suzukirx19.htm", Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "11", Price: euros(20), ImgHash: stock.Hash},
+			{Name: "Eturengas", PartIdentifier: "12", Price: euros(20), ImgHash: unique.Hash},
+			{Name: "Vanne", PartIdentifier: "13", Price: euros(30), ImgHash: undecoded.Hash},
+		}},
+		{Brand: "Honda", Model: "MB", VehicleType: "moped", Identifier: "2", Year: 1982, Url: "https://www.purkuosat.net/hondamb82.htm", Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "21", Price: euros(25), ImgHash: recompressed.Hash},
+			{Name: "Vanne", PartIdentifier: "22", Price: euros(30), ImgHash: undecoded.Hash},
+		}},
+		{Brand: "Honda", Model: "MT", VehicleType: "moped", Identifier: "3", Year: 1984, Url: "https://www.purkuosat.net/hondamt84.htm", Parts: []models.Part{
+			{Name: "Takarengas", PartIdentifier: "31", Price: euros(25), ImgHash: stock.Hash},
+		}},
+	}
+	if err := handler.InsertVehicles(vehicles); err != nil {
+		t.Fatalf("InsertVehicles() error = %v", err)
+	}
+	if err := handler.InsertParts(vehicles); err != nil {
+		t.Fatalf("InsertParts() error = %v", err)
+	}
+
+	// groupIDs returns the image hashes and part identifiers of the groups.
+	groupIDs := func(groups []models.DuplicateImageGroup) [][]string {
+		ids := [][]string{}
+		for _, group := range groups {
+			var groupIDs []string
+			for _, image := range group.Images {
+				groupIDs = append(groupIDs, image.Hash[:2])
+			}
+			for _, part := range group.Parts {
+				groupIDs = append(groupIDs, part.Part.PartIdentifier)
+			}
+			ids = append(ids, groupIDs)
+		}
+		return ids
+	}
+	tests := []struct {
+		name        string
+		maxDistance int
+		want        [][]string
+	}{
+		{"Exact", 0, [][]string{{"01", "11", "31"}, {"04", "13", "22"}}},
+		{"Similar", 2, [][]string{{"01", "02", "11", "21", "31"}, {"04", "13", "22"}}},
+		{"Everything similar", 64, [][]string{{"01", "02", "03", "11", "12", "21", "31"}, {"04", "13", "22"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.GetDuplicateImages(tt.maxDistance)
+			if err != nil {
+				t.Fatalf("GetDuplicateImages() error = %v", err)
+			}
+			if !reflect.DeepEqual(groupIDs(got), tt.want) {
+				t.Errorf("GetDuplicateImages() = %v, want %v", groupIDs(got), tt.want)
+			}
+		})
+	}
+
+	// Deleted parts do not share their photos anymore.
+	if err := handler.DeleteParts([]string{"31"}); err != nil {
+		t.Fatalf("DeleteParts() error = %v", err)
+	}
+	got, err := handler.GetDuplicateImages(0)
+	if want := [][]string{{"04", "13", "22"}}; err != nil || !reflect.DeepEqual(groupIDs(got), want) {
+		t.Errorf("GetDuplicateImages() after deleting = %v, %v, want %v", groupIDs(got), err, want)
+	}
+}
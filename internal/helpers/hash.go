@@ -0,0 +1,18 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateHash
+// generates a 128-bit hashed identifier in hex from one or many string values.
+func GenerateHash(hashableVals ...string) string {
+	h := sha256.New()
+	for _, a := range hashableVals {
+		h.Write([]byte(a))
+		// Separate the values, so that "ab", "c" and "a", "bc" hash differently.
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,23 @@
+package helpers
+
+import "testing"
+
+func Test_GenerateHash(t *testing.T) {
+	type args struct {
+		vals []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Test normal link", args{vals: []string{"https://www.purkuosat.net/apriliamx12504.htm", "Aprilia MX 125 2004"}}, "1795aed0ec8e549b44ad5a4da75da094"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateHash(tt.args.vals...); got != tt.want {
+				t.Errorf("GenerateHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
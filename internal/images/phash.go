@@ -0,0 +1,88 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// Sizes of the perceptual hash. The image is scaled to hashSampleSize pixels
+// square and the hashSize x hashSize lowest frequencies of its DCT are hashed.
+const (
+	hashSampleSize = 32
+	hashSize       = 8
+)
+
+// PerceptualHash returns the 64-bit perceptual hash of an image as hex. Each
+// bit tells whether a low frequency of the grayscale image is above the
+// median, so the hashes of a photo that was resized, recompressed or slightly
+// retouched are only a few bits apart.
+func PerceptualHash(img image.Image) string {
+	sample := image.NewGray(image.Rect(0, 0, hashSampleSize, hashSampleSize))
+	draw.ApproxBiLinear.Scale(sample, sample.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var pixels [hashSampleSize][hashSampleSize]float64
+	for y := 0; y < hashSampleSize; y++ {
+		for x := 0; x < hashSampleSize; x++ {
+			pixels[y][x] = float64(sample.GrayAt(x, y).Y)
+		}
+	}
+
+	frequencies := make([]float64, 0, hashSize*hashSize)
+	for v := 0; v < hashSize; v++ {
+		for u := 0; u < hashSize; u++ {
+			frequencies = append(frequencies, dct(&pixels, u, v))
+		}
+	}
+	// The first frequency is the average brightness, it is left out of the median.
+	sorted := append([]float64(nil), frequencies[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	var hash uint64
+	for i, frequency := range frequencies {
+		if frequency > median {
+			hash |= 1 << (63 - i)
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// dct returns the coefficient of the frequency u, v of the two dimensional
+// discrete cosine transform of the pixels.
+func dct(pixels *[hashSampleSize][hashSampleSize]float64, u int, v int) float64 {
+	var sum float64
+	for y := 0; y < hashSampleSize; y++ {
+		for x := 0; x < hashSampleSize; x++ {
+			sum += pixels[y][x] *
+				math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*hashSampleSize)) *
+				math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*hashSampleSize))
+		}
+	}
+	return sum
+}
+
+// ParseHash returns the bits of a perceptual hash returned by PerceptualHash.
+func ParseHash(hash string) (uint64, error) {
+	value, err := strconv.ParseUint(hash, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash %q", hash)
+	}
+	return value, nil
+}
+
+// HashDistance returns the number of bits the perceptual hashes differ in.
+func HashDistance(a string, b string) (int, error) {
+	x, err := ParseHash(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := ParseHash(b)
+	if err != nil {
+		return 0, err
+	}
+	return bits.OnesCount64(x ^ y), nil
+}
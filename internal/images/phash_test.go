@@ -0,0 +1,80 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// testPhoto returns a width x height image with a diagonal gradient and a
+// dark square, scaled from the same drawing whatever the size.
+func testPhoto(width int, height int, inverted bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := uint8(255 * (x*height + y*width) / (2 * width * height))
+			if x > width/4 && x < width/2 && y > height/2 && y < 3*height/4 {
+				value /= 4
+			}
+			if inverted {
+				value = 255 - value
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+func Test_PerceptualHash(t *testing.T) {
+	photo := PerceptualHash(testPhoto(320, 240, false))
+	if len(photo) != 16 {
+		t.Fatalf("PerceptualHash() = %q, want 16 hex digits", photo)
+	}
+	resized := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	draw.BiLinear.Scale(resized, resized.Bounds(), testPhoto(320, 240, false), image.Rect(0, 0, 320, 240), draw.Src, nil)
+
+	tests := []struct {
+		name         string
+		img          image.Image
+		wantDistance func(distance int) bool
+	}{
+		{"Same photo", testPhoto(320, 240, false), func(distance int) bool { return distance == 0 }},
+		{"Resized photo", resized, func(distance int) bool { return distance <= 4 }},
+		{"Different photo", testPhoto(320, 240, true), func(distance int) bool { return distance >= 16 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PerceptualHash(tt.img)
+			distance, err := HashDistance(photo, got)
+			if err != nil || !tt.wantDistance(distance) {
+				t.Errorf("HashDistance(%s, %s) = %d, %v", photo, got, distance, err)
+			}
+		})
+	}
+}
+
+func Test_HashDistance(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		want    int
+		wantErr bool
+	}{
+		{"Equal", "8f3c00ff12345678", "8f3c00ff12345678", 0, false},
+		{"One bit", "0000000000000000", "0000000000000001", 1, false},
+		{"All bits", "0000000000000000", "ffffffffffffffff", 64, false},
+		{"Invalid", "0000000000000000", "not a hash", 0, true},
+		{"Empty", "", "0000000000000000", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HashDistance(tt.a, tt.b)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("HashDistance() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
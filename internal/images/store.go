@@ -0,0 +1,151 @@
+// Package images keeps the part photos mirrored by the crawler in a content
+// addressed directory, so that they can be served after they disappear from
+// the site.
+package images
+
+import (
+	"Crawler/internal/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Decoders of the image formats that are described and thumbnailed.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	// ErrNotImage is returned when storing content that is not an image.
+	ErrNotImage = errors.New("content is not an image")
+	// ErrNotStored is returned when opening an image that is not in the store.
+	ErrNotStored = errors.New("image is not stored")
+)
+
+// Store is a directory holding one file per image. The file is named by the
+// sha256 of its content and sharded into subdirectories by the first two
+// characters of the hash, so an image is stored once however many parts and
+// URLs share it.
+type Store struct {
+	dir string
+}
+
+// Open opens the store in dir, creating the directory when needed.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("image directory is not configured")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// ValidHash tells whether the hash is a hex encoded sha256, so that it can
+// safely be used as a file name.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 || strings.ToLower(hash) != hash {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Describe returns the metadata of the content of an image downloaded from
+// sourceURL. The dimensions and the perceptual hash are left empty for
+// formats that cannot be decoded.
+func Describe(content []byte, sourceURL string) (models.Image, error) {
+	mimeType := http.DetectContentType(content)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return models.Image{}, fmt.Errorf("%w: %s is %s", ErrNotImage, sourceURL, mimeType)
+	}
+	sum := sha256.Sum256(content)
+	described := models.Image{Hash: hex.EncodeToString(sum[:]), SourceURL: sourceURL, Size: int64(len(content)), MIMEType: mimeType}
+	if decoded, _, err := image.Decode(bytes.NewReader(content)); err == nil {
+		described.Width, described.Height = decoded.Bounds().Dx(), decoded.Bounds().Dy()
+		described.PHash = PerceptualHash(decoded)
+	}
+	return described, nil
+}
+
+// Put stores the content of an image downloaded from sourceURL and returns
+// its metadata. Content that is already stored is not written again. The
+// file is written atomically, so that a crawl never leaves a partly written
+// image behind.
+func (s *Store) Put(content []byte, sourceURL string) (models.Image, error) {
+	described, err := Describe(content, sourceURL)
+	if err != nil {
+		return models.Image{}, err
+	}
+	if s.Has(described.Hash) {
+		return described, nil
+	}
+	path := s.path(described.Hash)
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return models.Image{}, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".image-*")
+	if err != nil {
+		return models.Image{}, err
+	}
+	if _, err = file.Write(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return models.Image{}, err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return models.Image{}, err
+	}
+	return described, os.Rename(file.Name(), path)
+}
+
+// PutThumbnail stores a size x size thumbnail of the content of an image and
+// returns the metadata of the thumbnail. sourceURL is where the thumbnail is
+// served from when it is missing from the store.
+func (s *Store) PutThumbnail(content []byte, size int, sourceURL string) (models.Image, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return models.Image{}, fmt.Errorf("%w: %s cannot be decoded: %v", ErrNotImage, sourceURL, err)
+	}
+	thumbnail, err := Thumbnail(decoded, size)
+	if err != nil {
+		return models.Image{}, err
+	}
+	return s.Put(thumbnail, sourceURL)
+}
+
+// Has tells whether the image of the hash is stored.
+func (s *Store) Has(hash string) bool {
+	if !ValidHash(hash) {
+		return false
+	}
+	_, err := os.Stat(s.path(hash))
+	return err == nil
+}
+
+// Open opens the stored image of the hash for reading.
+func (s *Store) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, fmt.Errorf("%w: %q", ErrNotStored, hash)
+	}
+	file, err := os.Open(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotStored, hash)
+	}
+	return file, err
+}
@@ -0,0 +1,76 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// testPNG returns the content of a blank PNG image of the size.
+func testPNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func Test_Store(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content := testPNG(t, 4, 3)
+	stored, err := store.Put(content, "https://www.purkuosat.net/kuvat/2001.jpg")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if !ValidHash(stored.Hash) || stored.Size != int64(len(content)) || stored.MIMEType != "image/png" || stored.Width != 4 || stored.Height != 3 {
+		t.Errorf("Put() = %+v, want a 4x3 PNG of %d bytes", stored, len(content))
+	}
+	// The same content from another URL is the same image.
+	again, err := store.Put(content, "https://www.purkuosat.net/kuvat/2002.jpg")
+	if err != nil || again.Hash != stored.Hash {
+		t.Errorf("Put() of the same content = %+v, %v, want hash %s", again, err, stored.Hash)
+	}
+
+	file, err := store.Open(stored.Hash)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer file.Close()
+	if got, _ := io.ReadAll(file); !bytes.Equal(got, content) {
+		t.Errorf("Open() content differs from the stored one")
+	}
+	missing := "0000000000000000000000000000000000000000000000000000000000000000"
+	if _, err := store.Open(missing); !errors.Is(err, ErrNotStored) || store.Has(missing) {
+		t.Errorf("Open() of a missing image error = %v, want ErrNotStored", err)
+	}
+	if _, err := store.Put([]byte("<html>Sivua ei löydy</html>"), "https://www.purkuosat.net/kuvat/404.jpg"); !errors.Is(err, ErrNotImage) {
+		t.Errorf("Put() of a HTML page error = %v, want ErrNotImage", err)
+	}
+}
+
+func Test_ValidHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"Test sha256", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", true},
+		{"Test upper case", "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08", false},
+		{"Test too short", "9f86d081", false},
+		{"Test path", "../../../../../../../../../../../../../../../../../../etc/passwd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidHash(tt.hash); got != tt.want {
+				t.Errorf("ValidHash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbnailQuality is the JPEG quality of the thumbnails.
+const thumbnailQuality = 85
+
+// Thumbnail returns a size x size JPEG of the image. The image is scaled to
+// fit and centered on a white background, so that thumbnails of photos of
+// any shape line up and transparent images stay readable.
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail size %d", size)
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("%w: the image is empty", ErrNotImage)
+	}
+	width, height := size, size
+	if bounds.Dx() > bounds.Dy() {
+		height = max(1, size*bounds.Dy()/bounds.Dx())
+	} else {
+		width = max(1, size*bounds.Dx()/bounds.Dy())
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	target := image.Rect((size-width)/2, (size-height)/2, (size-width)/2+width, (size-height)/2+height)
+	draw.CatmullRom.Scale(thumbnail, target, img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func Test_Thumbnail(t *testing.T) {
+	tests := []struct {
+		name    string
+		img     image.Image
+		size    int
+		wantErr bool
+	}{
+		{"Wide photo", testPhoto(320, 240, false), 100, false},
+		{"Tall photo", testPhoto(24, 480, false), 100, false},
+		{"Smaller than the thumbnail", testPhoto(4, 3, false), 100, false},
+		{"Invalid size", testPhoto(320, 240, false), 0, true},
+		{"Empty image", image.NewGray(image.Rect(0, 0, 0, 0)), 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Thumbnail(tt.img, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Thumbnail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			decoded, err := jpeg.Decode(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("jpeg.Decode() error = %v", err)
+			}
+			if decoded.Bounds() != image.Rect(0, 0, tt.size, tt.size) {
+				t.Errorf("Thumbnail() bounds = %v, want %dx%[2]d", decoded.Bounds(), tt.size)
+			}
+		})
+	}
+}
+
+func Test_Store_PutThumbnail(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	thumbnail, err := store.PutThumbnail(testPNG(t, 40, 20), 16, "https://www.purkuosat.net/kuvat/2001_t.jpg")
+	if err != nil {
+		t.Fatalf("PutThumbnail() error = %v", err)
+	}
+	if !store.Has(thumbnail.Hash) || thumbnail.MIMEType != "image/jpeg" || thumbnail.Width != 16 || thumbnail.Height != 16 || thumbnail.PHash == "" {
+		t.Errorf("PutThumbnail() = %+v, want a stored 16x16 JPEG with a perceptual hash", thumbnail)
+	}
+	if _, err := store.PutThumbnail([]byte("GIF89a"), 16, "https://www.purkuosat.net/kuvat/2002_t.gif"); err == nil {
+		t.Errorf("PutThumbnail() of a broken image error = nil, want an error")
+	}
+}
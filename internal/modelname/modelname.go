@@ -0,0 +1,109 @@
+// Package modelname normalizes the model names scraped from vehicle listings,
+// so that spelling variants such as "RX-3", "RX 3" and "RX3" are one model and
+// the models that differ only by displacement form a family.
+package modelname
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Displacements outside this range in cubic centimetres are model numbers.
+const (
+	minDisplacement = 49
+	maxDisplacement = 2500
+)
+
+// Name is the normalized form of a model name.
+type Name struct {
+	// Key identifies the model across spelling variants, "XP4 50" for "XP4-50".
+	Key string
+	// Family is the model without its displacement, "XP4" for "XP4 50".
+	Family string
+	// Displacement is in cubic centimetres, 0 when the name does not tell it.
+	Displacement int
+}
+
+// Normalize derives the key, family and displacement of a model name. The name
+// is split into runs of letters and runs of digits, ignoring case and
+// punctuation. The first run of digits that is a plausible displacement, and
+// is not the whole name, separates the family from the rest of the name.
+func Normalize(model string) Name {
+	tokens := tokenize(model)
+	for i, token := range tokens {
+		displacement, isDisplacement := parseDisplacement(token)
+		if !isDisplacement || len(tokens) == 1 {
+			continue
+		}
+		rest := tokens[i+1:]
+		// "50cc" tells the unit of the displacement.
+		if len(rest) > 0 && (rest[0] == "CC" || rest[0] == "CCM") {
+			rest = rest[1:]
+		}
+		prefix, suffix := strings.Join(tokens[:i], ""), strings.Join(rest, "")
+		name := Name{Family: prefix, Displacement: displacement}
+		if name.Family == "" {
+			name.Family = suffix
+		}
+		name.Key = strings.Join(nonEmpty(prefix, token, suffix), " ")
+		return name
+	}
+	key := strings.Join(tokens, "")
+	return Name{Key: key, Family: key}
+}
+
+// tokenize splits the upper cased name into runs of letters and runs of digits.
+func tokenize(model string) []string {
+	var tokens []string
+	var current strings.Builder
+	var currentDigits bool
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range model {
+		switch {
+		case unicode.IsDigit(r):
+			if !currentDigits {
+				flush()
+			}
+			currentDigits = true
+			current.WriteRune(r)
+		case unicode.IsLetter(r):
+			if currentDigits {
+				flush()
+			}
+			currentDigits = false
+			current.WriteRune(unicode.ToUpper(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// parseDisplacement tells whether the token is a plausible displacement.
+func parseDisplacement(token string) (int, bool) {
+	if token[0] == '0' {
+		return 0, false
+	}
+	value, err := strconv.Atoi(token)
+	if err != nil || value < minDisplacement || value > maxDisplacement {
+		return 0, false
+	}
+	return value, true
+}
+
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
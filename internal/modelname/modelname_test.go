@@ -0,0 +1,32 @@
+package modelname
+
+import "testing"
+
+func Test_Normalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  Name
+	}{
+		{"dashed model number", "RX-3", Name{Key: "RX3", Family: "RX3"}},
+		{"spaced model number", "RX 3", Name{Key: "RX3", Family: "RX3"}},
+		{"joined model number", "rx3", Name{Key: "RX3", Family: "RX3"}},
+		{"displacement", "XP4 50", Name{Key: "XP4 50", Family: "XP4", Displacement: 50}},
+		{"dashed displacement", "XP4-50", Name{Key: "XP4 50", Family: "XP4", Displacement: 50}},
+		{"joined displacement", "MX125", Name{Key: "MX 125", Family: "MX", Displacement: 125}},
+		{"displacement with unit", "MX 125cc", Name{Key: "MX 125", Family: "MX", Displacement: 125}},
+		{"displacement with suffix", "CBR 600 RR", Name{Key: "CBR 600 RR", Family: "CBR", Displacement: 600}},
+		{"displacement first", "125 Duke", Name{Key: "125 DUKE", Family: "DUKE", Displacement: 125}},
+		{"only a number", "600", Name{Key: "600", Family: "600"}},
+		{"no displacement", "V7 Stone", Name{Key: "V7STONE", Family: "V7STONE"}},
+		{"leading zero", "Senda 050", Name{Key: "SENDA050", Family: "SENDA050"}},
+		{"empty", "", Name{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.model); got != tt.want {
+				t.Errorf("Normalize(%q) = %+v, want %+v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+// Compatibility states that a part fits the vehicles of a brand and model
+// made within a range of years.
+type Compatibility struct {
+	PartIdentifier string `json:"part_id"`
+	Brand          string `json:"brand"`
+	Model          string `json:"model"`
+	YearFrom       int    `json:"year_from"`
+	YearTo         int    `json:"year_to"`
+	// Source tells what the compatibility was derived from, see the compatibility package.
+	Source string `json:"source"`
+}
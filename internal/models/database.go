@@ -2,15 +2,55 @@ package models
 
 // DatabaseHandler defines the methods for interacting with the database
 type DatabaseHandler interface {
+	// InsertVehicles adds new vehicles and updates the stored ones that changed.
+	// Vehicles whose identifier belongs to a vehicle of another listing are
+	// skipped and reported with a *CollisionError.
 	InsertVehicles(vehicles []Vehicle) error
+	// InsertParts adds new parts and updates the stored ones that changed.
+	// Parts whose identifier belongs to a part of another vehicle are skipped
+	// and reported with a *CollisionError.
 	InsertParts(vehicles []Vehicle) error
+	// RekeyIdentifiers renames stored vehicles and parts along with everything
+	// referring to them. Changes of identifiers that are not stored are ignored.
+	RekeyIdentifiers(vehicles []IdentifierChange, parts []IdentifierChange) error
+	// DeleteVehicles marks the vehicles and their parts as deleted.
+	DeleteVehicles(vehicleIdentifiers []string) error
+	// DeleteParts marks the parts as deleted.
+	DeleteParts(partIdentifiers []string) error
 	GetVehicleCount() (int, error)
 	GetVehicleTypes() ([]string, error)
-	GetVehiclesForType(vehicleType string) ([]Vehicle, error)
+	GetVehiclesForType(vehicleType string, options ListOptions) (VehiclePage, error)
 	GetBrands(vehicleType string) ([]string, error)
+	// GetModelsForBrand returns the names of the models of a brand, one name
+	// for the spelling variants of a model.
 	GetModelsForBrand(vehicleType string, brandName string) ([]string, error)
-	GetVehiclesForModel(vehicleType string, brandName string, modelName string) ([]Vehicle, error)
+	// GetModelFamilies returns the models of a brand grouped by family.
+	GetModelFamilies(vehicleType string, brandName string) ([]ModelFamily, error)
+	// GetVehiclesForModel and GetPartsForModel match the model by its key, so
+	// any spelling of the model name finds every vehicle of the model.
+	GetVehiclesForModel(vehicleType string, brandName string, modelName string, options ListOptions) (VehiclePage, error)
 	GetVehicle(vehicleType string, vehicleIdentifier string) (Vehicle, error)
-	GetPartsForVehicle(vehicleIdentifier string) (Vehicle, error)
-	GetPartsForModel(vehicleType string, brandName string, modelName string) ([]Vehicle, error)
+	// GetPartsForVehicle returns sql.ErrNoRows when the vehicle of the type does not exist.
+	GetPartsForVehicle(vehicleType string, vehicleIdentifier string, options ListOptions) (PartPage, error)
+	GetPartsForModel(vehicleType string, brandName string, modelName string, options ListOptions) (PartPage, error)
+	// GetRecentVehicles returns the vehicles of every type, last updated first.
+	GetRecentVehicles(options ListOptions) (VehiclePage, error)
+	// GetRecentParts returns the parts of every vehicle, last updated first.
+	GetRecentParts(options ListOptions) (PartPage, error)
+	// InsertCompatibilities adds part compatibilities that are not stored yet.
+	InsertCompatibilities(compatibilities []Compatibility) error
+	// GetCompatibleParts returns the parts of other vehicles that fit the vehicle.
+	GetCompatibleParts(vehicleType string, vehicleIdentifier string, options ListOptions) (PartPage, error)
+	// GetPartPriceHistory returns the observed prices of a part, oldest first.
+	GetPartPriceHistory(vehicleType string, vehicleIdentifier string, partIdentifier string) ([]PricePoint, error)
+	// InsertImages adds the metadata of mirrored images that are not stored yet.
+	InsertImages(images []Image) error
+	// GetImage returns the metadata of a mirrored image by its hash.
+	GetImage(hash string) (Image, error)
+	// GetDuplicateImages returns the groups of listed part photos whose perceptual
+	// hashes are at most maxDistance bits apart and that are shared by vehicles.
+	GetDuplicateImages(maxDistance int) ([]DuplicateImageGroup, error)
+	// Search returns at most limit vehicles and parts matching the query, best match first.
+	Search(query string, limit int) ([]SearchHit, error)
+	Close() error
 }
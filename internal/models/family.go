@@ -0,0 +1,17 @@
+package models
+
+// ModelFamily groups the models of a brand that differ only by displacement,
+// such as XP4 50 and XP4 125 of family XP4.
+type ModelFamily struct {
+	Family string         `json:"family"`
+	Models []VehicleModel `json:"models"`
+}
+
+// VehicleModel is a model of a brand with the spellings of its name found in
+// the listings. Name is the most common spelling.
+type VehicleModel struct {
+	Key          string   `json:"key"`
+	Name         string   `json:"name"`
+	Displacement int      `json:"displacement"`
+	Spellings    []string `json:"spellings"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of records with identifiers.
+const (
+	KindVehicle = "vehicle"
+	KindPart    = "part"
+)
+
+// IdentifierChange re-keys a vehicle or part stored under an identifier of an
+// older scheme.
+type IdentifierChange struct {
+	Old string
+	New string
+}
+
+// IdentifierCollision is a record that was not stored because another record
+// already has its identifier. Stored and Scraped tell the records apart: the
+// listing URL of a vehicle or the vehicle of a part.
+type IdentifierCollision struct {
+	Kind       string `json:"kind"`
+	Identifier string `json:"id"`
+	Stored     string `json:"stored"`
+	Scraped    string `json:"scraped"`
+}
+
+// CollisionError is returned by inserts that skipped records because of
+// identifier collisions. The other records are stored.
+type CollisionError struct {
+	Collisions []IdentifierCollision
+}
+
+func (e *CollisionError) Error() string {
+	descriptions := make([]string, len(e.Collisions))
+	for i, collision := range e.Collisions {
+		descriptions[i] = fmt.Sprintf("%s %s of %s is taken by %s", collision.Kind, collision.Identifier, collision.Scraped, collision.Stored)
+	}
+	return fmt.Sprintf("%d identifier collisions: %s", len(e.Collisions), strings.Join(descriptions, "; "))
+}
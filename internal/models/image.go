@@ -0,0 +1,23 @@
+package models
+
+// Image is a part photo mirrored into the local image store.
+type Image struct {
+	// Hash is the hex encoded sha256 of the content of the image.
+	Hash string `json:"hash"`
+	// SourceURL is the address the image was first downloaded from.
+	SourceURL string `json:"source_url"`
+	Size      int64  `json:"size"`
+	MIMEType  string `json:"mime_type"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	// PHash is the perceptual hash of the image in hex, see images.PerceptualHash.
+	// It is empty when the image cannot be decoded.
+	PHash string `json:"phash"`
+}
+
+// DuplicateImageGroup is a set of similar part photos, such as a stock photo,
+// and the parts of the different vehicles that use them.
+type DuplicateImageGroup struct {
+	Images []Image          `json:"images"`
+	Parts  []VehicleAndPart `json:"parts"`
+}
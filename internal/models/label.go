@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// isWithin tells whether t is at most window before now.
+func isWithin(t time.Time, now time.Time, window time.Duration) bool {
+	return !t.IsZero() && now.Sub(t) <= window
+}
+
+// Label marks the vehicle new when it was created within the window before
+// now, and otherwise recently updated when it or its parts changed within the
+// window. The parts of the vehicle are labelled too.
+func (vehicle *Vehicle) Label(now time.Time, window time.Duration) {
+	vehicle.IsNew = isWithin(vehicle.CreatedAt, now, window)
+	vehicle.RecentlyUpdated = !vehicle.IsNew && isWithin(vehicle.UpdatedAt, now, window)
+	for i := range vehicle.Parts {
+		vehicle.Parts[i].Label(now, window)
+	}
+}
+
+// Label marks the part new when it was created within the window before now,
+// and otherwise recently updated when it changed within the window.
+func (part *Part) Label(now time.Time, window time.Duration) {
+	part.IsNew = isWithin(part.CreatedAt, now, window)
+	part.RecentlyUpdated = !part.IsNew && isWithin(part.UpdatedAt, now, window)
+}
+
+// Scraped returns the vehicle and its parts without the timestamps and labels,
+// which are not scraped from the listing.
+func (vehicle Vehicle) Scraped() Vehicle {
+	vehicle.CreatedAt, vehicle.UpdatedAt = time.Time{}, time.Time{}
+	vehicle.IsNew, vehicle.RecentlyUpdated = false, false
+	if vehicle.Parts != nil {
+		parts := make([]Part, len(vehicle.Parts))
+		for i, part := range vehicle.Parts {
+			parts[i] = part.Scraped()
+		}
+		vehicle.Parts = parts
+	}
+	return vehicle
+}
+
+// Scraped returns the part without the timestamps and labels, which are not
+// scraped from the listing.
+func (part Part) Scraped() Part {
+	part.CreatedAt, part.UpdatedAt = time.Time{}, time.Time{}
+	part.IsNew, part.RecentlyUpdated = false, false
+	return part
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidListOptions is returned for list options that do not apply to a list,
+// such as an unknown sort key or a cursor of another query.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// Sort keys of vehicle and part lists. Price and name only apply to parts.
+const (
+	SortYear      = "year"
+	SortBrand     = "brand"
+	SortPrice     = "price"
+	SortName      = "name"
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+)
+
+// ListOptions filters, orders and pages a list of vehicles or parts.
+type ListOptions struct {
+	// Limit is the maximum number of items of a page, 0 lists every item.
+	Limit int
+	// Cursor continues a list after the page that returned it.
+	Cursor string
+	// Sort is a sort key, prefixed with "-" for descending order. Empty keeps
+	// the default order of the list.
+	Sort string
+	// UpdatedSince limits the items to the ones updated at or after it when it is set.
+	UpdatedSince time.Time
+	// MinYear and MaxYear limit the vehicle years when they are not 0. A
+	// vehicle of a year range is kept if any of its years is within them.
+	MinYear int
+	MaxYear int
+	// MinDisplacement and MaxDisplacement limit the displacements when they
+	// are not 0. Vehicles of an unknown displacement are left out.
+	MinDisplacement int
+	MaxDisplacement int
+	// Stroke limits the vehicles to the stroke when it is set.
+	Stroke string
+	// MinPrice and MaxPrice limit the part prices when they are set. They are
+	// in the minor units of the currencies of the prices, such as cents.
+	MinPrice *int64
+	MaxPrice *int64
+	// Currency limits the parts to the prices in the currency when it is set.
+	// The price filters and the price sort only compare the amounts of one
+	// currency, DefaultCurrency when Currency is not set.
+	Currency string
+}
+
+// VehiclePage is a page of a vehicle list.
+type VehiclePage struct {
+	Vehicles []Vehicle
+	// NextCursor continues the list, it is empty on the last page.
+	NextCursor string
+}
+
+// PartPage is a page of a part list.
+type PartPage struct {
+	Parts []VehicleAndPart
+	// NextCursor continues the list, it is empty on the last page.
+	NextCursor string
+}
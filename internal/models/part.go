@@ -1,17 +1,31 @@
 package models
 
+import "time"
+
 type VehicleAndPart struct {
 	Part    Part    `json:"part"`
 	Vehicle Vehicle `json:"vehicle"`
 }
 
 type Part struct {
-	Name           string  `json:"name"`
-	Description    string  `json:"description"`
-	PartIdentifier string  `json:"id"`
-	Price          float64 `json:"price"`
-	ImgUrl         string  `json:"img_url"`
-	ImgThumbUrl    string  `json:"img_thumb_url"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	PartIdentifier string `json:"id"`
+	// PartNumber is the identifier of the part on the site.
+	PartNumber  string `json:"part_number"`
+	Price       Price  `json:"price"`
+	ImgUrl      string `json:"img_url"`
+	ImgThumbUrl string `json:"img_thumb_url"`
+	// ImgHash and ImgThumbHash are the hashes of the images mirrored from
+	// ImgUrl and ImgThumbUrl, empty when they are not mirrored.
+	ImgHash      string `json:"img_hash"`
+	ImgThumbHash string `json:"img_thumb_hash"`
+	// CreatedAt and UpdatedAt are maintained by the database.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// IsNew and RecentlyUpdated are set by Label.
+	IsNew           bool `json:"is_new"`
+	RecentlyUpdated bool `json:"recently_updated"`
 }
 
 type RawPart struct {
@@ -22,3 +36,9 @@ type RawPart struct {
 	ImgUrl         string
 	ImgThumbUrl    string
 }
+
+// PricePoint is the price of a part observed at a point in time.
+type PricePoint struct {
+	Price      Price     `json:"price"`
+	ObservedAt time.Time `json:"observed_at"`
+}
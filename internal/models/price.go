@@ -0,0 +1,133 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of prices stored before prices had a
+// currency, and of the price filters of the API.
+const DefaultCurrency = "EUR"
+
+// Price is an amount of money in the minor units of its currency, such as
+// cents, so that prices compare and add up exactly.
+type Price struct {
+	// Amount is in the minor units of Currency.
+	Amount int64
+	// Currency is the ISO 4217 code of the currency, empty for a part
+	// without a price.
+	Currency string
+	// VATIncluded tells whether the amount includes value added tax.
+	VATIncluded bool
+}
+
+// minorDigits are the numbers of decimals of the currencies whose minor unit
+// is not a hundredth.
+var minorDigits = map[string]int{
+	"BHD": 3, "CLP": 0, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "OMR": 3, "TND": 3, "VND": 0,
+}
+
+// currencySymbols are the symbols prices are formatted with, other currencies
+// are formatted with their code.
+var currencySymbols = map[string]string{
+	"EUR": "€", "USD": "$", "GBP": "£", "SEK": "kr", "NOK": "kr", "DKK": "kr",
+}
+
+// MinorDigits returns the number of decimals of the currency.
+func MinorDigits(currency string) int {
+	if digits, exists := minorDigits[currency]; exists {
+		return digits
+	}
+	return 2
+}
+
+// MinorUnits converts an amount in the major units of the currency, such as
+// euros, to its minor units, rounding to the nearest minor unit.
+func MinorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(MinorDigits(currency))))
+}
+
+// NewPrice returns the price of an amount in the major units of the currency.
+func NewPrice(amount float64, currency string, vatIncluded bool) Price {
+	return Price{Amount: MinorUnits(amount, currency), Currency: currency, VATIncluded: vatIncluded}
+}
+
+// Major returns the amount in the major units of the currency, such as euros.
+func (p Price) Major() float64 {
+	return float64(p.Amount) / math.Pow10(MinorDigits(p.Currency))
+}
+
+// String formats the price the Finnish way, with a comma before the decimals
+// and the thousands and the currency separated by no-break spaces, such as
+// "1 234,50 €". A price without a currency formats as an empty string.
+func (p Price) String() string {
+	if p.Currency == "" {
+		return ""
+	}
+	amount, sign := p.Amount, ""
+	if amount < 0 {
+		amount, sign = -amount, "-"
+	}
+	digits := MinorDigits(p.Currency)
+	text := strconv.FormatInt(amount, 10)
+	if len(text) <= digits {
+		text = strings.Repeat("0", digits-len(text)+1) + text
+	}
+	whole, decimals := text[:len(text)-digits], text[len(text)-digits:]
+	var formatted strings.Builder
+	formatted.WriteString(sign)
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			formatted.WriteString("\u00a0")
+		}
+		formatted.WriteRune(digit)
+	}
+	if digits > 0 {
+		formatted.WriteString("," + decimals)
+	}
+	symbol, exists := currencySymbols[p.Currency]
+	if !exists {
+		symbol = p.Currency
+	}
+	formatted.WriteString("\u00a0" + symbol)
+	return formatted.String()
+}
+
+// priceJSON is the JSON form of a price. Formatted is only written.
+type priceJSON struct {
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	VATIncluded bool   `json:"vat_included"`
+	Formatted   string `json:"formatted"`
+}
+
+// MarshalJSON writes the price with its amount in minor units and formatted.
+func (p Price) MarshalJSON() ([]byte, error) {
+	return json.Marshal(priceJSON{Amount: p.Amount, Currency: p.Currency, VATIncluded: p.VATIncluded, Formatted: p.String()})
+}
+
+// UnmarshalJSON reads a price written by MarshalJSON. A plain number is a
+// price written before prices had a currency, in euros with VAT included.
+func (p *Price) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] != '{' {
+		var amount float64
+		if err := json.Unmarshal(data, &amount); err != nil {
+			return err
+		}
+		*p = NewPrice(amount, DefaultCurrency, true)
+		return nil
+	}
+	var decoded priceJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*p = Price{Amount: decoded.Amount, Currency: decoded.Currency, VATIncluded: decoded.VATIncluded}
+	return nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_Price_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		price Price
+		want  string
+	}{
+		{"euros", Price{Amount: 2050, Currency: "EUR"}, "20,50\u00a0€"},
+		{"thousands", Price{Amount: 123456789, Currency: "EUR"}, "1\u00a0234\u00a0567,89\u00a0€"},
+		{"cents", Price{Amount: 5, Currency: "EUR"}, "0,05\u00a0€"},
+		{"negative", Price{Amount: -1250, Currency: "EUR"}, "-12,50\u00a0€"},
+		{"currency without decimals", Price{Amount: 1500, Currency: "JPY"}, "1\u00a0500\u00a0JPY"},
+		{"currency with three decimals", Price{Amount: 1500, Currency: "KWD"}, "1,500\u00a0KWD"},
+		{"crowns", Price{Amount: 25000, Currency: "SEK"}, "250,00\u00a0kr"},
+		{"no price", Price{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Price_JSON(t *testing.T) {
+	price := Price{Amount: 1550, Currency: "EUR", VATIncluded: true}
+	encoded, err := json.Marshal(price)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := "{\"amount\":1550,\"currency\":\"EUR\",\"vat_included\":true,\"formatted\":\"15,50\u00a0€\"}"; string(encoded) != want {
+		t.Errorf("Marshal() = %s, want %s", encoded, want)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want Price
+	}{
+		{"object", string(encoded), price},
+		{"legacy number", `15.5`, price},
+		{"null", `null`, Price{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Price
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil || got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, %v, want %+v", tt.data, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+// Kinds of search hits.
+const (
+	SearchHitVehicle = "vehicle"
+	SearchHitPart    = "part"
+)
+
+// SearchHit is a vehicle or a part matching a search query. Part is only set
+// for part hits, in which case Vehicle is the vehicle the part belongs to.
+type SearchHit struct {
+	Kind    string  `json:"kind"`
+	Rank    float64 `json:"rank"`
+	Vehicle Vehicle `json:"vehicle"`
+	Part    *Part   `json:"part,omitempty"`
+}
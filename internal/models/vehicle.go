@@ -1,14 +1,43 @@
 package models
 
+import "time"
+
+// Engine strokes of vehicles.
+const (
+	StrokeTwo  = "2T"
+	StrokeFour = "4T"
+)
+
 type Vehicle struct {
-	Name        string `json:"-"`
-	Brand       string `json:"Brand"`
-	Model       string `json:"Model"`
-	VehicleType string `json:"VehicleType"`
-	Identifier  string `json:"Identifier"`
-	Year        int    `json:"Year"`
-	Url         string `json:"Url"`
-	Parts       []Part `json:"Parts"`
+	Name  string `json:"-"`
+	Brand string `json:"Brand"`
+	Model string `json:"Model"`
+	// ModelKey and ModelFamily are derived from Model by the modelname
+	// package. The key is the same for the spelling variants of a model.
+	ModelKey    string `json:"ModelKey"`
+	ModelFamily string `json:"ModelFamily"`
+	// Displacement is in cubic centimetres, 0 when it is not known.
+	Displacement int    `json:"Displacement"`
+	VehicleType  string `json:"VehicleType"`
+	Identifier   string `json:"Identifier"`
+	// Year is the model year, the first one of the range from YearFrom to
+	// YearTo when the listing covers several years.
+	Year     int `json:"Year"`
+	YearFrom int `json:"YearFrom"`
+	YearTo   int `json:"YearTo"`
+	// Stroke is StrokeTwo, StrokeFour or empty when it is not known.
+	Stroke string `json:"Stroke"`
+	// Trim is the rest of the name after the years, such as "ABS".
+	Trim  string `json:"Trim"`
+	Url   string `json:"Url"`
+	Parts []Part `json:"Parts"`
+	// CreatedAt and UpdatedAt are maintained by the database. A vehicle is
+	// updated when its parts change too.
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	// IsNew and RecentlyUpdated are set by Label.
+	IsNew           bool `json:"IsNew"`
+	RecentlyUpdated bool `json:"RecentlyUpdated"`
 }
 
 type RawVehicle struct {
@@ -0,0 +1,259 @@
+// Package pricing parses the prices scraped from part listings, such as
+// "1 234,50 €", "€12.50" or "20 e + alv", into amounts in minor units with
+// their currency and whether they include VAT.
+package pricing
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrNoPrice is returned for text without a number, such as "Kysy hintaa".
+	ErrNoPrice = errors.New("no price found")
+	// ErrMalformedPrice is returned for numbers that are not a price, such as
+	// "1.2.3" or a price with more decimals than its currency has.
+	ErrMalformedPrice = errors.New("malformed price")
+)
+
+// Locale holds the conventions of the prices of a site.
+type Locale struct {
+	// DecimalSeparator tells how to read a number with a single separator
+	// followed by three digits, such as "1,500", which could as well have a
+	// thousands separator.
+	DecimalSeparator rune
+	// Currency is the ISO 4217 code of the prices that do not name theirs.
+	Currency string
+	// VATIncluded tells whether the prices without a VAT note include VAT.
+	VATIncluded bool
+}
+
+// Finnish is the locale of Finnish consumer prices: a decimal comma, euros
+// and VAT included.
+var Finnish = Locale{DecimalSeparator: ',', Currency: "EUR", VATIncluded: true}
+
+// currencyMarkers map the lower case symbols, codes and words written next to
+// the amounts to currencies, longer markers first so that "euroa" wins over
+// "e".
+var currencyMarkers = []struct {
+	marker   string
+	currency string
+}{
+	{"euroa", "EUR"}, {"euro", "EUR"}, {"eur", "EUR"}, {"usd", "USD"}, {"gbp", "GBP"}, {"sek", "SEK"}, {"nok", "NOK"}, {"dkk", "DKK"}, {"chf", "CHF"},
+	{"€", "EUR"}, {"$", "USD"}, {"£", "GBP"}, {"e", "EUR"},
+}
+
+var (
+	// percentage matches the rates of VAT notes, which are not prices.
+	percentage = regexp.MustCompile(`\d+(?:[.,]\d+)?\s*%`)
+	// vatExcluded and vatIncluded match the Finnish, Swedish and English VAT
+	// notes. A VAT rate of 0 excludes VAT, any other rate includes it.
+	vatExcluded = regexp.MustCompile(`\+\s*(?:alv|vat|moms)\b|\b(?:alv|vat|moms)\s*0+(?:[.,]0+)?\s*(?:%|[^\d.,%]|$)|\bveroton|\bilman\s+alv\b|\b(?:excl|ex|exkl)\.?\s*(?:alv|vat|moms)\b`)
+	vatIncluded = regexp.MustCompile(`\b(?:sis|sisältää|incl|inc|including|inkl)\.?\s*(?:alv|vat|moms)\b|\b(?:alv|vat|moms)\s*\d+(?:[.,]\d+)?\s*%`)
+)
+
+// Parse parses the price in the text. The amount is the first number next to
+// a currency symbol or code, or the first number when none is. Its decimal
+// and thousands separators are told apart by their position and count, and
+// by the locale when that is not enough. Prices without a currency or a VAT
+// note get the ones of the locale.
+func Parse(text string, locale Locale) (models.Price, error) {
+	lower := strings.ToLower(text)
+	// The rates of VAT notes are blanked out so that they are not read as prices.
+	numbers := []rune(percentage.ReplaceAllStringFunc(lower, func(rate string) string {
+		return strings.Repeat(" ", len([]rune(rate)))
+	}))
+	var first *number
+	for start := 0; start < len(numbers); start++ {
+		if !unicode.IsDigit(numbers[start]) || (start > 0 && unicode.IsDigit(numbers[start-1])) {
+			continue
+		}
+		found := scanNumber(numbers, start)
+		if found.currency = currencyAround(numbers, found.start, found.end); found.currency != "" {
+			first = &found
+			break
+		}
+		if first == nil {
+			first = &found
+		}
+		start = found.end - 1
+	}
+	if first == nil {
+		return models.Price{}, fmt.Errorf("%w in %q", ErrNoPrice, text)
+	}
+
+	currency := first.currency
+	if currency == "" {
+		currency = locale.Currency
+	}
+	amount, err := first.amount(locale, models.MinorDigits(currency))
+	if err != nil {
+		return models.Price{}, fmt.Errorf("%w: %q: %v", ErrMalformedPrice, text, err)
+	}
+	price := models.Price{Amount: amount, Currency: currency, VATIncluded: locale.VATIncluded}
+	if vatExcluded.MatchString(lower) {
+		price.VATIncluded = false
+	} else if vatIncluded.MatchString(lower) {
+		price.VATIncluded = true
+	}
+	return price, nil
+}
+
+// number is a run of digits and separators in the text.
+type number struct {
+	// start and end are the rune offsets of the number in the text.
+	start, end int
+	text       string
+	// currency is the currency written next to the number.
+	currency string
+}
+
+// isSeparator tells whether the rune can separate the digits of a number.
+func isSeparator(r rune) bool {
+	return r == '.' || r == ',' || isSpaceSeparator(r)
+}
+
+// isSpaceSeparator tells whether the rune can only separate thousands.
+func isSpaceSeparator(r rune) bool {
+	return r == ' ' || r == '\'' || r == '\u00a0' || r == '\u202f'
+}
+
+// scanNumber scans the number starting at the digit at start. A decimal point
+// or comma is part of the number when a digit follows it, a space or an
+// apostrophe only when a group of three digits follows it. A trailing ",-"
+// or ".-" marks a whole amount and is skipped.
+func scanNumber(text []rune, start int) number {
+	digitsFollow := func(i int, count int) bool {
+		for j := i; j < i+count; j++ {
+			if j >= len(text) || !unicode.IsDigit(text[j]) {
+				return false
+			}
+		}
+		return count == 0 || i+count >= len(text) || !unicode.IsDigit(text[i+count])
+	}
+	end := start
+	for end < len(text) {
+		r := text[end]
+		switch {
+		case unicode.IsDigit(r):
+			end++
+			continue
+		case isSpaceSeparator(r) && digitsFollow(end+1, 3):
+			end++
+			continue
+		case (r == '.' || r == ',') && end+1 < len(text) && unicode.IsDigit(text[end+1]):
+			end++
+			continue
+		}
+		break
+	}
+	found := number{start: start, end: end, text: string(text[start:end])}
+	if end+1 < len(text) && (text[end] == ',' || text[end] == '.') && (text[end+1] == '-' || text[end+1] == '–') {
+		found.end += 2
+	}
+	return found
+}
+
+// currencyAround returns the currency whose symbol or code is written right
+// before or after the number, with spaces in between at most.
+func currencyAround(text []rune, start int, end int) string {
+	after := strings.TrimLeftFunc(string(text[end:]), unicode.IsSpace)
+	before := strings.TrimRightFunc(string(text[:start]), unicode.IsSpace)
+	for _, marker := range currencyMarkers {
+		// Codes and words are not the start or the end of longer words.
+		if rest, found := strings.CutPrefix(after, marker.marker); found && !(isWord(marker.marker) && startsWithLetter(rest)) {
+			return marker.currency
+		}
+		// A lone "e" before a number is a word, not euros.
+		if rest, found := strings.CutSuffix(before, marker.marker); found && marker.marker != "e" && !(isWord(marker.marker) && endsWithLetter(rest)) {
+			return marker.currency
+		}
+	}
+	return ""
+}
+
+// isWord tells whether a currency marker is a code or a word, unlike symbols.
+func isWord(marker string) bool {
+	return startsWithLetter(marker)
+}
+
+func startsWithLetter(text string) bool {
+	for _, r := range text {
+		return unicode.IsLetter(r)
+	}
+	return false
+}
+
+func endsWithLetter(text string) bool {
+	runes := []rune(text)
+	return len(runes) > 0 && unicode.IsLetter(runes[len(runes)-1])
+}
+
+// amount returns the number in minor units of a currency with the number of
+// decimals.
+func (n number) amount(locale Locale, decimals int) (int64, error) {
+	var groups []string
+	var separators []rune
+	group := strings.Builder{}
+	for _, r := range n.text {
+		if isSeparator(r) {
+			groups = append(groups, group.String())
+			separators = append(separators, r)
+			group.Reset()
+			continue
+		}
+		group.WriteRune(r)
+	}
+	groups = append(groups, group.String())
+
+	fraction := ""
+	if isDecimalSeparator(separators, groups, locale) {
+		fraction = groups[len(groups)-1]
+		groups, separators = groups[:len(groups)-1], separators[:len(separators)-1]
+	}
+	for i, separator := range separators {
+		if separator != separators[0] {
+			return 0, fmt.Errorf("mixed thousands separators in %q", n.text)
+		}
+		if len(groups[i+1]) != 3 || len(groups[0]) > 3 {
+			return 0, fmt.Errorf("misplaced thousands separator in %q", n.text)
+		}
+	}
+	if len(fraction) > decimals {
+		return 0, fmt.Errorf("%q has more than %d decimals", n.text, decimals)
+	}
+	digits := strings.Join(groups, "") + fraction + strings.Repeat("0", decimals-len(fraction))
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is too large", n.text)
+	}
+	return amount, nil
+}
+
+// isDecimalSeparator tells whether the last separator of a number separates
+// the decimals. A point or comma does unless it occurs more than once, like
+// thousands separators do. When it is the only separator and three digits
+// follow it, the locale decides.
+func isDecimalSeparator(separators []rune, groups []string, locale Locale) bool {
+	if len(separators) == 0 {
+		return false
+	}
+	last := separators[len(separators)-1]
+	if isSpaceSeparator(last) {
+		return false
+	}
+	for _, separator := range separators[:len(separators)-1] {
+		if separator == last {
+			return false
+		}
+	}
+	if len(separators) == 1 && len(groups[1]) == 3 {
+		return last == locale.DecimalSeparator
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package pricing
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"testing"
+)
+
+func Test_Parse(t *testing.T) {
+	// eur returns a price in euro cents with VAT included.
+	eur := func(cents int64) models.Price {
+		return models.Price{Amount: cents, Currency: "EUR", VATIncluded: true}
+	}
+	withoutVAT := func(price models.Price) models.Price {
+		price.VATIncluded = false
+		return price
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    models.Price
+		wantErr error
+	}{
+		// Separators
+		{"whole euros", "20 €", eur(2000), nil},
+		{"decimal point", "15.50 €", eur(1550), nil},
+		{"decimal comma", "12,50 €", eur(1250), nil},
+		{"single decimal", "12,5 €", eur(1250), nil},
+		{"space thousands", "1 234,50 €", eur(123450), nil},
+		{"no-break space thousands", "1\u00a0234,50\u00a0€", eur(123450), nil},
+		{"narrow no-break space thousands", "1\u202f234 €", eur(123400), nil},
+		{"point thousands and decimal comma", "1.234,50 €", eur(123450), nil},
+		{"comma thousands and decimal point", "1,234.50 €", eur(123450), nil},
+		{"apostrophe thousands", "1'234.50 €", eur(123450), nil},
+		{"repeated point thousands", "1.234.567 €", eur(123456700), nil},
+		{"repeated comma thousands", "1,234,567 €", eur(123456700), nil},
+		{"space thousands without decimals", "10 000 €", eur(1000000), nil},
+		{"ambiguous point is thousands in Finnish", "1.500 €", eur(150000), nil},
+		{"ambiguous comma is decimals in Finnish", "1,500 €", models.Price{}, ErrMalformedPrice},
+		{"whole amount dash", "15,- €", eur(1500), nil},
+		{"whole amount dash without currency", "15,-", eur(1500), nil},
+		{"whole amount point dash", "15.-", eur(1500), nil},
+		{"zero", "0 €", eur(0), nil},
+		{"space before the next number is not a separator", "2 10 €", eur(1000), nil},
+
+		// Currencies
+		{"euro sign before", "€12.50", eur(1250), nil},
+		{"euro sign before with space", "€ 12,50", eur(1250), nil},
+		{"euro code after", "12,50 EUR", eur(1250), nil},
+		{"euro code before", "EUR 12,50", eur(1250), nil},
+		{"euro word", "12 euroa", eur(1200), nil},
+		{"euro e", "15e", eur(1500), nil},
+		{"euro e with space", "15 e/kpl", eur(1500), nil},
+		{"no currency is the locale currency", "Hinta 100", eur(10000), nil},
+		{"dollars", "$1,234.56", models.Price{Amount: 123456, Currency: "USD", VATIncluded: true}, nil},
+		{"pounds", "£9.99", models.Price{Amount: 999, Currency: "GBP", VATIncluded: true}, nil},
+		{"Swedish crowns", "250 SEK", models.Price{Amount: 25000, Currency: "SEK", VATIncluded: true}, nil},
+		{"Swiss francs", "1'250.00 CHF", models.Price{Amount: 125000, Currency: "CHF", VATIncluded: true}, nil},
+		{"code that starts a word is not a currency", "12 sekuntia", eur(1200), nil},
+		{"letter after a number is not euros", "2 ei", eur(200), nil},
+
+		// Text around the price
+		{"price label", "Hinta: 7 € / kpl", eur(700), nil},
+		{"quantity before the price", "2 kpl á 10 €", eur(1000), nil},
+		{"year before the price", "Vuosimallia 2019, 50 €", eur(5000), nil},
+		{"first of two prices", "20 € (ennen 30 €)", eur(2000), nil},
+
+		// VAT notes
+		{"VAT included", "20 € sis. alv", eur(2000), nil},
+		{"VAT included with rate", "20 € (sis. alv 24%)", eur(2000), nil},
+		{"VAT rate with decimals", "124,50 € alv 25,5 %", eur(12450), nil},
+		{"VAT rate before the price", "ALV 24% 20 €", eur(2000), nil},
+		{"VAT excluded", "20 € + alv", withoutVAT(eur(2000)), nil},
+		{"VAT excluded without space", "20€+alv", withoutVAT(eur(2000)), nil},
+		{"zero VAT", "20 € alv 0%", withoutVAT(eur(2000)), nil},
+		{"zero VAT without percent", "20 € ALV 0", withoutVAT(eur(2000)), nil},
+		{"tax free", "20 € veroton", withoutVAT(eur(2000)), nil},
+		{"without VAT", "20 € ilman alv", withoutVAT(eur(2000)), nil},
+		{"English VAT included", "€20 incl. VAT", eur(2000), nil},
+		{"English VAT excluded", "€20 excl. VAT", withoutVAT(eur(2000)), nil},
+		{"English VAT added", "€20 + VAT", withoutVAT(eur(2000)), nil},
+		{"Swedish VAT included", "250 SEK inkl. moms", models.Price{Amount: 25000, Currency: "SEK", VATIncluded: true}, nil},
+		{"Swedish VAT excluded", "250 SEK exkl. moms", models.Price{Amount: 25000, Currency: "SEK"}, nil},
+
+		// Errors
+		{"no price", "Kysy hintaa", models.Price{}, ErrNoPrice},
+		{"empty", "", models.Price{}, ErrNoPrice},
+		{"only a VAT rate", "alv 24%", models.Price{}, ErrNoPrice},
+		{"too many decimals", "12,505 €", models.Price{}, ErrMalformedPrice},
+		{"too many decimal points", "1.2.3 €", models.Price{}, ErrMalformedPrice},
+		{"mixed thousands separators", "1.234 567 €", models.Price{}, ErrMalformedPrice},
+		{"misplaced thousands separator", "12.34.567 €", models.Price{}, ErrMalformedPrice},
+		{"too large", "99999999999999999999 €", models.Price{}, ErrMalformedPrice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text, Finnish)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.text, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Parse_locale(t *testing.T) {
+	english := Locale{DecimalSeparator: '.', Currency: "USD", VATIncluded: false}
+	tests := []struct {
+		name string
+		text string
+		want models.Price
+	}{
+		{"decimal point", "12.50", models.Price{Amount: 1250, Currency: "USD"}},
+		{"ambiguous comma is thousands", "1,500", models.Price{Amount: 150000, Currency: "USD"}},
+		{"unambiguous decimal comma", "12,50", models.Price{Amount: 1250, Currency: "USD"}},
+		{"currency overrides the locale", "12,50 €", models.Price{Amount: 1250, Currency: "EUR"}},
+		{"VAT note overrides the locale", "$20 incl. VAT", models.Price{Amount: 2000, Currency: "USD", VATIncluded: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text, english)
+			if err != nil || got != tt.want {
+				t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.text, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"math"
+	"strings"
+)
+
+// Match reports whether the query matches a document split into words by Tokenize.
+func Match(n Node, words []string) bool {
+	switch n := n.(type) {
+	case Term:
+		return occurrences(n, words) > 0
+	case And:
+		for _, child := range n.Children {
+			if !Match(child, words) {
+				return false
+			}
+		}
+		return true
+	case Or:
+		for _, child := range n.Children {
+			if Match(child, words) {
+				return true
+			}
+		}
+		return false
+	case Not:
+		return !Match(n.Child, words)
+	}
+	return false
+}
+
+// Rank scores how well a matching document fits the query. Every occurrence
+// of a term that is not negated counts, and longer documents are scored
+// lower so that a term in a vehicle name outranks the same term in a long
+// part description.
+func Rank(n Node, words []string) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	return float64(countOccurrences(n, words)) / (1 + math.Log(float64(len(words))))
+}
+
+func countOccurrences(n Node, words []string) int {
+	switch n := n.(type) {
+	case Term:
+		return occurrences(n, words)
+	case And:
+		count := 0
+		for _, child := range n.Children {
+			count += countOccurrences(child, words)
+		}
+		return count
+	case Or:
+		count := 0
+		for _, child := range n.Children {
+			count += countOccurrences(child, words)
+		}
+		return count
+	}
+	return 0
+}
+
+// occurrences counts the positions in words where the term's phrase starts.
+func occurrences(term Term, words []string) int {
+	count := 0
+	for start := 0; start+len(term.Words) <= len(words); start++ {
+		matches := true
+		for i, word := range term.Words {
+			last := i == len(term.Words)-1
+			if words[start+i] != word && !(last && term.Prefix && strings.HasPrefix(words[start+i], word)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			count++
+		}
+	}
+	return count
+}
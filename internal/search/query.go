@@ -0,0 +1,281 @@
+// Package search parses free text search queries with Boolean operators and
+// renders them for the full-text search engines of the storage backends.
+//
+// Terms separated by white space must all match. OR between terms matches
+// either of them, NOT or a leading minus excludes a term, parentheses group
+// terms, double quotes match a phrase and a trailing asterisk matches words
+// starting with the term. Operators are written in upper case, so that "or"
+// and "not" can still be searched for as words.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidQuery is returned for queries that cannot be parsed.
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Node is a node of a parsed query.
+type Node interface {
+	node()
+}
+
+// Term matches a word, or a phrase when it has several words. With Prefix
+// the last word matches any word starting with it.
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+// And matches when all of its children match.
+type And struct {
+	Children []Node
+}
+
+// Or matches when any of its children matches.
+type Or struct {
+	Children []Node
+}
+
+// Not matches when its child does not. It is only valid as a child of an And
+// that has other children which are not negated.
+type Not struct {
+	Child Node
+}
+
+func (Term) node() {}
+func (And) node()  {}
+func (Or) node()   {}
+func (Not) node()  {}
+
+// Tokenize lower-cases text and splits it into words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits the query into words, phrases, operators and parentheses.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose})
+			i++
+		case r == '-' && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, token{kind: tokenNot})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated phrase", ErrInvalidQuery)
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: string(runes[i+1 : end])})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd})
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word})
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseOr parses terms separated by OR.
+func (p *parser) parseOr() (Node, error) {
+	var children []Node
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		next, ok := p.peek()
+		if !ok || next.kind != tokenOr {
+			break
+		}
+		p.pos++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return Or{Children: children}, nil
+}
+
+// parseAnd parses terms separated by white space or AND.
+func (p *parser) parseAnd() (Node, error) {
+	var children []Node
+	for {
+		next, ok := p.peek()
+		if !ok || next.kind == tokenOr || next.kind == tokenClose {
+			break
+		}
+		if next.kind == tokenAnd {
+			p.pos++
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return nil, fmt.Errorf("%w: expected a search term", ErrInvalidQuery)
+	case 1:
+		return children[0], nil
+	}
+	return And{Children: children}, nil
+}
+
+// parseUnary parses a negated or plain term, phrase or group. It returns nil
+// for words without letters or digits.
+func (p *parser) parseUnary() (Node, error) {
+	next, _ := p.peek()
+	p.pos++
+	switch next.kind {
+	case tokenNot:
+		if following, ok := p.peek(); !ok || following.kind == tokenOr || following.kind == tokenClose || following.kind == tokenAnd {
+			return nil, fmt.Errorf("%w: NOT must be followed by a term", ErrInvalidQuery)
+		}
+		child, err := p.parseUnary()
+		if err != nil || child == nil {
+			return nil, err
+		}
+		return Not{Child: child}, nil
+	case tokenOpen:
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != tokenClose {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrInvalidQuery)
+		}
+		p.pos++
+		return child, nil
+	case tokenWord:
+		prefix := strings.HasSuffix(next.text, "*")
+		words := Tokenize(next.text)
+		if len(words) == 0 {
+			return nil, nil
+		}
+		return Term{Words: words, Prefix: prefix}, nil
+	case tokenPhrase:
+		words := Tokenize(next.text)
+		if len(words) == 0 {
+			return nil, nil
+		}
+		return Term{Words: words}, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected closing parenthesis", ErrInvalidQuery)
+}
+
+// Parse parses a search query.
+func Parse(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected closing parenthesis", ErrInvalidQuery)
+	}
+	if err = validate(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// validate checks that every negation is a child of an And that also has a
+// child which is not negated, so that a query never matches everything.
+func validate(n Node, negationAllowed bool) error {
+	switch n := n.(type) {
+	case Not:
+		if !negationAllowed {
+			return fmt.Errorf("%w: NOT can only exclude from other terms", ErrInvalidQuery)
+		}
+		return validate(n.Child, false)
+	case And:
+		positive := false
+		for _, child := range n.Children {
+			if _, negated := child.(Not); !negated {
+				positive = true
+			}
+		}
+		for _, child := range n.Children {
+			if err := validate(child, positive); err != nil {
+				return err
+			}
+		}
+	case Or:
+		for _, child := range n.Children {
+			if err := validate(child, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
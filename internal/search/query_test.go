@@ -0,0 +1,94 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantTS    string
+		wantFTS   string
+		wantError bool
+	}{
+		{"Test single word", "Takarengas", "'takarengas'", `"takarengas"`, false},
+		{"Test implicit AND", "suzuki rengas", "('suzuki' & 'rengas')", `("suzuki" AND "rengas")`, false},
+		{"Test explicit AND", "suzuki AND rengas", "('suzuki' & 'rengas')", `("suzuki" AND "rengas")`, false},
+		{"Test OR binds looser than AND", "suzuki rx OR aprilia", "(('suzuki' & 'rx') | 'aprilia')", `(("suzuki" AND "rx") OR "aprilia")`, false},
+		{"Test parentheses", "(suzuki OR aprilia) satula", "(('suzuki' | 'aprilia') & 'satula')", `(("suzuki" OR "aprilia") AND "satula")`, false},
+		{"Test NOT", "rengas NOT taka*", "('rengas' & !'taka':*)", `(("rengas") NOT "taka*")`, false},
+		{"Test minus", "rengas -etu -taka", "('rengas' & !'etu' & !'taka')", `((("rengas") NOT "etu") NOT "taka")`, false},
+		{"Test phrase", `"hyvä kunto"`, "('hyvä' <-> 'kunto')", `"hyvä kunto"`, false},
+		{"Test prefix", "taka*", "'taka':*", `"taka*"`, false},
+		{"Test word with punctuation is a phrase", "RX-3", "('rx' <-> '3')", `"rx 3"`, false},
+		{"Test lower case operators are words", "or not", "('or' & 'not')", `("or" AND "not")`, false},
+		{"Test punctuation is ignored", "rengas &&", "'rengas'", `"rengas"`, false},
+		{"Test empty query", "  ", "", "", true},
+		{"Test only NOT", "-rengas", "", "", true},
+		{"Test NOT in OR", "rengas OR -satula", "", "", true},
+		{"Test unterminated phrase", `"hyvä kunto`, "", "", true},
+		{"Test missing parenthesis", "(suzuki OR aprilia", "", "", true},
+		{"Test extra parenthesis", "suzuki)", "", "", true},
+		{"Test dangling OR", "suzuki OR", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := Parse(tt.query)
+			if tt.wantError {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Fatalf("Parse() error = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := TSQuery(root); got != tt.wantTS {
+				t.Errorf("TSQuery() = %v, want %v", got, tt.wantTS)
+			}
+			if got := FTSQuery(root); got != tt.wantFTS {
+				t.Errorf("FTSQuery() = %v, want %v", got, tt.wantFTS)
+			}
+		})
+	}
+}
+
+func Test_Match(t *testing.T) {
+	document := Tokenize("Suzuki RX Takarengas, hyvä kunto")
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"Test all words", "suzuki takarengas", true},
+		{"Test missing word", "suzuki satula", false},
+		{"Test OR", "satula OR takarengas", true},
+		{"Test NOT", "suzuki -takarengas", false},
+		{"Test phrase", `"hyvä kunto"`, true},
+		{"Test phrase in wrong order", `"kunto hyvä"`, false},
+		{"Test prefix", "taka*", true},
+		{"Test word is not a prefix", "taka", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := Match(root, document); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Rank(t *testing.T) {
+	root, _ := Parse("suzuki")
+	vehicle := Rank(root, Tokenize("Suzuki RX"))
+	part := Rank(root, Tokenize("Suzuki RX Takarengas Hyvä kunto"))
+	if vehicle <= part {
+		t.Errorf("Rank() of a short document = %v, want more than %v", vehicle, part)
+	}
+}
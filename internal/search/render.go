@@ -0,0 +1,69 @@
+package search
+
+import "strings"
+
+// TSQuery renders the query as a PostgreSQL tsquery for to_tsquery with the
+// 'simple' configuration.
+func TSQuery(n Node) string {
+	switch n := n.(type) {
+	case Term:
+		words := make([]string, len(n.Words))
+		for i, word := range n.Words {
+			words[i] = "'" + word + "'"
+		}
+		if n.Prefix {
+			words[len(words)-1] += ":*"
+		}
+		if len(words) == 1 {
+			return words[0]
+		}
+		return "(" + strings.Join(words, " <-> ") + ")"
+	case And:
+		return "(" + joinNodes(n.Children, " & ", TSQuery) + ")"
+	case Or:
+		return "(" + joinNodes(n.Children, " | ", TSQuery) + ")"
+	case Not:
+		return "!" + TSQuery(n.Child)
+	}
+	return ""
+}
+
+// FTSQuery renders the query as a SQLite FTS4 MATCH expression in the enhanced
+// query syntax.
+func FTSQuery(n Node) string {
+	switch n := n.(type) {
+	case Term:
+		phrase := strings.Join(n.Words, " ")
+		if n.Prefix {
+			phrase += "*"
+		}
+		return `"` + phrase + `"`
+	case And:
+		// FTS4 has only a binary NOT, so the negated children are subtracted
+		// from the conjunction of the other children.
+		var positive, negative []Node
+		for _, child := range n.Children {
+			if not, negated := child.(Not); negated {
+				negative = append(negative, not.Child)
+			} else {
+				positive = append(positive, child)
+			}
+		}
+		query := "(" + joinNodes(positive, " AND ", FTSQuery) + ")"
+		for _, child := range negative {
+			query = "(" + query + " NOT " + FTSQuery(child) + ")"
+		}
+		return query
+	case Or:
+		return "(" + joinNodes(n.Children, " OR ", FTSQuery) + ")"
+	}
+	return ""
+}
+
+func joinNodes(nodes []Node, separator string, render func(Node) string) string {
+	rendered := make([]string, len(nodes))
+	for i, child := range nodes {
+		rendered[i] = render(child)
+	}
+	return strings.Join(rendered, separator)
+}
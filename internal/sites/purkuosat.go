@@ -0,0 +1,73 @@
+package sites
+
+import (
+	"Crawler/internal/models"
+	"Crawler/internal/pricing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func init() {
+	Register(Purkuosat{})
+}
+
+// Purkuosat is the adapter of purkuosat.net. The listing pages link to the
+// vehicles in font elements of size 2 and the part pages show each part in a
+// table of 75% width.
+type Purkuosat struct{}
+
+func (Purkuosat) Name() string {
+	return "purkuosat"
+}
+
+func (Purkuosat) AllowedDomains() []string {
+	return []string{"purkuosat.net", "www.purkuosat.net"}
+}
+
+func (Purkuosat) LimitRule() *colly.LimitRule {
+	return &colly.LimitRule{
+		DomainGlob:  "*purkuosat.*",
+		Parallelism: 10,
+		Delay:       50 * time.Millisecond,
+		RandomDelay: 50 * time.Millisecond,
+	}
+}
+
+func (Purkuosat) ListingSelector() string {
+	return "font"
+}
+
+func (Purkuosat) VehicleLink(e *colly.HTMLElement) (string, string, bool) {
+	if e.Attr("size") != "2" {
+		return "", "", false
+	}
+	name := e.ChildText("a")
+	if len(name) == 0 {
+		return "", "", false
+	}
+	return name, e.Request.AbsoluteURL(e.ChildAttr("a", "href")), true
+}
+
+func (Purkuosat) PartSelector() string {
+	return "table"
+}
+
+func (Purkuosat) Part(tb *colly.HTMLElement) (models.RawPart, bool) {
+	if tb.Attr("width") != "75%" {
+		return models.RawPart{}, false
+	}
+	return models.RawPart{
+		Name:           tb.ChildText("tr:nth-of-type(1) > td:nth-of-type(3)"),
+		ImgThumbUrl:    tb.Request.AbsoluteURL(tb.ChildAttr("tr:nth-of-type(1) > td:nth-of-type(1) > a > img", "src")),
+		ImgUrl:         tb.Request.AbsoluteURL(tb.ChildAttr("tr:nth-of-type(1) > td:nth-of-type(1) > a", "href")),
+		PartIdentifier: tb.ChildText("tr:nth-of-type(2) > td:nth-of-type(2)"),
+		Description:    tb.ChildText("tr:nth-of-type(3) > td:nth-of-type(2)"),
+		Price:          tb.ChildText("tr:nth-of-type(4) > td:nth-of-type(2) > font > b:nth-of-type(1)"),
+	}, true
+}
+
+// ParsePrice parses the Finnish prices of the site, such as "15.50 €".
+func (Purkuosat) ParsePrice(price string) (models.Price, error) {
+	return pricing.Parse(price, pricing.Finnish)
+}
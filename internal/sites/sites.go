@@ -0,0 +1,83 @@
+// Package sites holds the adapters of the salvage yard sites the crawler can
+// scrape. Each adapter knows the domains, rate limits and page layout of one
+// site and registers itself under the name used in the crawler config.
+package sites
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// ErrUnknownSite is returned for site names without a registered adapter.
+var ErrUnknownSite = errors.New("unknown site")
+
+// SiteAdapter describes how the listing and part pages of a site are crawled.
+// A listing page links to the part page of every disassembled vehicle.
+type SiteAdapter interface {
+	// Name is the key of the adapter in the registry.
+	Name() string
+	// AllowedDomains are the domains the crawler may visit.
+	AllowedDomains() []string
+	// LimitRule limits the request rate to the site.
+	LimitRule() *colly.LimitRule
+	// ListingSelector selects the elements of a listing page that link to vehicles.
+	ListingSelector() string
+	// VehicleLink returns the vehicle name and part page URL of a listing
+	// element. Elements that are not vehicle links are skipped with ok false.
+	VehicleLink(e *colly.HTMLElement) (name string, url string, ok bool)
+	// PartSelector selects the elements of a part page that each hold a part.
+	PartSelector() string
+	// Part extracts the part of a part element. Elements that are not parts
+	// are skipped with ok false.
+	Part(e *colly.HTMLElement) (part models.RawPart, ok bool)
+	// ParsePrice parses a price in the format of the site.
+	ParsePrice(price string) (models.Price, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]SiteAdapter)
+)
+
+// Register makes an adapter available by its name. It panics when the name is
+// already taken, like registering two adapters for one site would be a bug.
+func Register(adapter SiteAdapter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[adapter.Name()]; exists {
+		panic(fmt.Sprintf("sites: adapter %q registered twice", adapter.Name()))
+	}
+	registry[adapter.Name()] = adapter
+}
+
+// Get returns the adapter registered with the name.
+func Get(name string) (SiteAdapter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	adapter, exists := registry[name]
+	if !exists {
+		return nil, fmt.Errorf("%w %q, registered sites are %v", ErrUnknownSite, name, names())
+	}
+	return adapter, nil
+}
+
+// Names returns the names of the registered adapters in alphabetical order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return names()
+}
+
+func names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package sites
+
+import (
+	"Crawler/internal/models"
+	"errors"
+	"testing"
+)
+
+func Test_Get(t *testing.T) {
+	adapter, err := Get("purkuosat")
+	if err != nil || adapter.Name() != "purkuosat" {
+		t.Errorf("Get(purkuosat) = %v, %v", adapter, err)
+	}
+	if _, err := Get("romupiha"); !errors.Is(err, ErrUnknownSite) {
+		t.Errorf("Get(romupiha) error = %v, want %v", err, ErrUnknownSite)
+	}
+}
+
+func Test_Register(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register() of a taken name should panic")
+		}
+	}()
+	Register(Purkuosat{})
+}
+
+func Test_Purkuosat_ParsePrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   string
+		want    models.Price
+		wantErr bool
+	}{
+		{"Test whole euros", "20 €", models.Price{Amount: 2000, Currency: "EUR", VATIncluded: true}, false},
+		{"Test decimal price", "15.50 €", models.Price{Amount: 1550, Currency: "EUR", VATIncluded: true}, false},
+		{"Test price with text", "Hinta: 7 € / kpl", models.Price{Amount: 700, Currency: "EUR", VATIncluded: true}, false},
+		{"Test price without VAT", "12,50 € + alv", models.Price{Amount: 1250, Currency: "EUR"}, false},
+		{"Test no price", "Kysy hintaa", models.Price{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Purkuosat{}.ParsePrice(tt.price)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParsePrice() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}